@@ -41,6 +41,7 @@ func InitializeOAuth(clientID, clientSecret, callbackURL string) {
 			"publicData",
 			"esi-search.search_structures.v1",
 			"esi-characters.write_contacts.v1",
+			"esi-characters.read_contacts.v1",
 		},
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  "https://login.eveonline.com/v2/oauth/authorize",
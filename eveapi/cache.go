@@ -0,0 +1,178 @@
+package eveapi
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+// How long cached lookups are kept. Affiliations match the ESI cache time, names rarely change.
+const (
+	affiliationCacheTTL = time.Hour
+	nameCacheTTL        = 24 * time.Hour
+	// unknownNameCacheTTL is shorter so newly created characters are found soon after
+	unknownNameCacheTTL = 15 * time.Minute
+
+	// cacheSweepInterval is how often set drops expired entries
+	cacheSweepInterval = 5 * time.Minute
+	// maxCacheEntries caps each cache, a full cache drops a tenth of its entries to make room
+	maxCacheEntries = 100000
+)
+
+// ttlCache is a map whose entries expire. Expired entries are swept on set and the size is capped.
+type ttlCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	entries    map[K]cacheEntry[V]
+	maxEntries int
+	lastSweep  time.Time
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[K comparable, V any]() *ttlCache[K, V] {
+	return &ttlCache[K, V]{entries: make(map[K]cacheEntry[V]), maxEntries: maxCacheEntries, lastSweep: time.Now()}
+}
+
+func (c *ttlCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[K, V]) set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, exists := c.entries[key]; !exists && (now.Sub(c.lastSweep) >= cacheSweepInterval || len(c.entries) >= c.maxEntries) {
+		c.sweep(now)
+	}
+	c.entries[key] = cacheEntry[V]{value: value, expires: now.Add(ttl)}
+}
+
+// sweep drops expired entries, and arbitrary ones when the cache is still full
+func (c *ttlCache[K, V]) sweep(now time.Time) {
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	target := c.maxEntries - max(c.maxEntries/10, 1)
+	for key := range c.entries {
+		if len(c.entries) <= target {
+			break
+		}
+		delete(c.entries, key)
+	}
+}
+
+var (
+	characterNameCache = newTTLCache[string, *model.UniverseEntity]()
+	affiliationCache   = newTTLCache[int64, model.CharacterAffiliation]()
+	entityNameCache    = newTTLCache[int64, string]()
+)
+
+// CachedCharacterIDs resolves character names to characters, keyed by lower case name. Names that are
+// not characters are left out. Results, including unknown names, are cached.
+func CachedCharacterIDs(names []string) (map[string]model.UniverseEntity, error) {
+	characters := make(map[string]model.UniverseEntity)
+	var missing []string
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if character, ok := characterNameCache.get(key); ok {
+			if character != nil {
+				characters[key] = *character
+			}
+			continue
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) == 0 {
+		return characters, nil
+	}
+
+	resolved, err := ResolveNames(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range missing {
+		key := strings.ToLower(strings.TrimSpace(name))
+		character, ok := FindByName(resolved.Characters, name)
+		if !ok {
+			characterNameCache.set(key, nil, unknownNameCacheTTL)
+			continue
+		}
+		character.Category = "character"
+		characterNameCache.set(key, &character, nameCacheTTL)
+		characters[key] = character
+	}
+	return characters, nil
+}
+
+// CachedAffiliations returns the corporation and alliance of each character, keyed by character ID
+func CachedAffiliations(characterIDs []int64) (map[int64]model.CharacterAffiliation, error) {
+	affiliations := make(map[int64]model.CharacterAffiliation, len(characterIDs))
+	var missing []int64
+	for _, id := range characterIDs {
+		if affiliation, ok := affiliationCache.get(id); ok {
+			affiliations[id] = affiliation
+			continue
+		}
+		missing = append(missing, id)
+	}
+	if len(missing) == 0 {
+		return affiliations, nil
+	}
+
+	results, err := GetAffiliations(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, affiliation := range results {
+		affiliationCache.set(affiliation.CharacterID, affiliation, affiliationCacheTTL)
+		affiliations[affiliation.CharacterID] = affiliation
+	}
+	return affiliations, nil
+}
+
+// CachedNames resolves IDs of any kind to names. IDs that do not exist are left out.
+func CachedNames(ids []int64) (map[int64]string, error) {
+	names := make(map[int64]string, len(ids))
+	var missing []int64
+	for _, id := range ids {
+		if name, ok := entityNameCache.get(id); ok {
+			names[id] = name
+			continue
+		}
+		missing = append(missing, id)
+	}
+	if len(uniqueIDs(missing)) == 0 {
+		return names, nil
+	}
+
+	entities, err := ResolveIDs(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		entityNameCache.set(entity.ID, entity.Name, nameCacheTTL)
+		names[entity.ID] = entity.Name
+	}
+	return names, nil
+}
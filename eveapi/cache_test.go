@@ -0,0 +1,39 @@
+package eveapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCacheSweepsExpiredEntries(t *testing.T) {
+	c := newTTLCache[int, string]()
+	c.set(1, "expired", -time.Second)
+	c.set(2, "live", time.Hour)
+
+	// Force the next set to sweep
+	c.lastSweep = time.Now().Add(-cacheSweepInterval)
+	c.set(3, "new", time.Hour)
+
+	if _, ok := c.entries[1]; ok {
+		t.Error("expired entry was not swept")
+	}
+	if len(c.entries) != 2 {
+		t.Errorf("got %d entries, want 2", len(c.entries))
+	}
+}
+
+func TestTTLCacheSizeCap(t *testing.T) {
+	c := newTTLCache[int, int]()
+	c.maxEntries = 100
+
+	for i := 0; i < 1000; i++ {
+		c.set(i, i, time.Hour)
+	}
+
+	if len(c.entries) > c.maxEntries {
+		t.Errorf("got %d entries, want at most %d", len(c.entries), c.maxEntries)
+	}
+	if value, ok := c.get(999); !ok || value != 999 {
+		t.Error("most recently set entry is missing")
+	}
+}
@@ -1,6 +1,8 @@
 package eveapi
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gambtho/whototrust/xlog"
@@ -69,7 +71,10 @@ func createRequestWithParams(baseURL string, params map[string]string, token *oa
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	// Public endpoints may be called without a token
+	if token != nil {
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Accept-Language", "en")
 	req.Header.Set("Cache-Control", "no-cache")
@@ -132,7 +137,7 @@ func makeRequestWithParams(baseURL string, params map[string]string, token *oaut
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusUnauthorized {
+	if resp.StatusCode == http.StatusUnauthorized && token != nil {
 		newToken, err := RefreshToken(token.RefreshToken)
 		if err != nil {
 			return nil, fmt.Errorf("failed to refresh token: %v", err)
@@ -187,3 +192,61 @@ func getResults(address string, token *oauth2.Token, params ...map[string]string
 
 	return bodyBytes, nil
 }
+
+// makePostRequest sends a JSON body to a public ESI endpoint that does not require authentication
+func makePostRequest(address string, body interface{}) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", address, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Language", "en")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if customErr, exists := httpStatusErrors[resp.StatusCode]; exists {
+		xlog.Logf("failed calling %s", address)
+		return nil, customErr
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		xlog.Logf("failed calling %s", address)
+		return nil, NewCustomError(resp.StatusCode, "failed request")
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	return bodyBytes, nil
+}
+
+// postResults posts to the given address, retrying on transient ESI errors
+func postResults(address string, body interface{}) ([]byte, error) {
+	result, err := retryWithExponentialBackoff(func() (interface{}, error) {
+		return makePostRequest(address, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bodyBytes, ok := result.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert result to byte slice")
+	}
+
+	return bodyBytes, nil
+}
@@ -53,7 +53,7 @@ func processIdentity(id int64, token oauth2.Token, userConfig *persist.Identitie
 	userConfig.Tokens[id] = token
 	mu.Unlock()
 
-	corp, err := GetCharacterCorporation(id, &token)
+	public, err := GetPublicCharacterData(id, &token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get corp for character %d: %v", id, err)
 	}
@@ -67,7 +67,8 @@ func processIdentity(id int64, token oauth2.Token, userConfig *persist.Identitie
 
 	character := model.Character{
 		User:          *user,
-		CorporationID: int64(corp),
+		CorporationID: int64(public.CorporationID),
+		AllianceID:    int64(public.AllianceID),
 		Portrait:      portrait,
 	}
 
@@ -149,95 +150,30 @@ func GetCorpInfo(corporationID int64, token *oauth2.Token) (*model.CorporationIn
 	return &corp, nil
 }
 
-func CharacterIDSearch(characterID int64, name string, token *oauth2.Token) (int32, error) {
-	baseURL := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/search/", characterID)
-	params := map[string]string{
-		"categories": "character",
-		"datasource": "tranquility",
-		"language":   "en",
-		"search":     name,
-		"strict":     "true",
-	}
-
-	bodyBytes, err := getResults(baseURL, token, params)
-	if err != nil {
-		return 0, err
-	}
-
-	// Parse JSON response
-	var result struct {
-		Character []int32 `json:"character"`
-	}
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return 0, fmt.Errorf("failed to parse JSON response: %v", err)
-	}
-
-	if len(result.Character) == 0 {
-		xlog.Logf("invalid characters returned from esi %v", result)
-		return 0, fmt.Errorf("no characterIDs returned for that name")
-	}
+// DefaultStanding is the standing contacts are added with when their entry does not set one
+const DefaultStanding = 5.0
 
-	tempID := result.Character[0]
+const (
+	// maxContactsPerAdd is the most contact IDs ESI accepts in a single contacts POST
+	maxContactsPerAdd = 100
+	// maxContactsPerDelete is the most contact_ids ESI accepts in a single contacts DELETE
+	maxContactsPerDelete = 20
+)
 
-	if len(result.Character) > 1 {
-		found := false
-		for _, charID := range result.Character {
-			charData, err := GetPublicCharacterData(int64(charID), token)
-			xlog.Logf("%v", charData)
-			if err != nil {
-				continue
-			}
-			if charData.Name == name {
-				tempID = charID
-				found = true
-				break
-			}
-		}
-		if !found {
-			xlog.Logf("%v returned for  %v", result.Character, name)
-			return 0, fmt.Errorf("invalid character IDs returned for that name")
+// AddContacts sends contacts to the EVE API with the given standing and contact labels, in as many requests as ESI
+// needs. It returns the IDs that were added before any failure.
+func AddContacts(characterID int64, token *oauth2.Token, contactIDs []int64, standing float64, labelIDs []int64) ([]int64, error) {
+	var added []int64
+	for _, chunk := range chunkIDs(contactIDs, maxContactsPerAdd) {
+		if err := addContacts(characterID, token, chunk, standing, labelIDs); err != nil {
+			return added, err
 		}
+		added = append(added, chunk...)
 	}
-
-	return tempID, nil
+	return added, nil
 }
 
-func CorporationIDSearch(characterID int64, name string, token *oauth2.Token) (int32, error) {
-	baseURL := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/search/", characterID)
-
-	// Define query parameters including the token
-	params := map[string]string{
-		"categories": "corporation",
-		"datasource": "tranquility",
-		"language":   "en",
-		"search":     name,
-		"strict":     "true",
-		"token":      token.AccessToken, // Adding the token as a query parameter
-	}
-
-	// Call getResults with the updated params map
-	bodyBytes, err := getResults(baseURL, token, params)
-	if err != nil {
-		return 0, err
-	}
-
-	// Parse JSON response
-	var result struct {
-		Corporation []int32 `json:"corporation"`
-	}
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return 0, fmt.Errorf("failed to parse JSON response: %v", err)
-	}
-
-	if len(result.Corporation) > 1 || len(result.Corporation) == 0 {
-		return 0, fmt.Errorf("invalid corporation IDs returned for that name")
-	}
-
-	return result.Corporation[0], nil
-}
-
-// AddContacts is a helper function to send contacts to the EVE API.
-func AddContacts(characterID int64, token *oauth2.Token, contactIDs []int64) error {
+func addContacts(characterID int64, token *oauth2.Token, contactIDs []int64, standing float64, labelIDs []int64) error {
 	// Prepare JSON payload
 	contactIDsJSON, err := json.Marshal(contactIDs)
 	if err != nil {
@@ -248,7 +184,10 @@ func AddContacts(characterID int64, token *oauth2.Token, contactIDs []int64) err
 	// Build the request URL with query parameters
 	baseURL := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/contacts/", characterID)
 	params := url.Values{}
-	params.Set("standing", strconv.FormatFloat(5.0, 'f', 1, 64))
+	params.Set("standing", strconv.FormatFloat(standing, 'f', 1, 64))
+	for _, id := range labelIDs {
+		params.Add("label_ids", strconv.FormatInt(id, 10))
+	}
 
 	client := &http.Client{}
 	req, err := http.NewRequest("POST", baseURL+"?"+params.Encode(), bytes.NewBuffer(contactIDsJSON))
@@ -288,8 +227,20 @@ func AddContacts(characterID int64, token *oauth2.Token, contactIDs []int64) err
 	return nil
 }
 
-// DeleteContacts is a helper function to send contacts to the EVE API.
-func DeleteContacts(characterID int64, token *oauth2.Token, contactIDs []int64) error {
+// DeleteContacts removes contacts through the EVE API, in as many requests as ESI needs. It returns the IDs that
+// were deleted before any failure.
+func DeleteContacts(characterID int64, token *oauth2.Token, contactIDs []int64) ([]int64, error) {
+	var deleted []int64
+	for _, chunk := range chunkIDs(contactIDs, maxContactsPerDelete) {
+		if err := deleteContacts(characterID, token, chunk); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, chunk...)
+	}
+	return deleted, nil
+}
+
+func deleteContacts(characterID int64, token *oauth2.Token, contactIDs []int64) error {
 	// Prepare JSON payload
 	contactIDsJSON, err := json.Marshal(contactIDs)
 	if err != nil {
@@ -336,6 +287,82 @@ func DeleteContacts(characterID int64, token *oauth2.Token, contactIDs []int64)
 	return nil
 }
 
+// GetContactIDs returns the IDs of every contact a character has, reading all pages
+func GetContactIDs(characterID int64, token *oauth2.Token) ([]int64, error) {
+	var ids []int64
+	// An expired access token is refreshed once and the page retried, like the other authenticated calls
+	refreshed := false
+	for page, pages := 1, 1; page <= pages; page++ {
+		url := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/contacts/?datasource=tranquility&page=%d", characterID, page)
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request: %v", err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !refreshed {
+			resp.Body.Close()
+			newToken, err := RefreshToken(token.RefreshToken)
+			if err != nil {
+				return nil, fmt.Errorf("failed to refresh token: %v", err)
+			}
+			xlog.Logf("token refreshed for contacts of character %d", characterID)
+			*token = *newToken
+			refreshed = true
+			page--
+			continue
+		}
+
+		var contacts []struct {
+			ContactID int64 `json:"contact_id"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&contacts)
+		}
+		resp.Body.Close()
+		if customErr, exists := httpStatusErrors[resp.StatusCode]; exists {
+			return nil, customErr
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, NewCustomError(resp.StatusCode, "failed request")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response body: %v", err)
+		}
+
+		for _, contact := range contacts {
+			ids = append(ids, contact.ContactID)
+		}
+		if value, err := strconv.Atoi(resp.Header.Get("X-Pages")); err == nil {
+			pages = value
+		}
+	}
+
+	return ids, nil
+}
+
+// GetContactLabels returns the contact labels a character has created in game. ESI cannot create labels.
+func GetContactLabels(characterID int64, token *oauth2.Token) ([]model.ContactLabel, error) {
+	url := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/contacts/labels/?datasource=tranquility", characterID)
+
+	bodyBytes, err := getResults(url, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []model.ContactLabel
+	if err := json.Unmarshal(bodyBytes, &labels); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %v", err)
+	}
+
+	return labels, nil
+}
+
 // GetCharacterPortrait retrieves the 64x64 portrait URL for a given characterID.
 func GetCharacterPortrait(characterID int64) (string, error) {
 	url := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/portrait/?datasource=tranquility", characterID)
@@ -0,0 +1,176 @@
+package eveapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const (
+	universeIDsURL   = "https://esi.evetech.net/latest/universe/ids/?datasource=tranquility&language=en"
+	universeNamesURL = "https://esi.evetech.net/latest/universe/names/?datasource=tranquility"
+	affiliationURL   = "https://esi.evetech.net/latest/characters/affiliation/?datasource=tranquility"
+
+	// maxNamesPerRequest is the most names ESI accepts in a single /universe/ids/ call
+	maxNamesPerRequest = 500
+	// maxIDsPerRequest is the most IDs ESI accepts in a single /universe/names/ or /characters/affiliation/ call
+	maxIDsPerRequest = 1000
+)
+
+// ResolveNames resolves character, corporation and alliance names to IDs in bulk.
+// Only entities whose name matches one of the requested names exactly (ignoring case) are returned.
+func ResolveNames(names []string) (*model.UniverseIDsResponse, error) {
+	requested := make(map[string]bool)
+	var unique []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || requested[strings.ToLower(name)] {
+			continue
+		}
+		requested[strings.ToLower(name)] = true
+		unique = append(unique, name)
+	}
+
+	resolved := &model.UniverseIDsResponse{}
+	for start := 0; start < len(unique); start += maxNamesPerRequest {
+		end := min(start+maxNamesPerRequest, len(unique))
+
+		bodyBytes, err := postResults(universeIDsURL, unique[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		var result model.UniverseIDsResponse
+		if err := json.Unmarshal(bodyBytes, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode response body: %v", err)
+		}
+
+		resolved.Characters = append(resolved.Characters, exactMatches(result.Characters, requested)...)
+		resolved.Corporations = append(resolved.Corporations, exactMatches(result.Corporations, requested)...)
+		resolved.Alliances = append(resolved.Alliances, exactMatches(result.Alliances, requested)...)
+	}
+
+	xlog.Logf("resolved %d names to %d characters, %d corporations, %d alliances", len(unique),
+		len(resolved.Characters), len(resolved.Corporations), len(resolved.Alliances))
+
+	return resolved, nil
+}
+
+// FindByName returns the entity with the given name, ignoring case
+func FindByName(entities []model.UniverseEntity, name string) (model.UniverseEntity, bool) {
+	for _, entity := range entities {
+		if strings.EqualFold(entity.Name, strings.TrimSpace(name)) {
+			return entity, true
+		}
+	}
+	return model.UniverseEntity{}, false
+}
+
+func exactMatches(entities []model.UniverseEntity, requested map[string]bool) []model.UniverseEntity {
+	var matches []model.UniverseEntity
+	for _, entity := range entities {
+		if requested[strings.ToLower(entity.Name)] {
+			matches = append(matches, entity)
+		}
+	}
+	return matches
+}
+
+// ResolveIDs resolves character, corporation, alliance and other IDs to names in bulk
+func ResolveIDs(ids []int64) ([]model.UniverseEntity, error) {
+	var entities []model.UniverseEntity
+	for _, chunk := range chunkIDs(uniqueIDs(ids), maxIDsPerRequest) {
+		result, err := resolveIDChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, result...)
+	}
+
+	return entities, nil
+}
+
+// resolveIDChunk resolves a single chunk of IDs. ESI rejects the whole request with a 404
+// when any ID is invalid, so on a 404 the chunk is split until the invalid IDs are isolated and skipped.
+func resolveIDChunk(ids []int64) ([]model.UniverseEntity, error) {
+	bodyBytes, err := postResults(universeNamesURL, ids)
+	if errors.Is(err, ErrNotFound) {
+		if len(ids) == 1 {
+			xlog.Logf("skipping unknown ID %d", ids[0])
+			return nil, nil
+		}
+
+		left, err := resolveIDChunk(ids[:len(ids)/2])
+		if err != nil {
+			return nil, err
+		}
+		right, err := resolveIDChunk(ids[len(ids)/2:])
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []model.UniverseEntity
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %v", err)
+	}
+
+	return result, nil
+}
+
+// GetAffiliations returns the current corporation and alliance for each of the given characters
+func GetAffiliations(characterIDs []int64) ([]model.CharacterAffiliation, error) {
+	var affiliations []model.CharacterAffiliation
+	for _, chunk := range chunkIDs(uniqueIDs(characterIDs), maxIDsPerRequest) {
+		bodyBytes, err := postResults(affiliationURL, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		var result []model.CharacterAffiliation
+		if err := json.Unmarshal(bodyBytes, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode response body: %v", err)
+		}
+		affiliations = append(affiliations, result...)
+	}
+
+	return affiliations, nil
+}
+
+// NamesByID converts resolved entities into a lookup of ID to name
+func NamesByID(entities []model.UniverseEntity) map[int64]string {
+	names := make(map[int64]string, len(entities))
+	for _, entity := range entities {
+		names[entity.ID] = entity.Name
+	}
+	return names
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var unique []int64
+	for _, id := range ids {
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+func chunkIDs(ids []int64, size int) [][]int64 {
+	var chunks [][]int64
+	for start := 0; start < len(ids); start += size {
+		chunks = append(chunks, ids[start:min(start+size, len(ids))])
+	}
+	return chunks
+}
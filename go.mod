@@ -4,8 +4,10 @@ go 1.23.2
 
 require (
 	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/securecookie v1.1.2
 	github.com/gorilla/sessions v1.4.0
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/oauth2 v0.23.0
 )
 
-require github.com/gorilla/securecookie v1.1.2 // indirect
+require golang.org/x/sys v0.4.0 // indirect
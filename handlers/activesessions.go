@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// ActiveSession describes a login session without its values.
+type ActiveSession struct {
+	ID           string    `json:"id"`
+	MainIdentity int64     `json:"main_identity"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	UserAgent    string    `json:"user_agent"`
+	Current      bool      `json:"current"`
+}
+
+// activeSessions returns the unexpired sessions that match, most recently used first.
+func activeSessions(s *SessionService, r *http.Request, match func(persist.Session) bool) ([]ActiveSession, error) {
+	records, err := s.store.ActiveSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	var currentID string
+	if session, err := s.Get(r, sessionName); err == nil && session.ID != "" {
+		currentID = sessionKey(session.ID)
+	}
+
+	active := []ActiveSession{}
+	for _, record := range records {
+		if !match(record) {
+			continue
+		}
+		active = append(active, ActiveSession{
+			ID:           record.ID,
+			MainIdentity: record.MainIdentity,
+			CreatedAt:    record.CreatedAt,
+			LastSeen:     record.LastSeen,
+			UserAgent:    record.UserAgent,
+			Current:      record.ID == currentID,
+		})
+	}
+
+	sort.Slice(active, func(i, j int) bool { return active[i].LastSeen.After(active[j].LastSeen) })
+	return active, nil
+}
+
+// SessionsHandler lists the logged in user's active sessions.
+func SessionsHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		active, err := activeSessions(s, r, func(record persist.Session) bool {
+			return record.MainIdentity == sessionValues.LoggedInUser
+		})
+		if err != nil {
+			xlog.Logf("Error listing sessions: %v", err)
+			sendJSONError(w, "Error listing sessions", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, active)
+	}
+}
+
+// RevokeSessionHandler ends one of the logged in user's sessions.
+func RevokeSessionHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ID == "" {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		record, err := db.LoadSession(request.ID)
+		if err != nil {
+			xlog.Logf("Error loading session: %v", err)
+			sendJSONError(w, "Error loading session", http.StatusInternalServerError)
+			return
+		}
+		if record == nil || record.MainIdentity != sessionValues.LoggedInUser {
+			sendJSONError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		if err := db.DeleteSession(record.ID); err != nil {
+			xlog.Logf("Error deleting session: %v", err)
+			sendJSONError(w, "Error ending session", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, map[string]string{"message": "Session ended"})
+	}
+}
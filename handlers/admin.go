@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// adminIDs holds the character IDs allowed to use the admin endpoints
+var adminIDs = map[int64]bool{}
+
+// SetAdmins sets the character IDs allowed to use the admin endpoints.
+func SetAdmins(ids []int64) {
+	adminIDs = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		adminIDs[id] = true
+	}
+}
+
+// requireAdmin returns the session of a logged in admin, responding with an error otherwise.
+func requireAdmin(s *SessionService, w http.ResponseWriter, r *http.Request) (SessionValues, bool) {
+	session, err := s.Get(r, sessionName)
+	sessionValues := getSessionValues(session)
+	if err != nil || sessionValues.LoggedInUser == 0 {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+		return sessionValues, false
+	}
+
+	if !adminIDs[sessionValues.LoggedInUser] {
+		sendJSONError(w, "Admin access required", http.StatusForbidden)
+		return sessionValues, false
+	}
+
+	return sessionValues, true
+}
+
+// QuarantineHandler lists the identities that could not be decrypted and were quarantined.
+func QuarantineHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(s, w, r); !ok {
+			return
+		}
+
+		quarantined, err := db.ListQuarantined()
+		if err != nil {
+			xlog.Logf("Error listing quarantined identities: %v", err)
+			sendJSONError(w, "Error listing quarantined identities", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, quarantined)
+	}
+}
+
+// RestoreQuarantineHandler decrypts quarantined identities with the configured keys and restores them.
+func RestoreQuarantineHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := requireAdmin(s, w, r)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			MainIdentity int64 `json:"mainIdentity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.MainIdentity == 0 {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		err := db.RestoreQuarantined(request.MainIdentity)
+		switch {
+		case errors.Is(err, persist.ErrNotQuarantined):
+			sendJSONError(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, persist.ErrWrongKey), errors.Is(err, persist.ErrCorrupt):
+			sendJSONError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			xlog.Logf("Error restoring quarantined identity %d: %v", request.MainIdentity, err)
+			sendJSONError(w, "Error restoring quarantined identity", http.StatusInternalServerError)
+			return
+		}
+
+		xlog.Logf("%s restored quarantined identity %d", sessionValues.Actor(), request.MainIdentity)
+		sendJSONResponse(w, http.StatusOK, map[string]string{"message": "Identity restored"})
+	}
+}
+
+// AdminSessionsHandler lists every active session.
+func AdminSessionsHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireAdmin(s, w, r); !ok {
+			return
+		}
+
+		active, err := activeSessions(s, r, func(persist.Session) bool { return true })
+		if err != nil {
+			xlog.Logf("Error listing sessions: %v", err)
+			sendJSONError(w, "Error listing sessions", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, active)
+	}
+}
+
+// ForceLogoutHandler ends every session of a user and revokes their API tokens.
+func ForceLogoutHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := requireAdmin(s, w, r)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			CharacterID int64 `json:"characterID"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CharacterID == 0 {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		records, err := db.ListSessions()
+		if err != nil {
+			xlog.Logf("Error listing sessions: %v", err)
+			sendJSONError(w, "Error listing sessions", http.StatusInternalServerError)
+			return
+		}
+
+		ended := 0
+		for _, record := range records {
+			if record.MainIdentity != request.CharacterID {
+				continue
+			}
+			if err := db.DeleteSession(record.ID); err != nil {
+				xlog.Logf("Error deleting session: %v", err)
+				sendJSONError(w, "Error ending sessions", http.StatusInternalServerError)
+				return
+			}
+			ended++
+		}
+
+		// API tokens would keep working after the sessions end, so a forced logout revokes them too
+		tokens, err := db.ListAPITokens()
+		if err != nil {
+			xlog.Logf("Error listing API tokens: %v", err)
+			sendJSONError(w, "Error revoking API tokens", http.StatusInternalServerError)
+			return
+		}
+		revoked := 0
+		for _, token := range tokens {
+			if token.Owner != request.CharacterID {
+				continue
+			}
+			if err := db.DeleteAPIToken(token.ID); err != nil {
+				xlog.Logf("Error deleting API token: %v", err)
+				sendJSONError(w, "Error revoking API tokens", http.StatusInternalServerError)
+				return
+			}
+			revoked++
+		}
+
+		xlog.Logf("%s ended %d sessions and revoked %d API tokens of character %d", sessionValues.Actor(), ended, revoked, request.CharacterID)
+		sendJSONResponse(w, http.StatusOK, map[string]int{"ended": ended, "revoked": revoked})
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// maxAnalyzedNames limits how many pilots a single paste may contain, enough for a busy local
+const maxAnalyzedNames = 5000
+
+// analyzeRequest is the body of a local chat analysis request
+type analyzeRequest struct {
+	// Names is the member list copied from local or fleet chat, one name per line
+	Names string `json:"names"`
+}
+
+// AnalyzedPilot is one pilot from a pasted member list and how far they are trusted
+type AnalyzedPilot struct {
+	Name            string `json:"name"`
+	ID              int64  `json:"id"`
+	CorporationID   int64  `json:"corporation_id"`
+	CorporationName string `json:"corporation_name"`
+	AllianceID      int64  `json:"alliance_id,omitempty"`
+	AllianceName    string `json:"alliance_name,omitempty"`
+	trust.Verdict
+}
+
+// AnalysisResponse classifies every pilot of a pasted member list against a trust list
+type AnalysisResponse struct {
+	List       string          `json:"list"`
+	Pilots     []AnalyzedPilot `json:"pilots"`
+	Unresolved []string        `json:"unresolved"`
+	// Summary counts the pilots with each verdict
+	Summary map[string]int `json:"summary"`
+}
+
+// APIAnalyzeHandler classifies the pilots of a member list pasted from local or fleet chat as
+// trusted, untrusted or unknown. Names and affiliations are looked up in bulk and cached.
+func APIAnalyzeHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, false)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], false)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		var request analyzeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes)).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Invalid request payload")
+			return
+		}
+		names := pastedNames(request.Names)
+		if len(names) == 0 {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Paste at least one name")
+			return
+		}
+		if len(names) > maxAnalyzedNames {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Too many names, paste fewer than 5000")
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to load trust list entries")
+			return
+		}
+
+		response, err := analyzePilots(names, trust.NewIndex(trustedData))
+		if err != nil {
+			xlog.Logf("Error analyzing pasted names: %v", err)
+			writeAPIError(w, http.StatusBadGateway, apiInternal, "Failed to look up pilots, try again later")
+			return
+		}
+		response.List = list.Name
+
+		writeJSONResponse(w, response, http.StatusOK)
+	}
+}
+
+// pastedNames returns the unique names of a pasted member list. Fleet windows copy more columns
+// separated by tabs, of which the first is the name.
+func pastedNames(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, line := range strings.Split(text, "\n") {
+		name, _, _ := strings.Cut(line, "\t")
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// analyzePilots resolves names to characters and their affiliation and classifies each of them
+func analyzePilots(names []string, index *trust.Index) (AnalysisResponse, error) {
+	response := AnalysisResponse{
+		Pilots:     []AnalyzedPilot{},
+		Unresolved: []string{},
+		Summary:    map[string]int{trust.VerdictTrusted: 0, trust.VerdictUntrusted: 0, trust.VerdictUnknown: 0},
+	}
+
+	characters, err := eveapi.CachedCharacterIDs(names)
+	if err != nil {
+		return response, err
+	}
+	characterIDs := make([]int64, 0, len(characters))
+	for _, character := range characters {
+		characterIDs = append(characterIDs, character.ID)
+	}
+	affiliations, err := eveapi.CachedAffiliations(characterIDs)
+	if err != nil {
+		return response, err
+	}
+	var ids []int64
+	for _, affiliation := range affiliations {
+		ids = append(ids, affiliation.CorporationID, affiliation.AllianceID)
+	}
+	entityNames, err := eveapi.CachedNames(ids)
+	if err != nil {
+		return response, err
+	}
+
+	for _, name := range names {
+		character, ok := characters[strings.ToLower(name)]
+		if !ok {
+			response.Unresolved = append(response.Unresolved, name)
+			continue
+		}
+		affiliation := affiliations[character.ID]
+		affiliation.CharacterID = character.ID
+
+		pilot := AnalyzedPilot{
+			Name:            character.Name,
+			ID:              character.ID,
+			CorporationID:   affiliation.CorporationID,
+			CorporationName: entityNames[affiliation.CorporationID],
+			AllianceID:      affiliation.AllianceID,
+			AllianceName:    entityNames[affiliation.AllianceID],
+			Verdict:         index.Classify(affiliation),
+		}
+		response.Summary[pilot.Verdict.Verdict]++
+		response.Pilots = append(response.Pilots, pilot)
+	}
+	return response, nil
+}
+
+// APIEvaluateHandler returns whether a list trusts a character, from its current affiliation, and the rule that decided it.
+// The list is given with the list query parameter and defaults to the default list.
+func APIEvaluateHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, false)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil || id <= 0 {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "id must be a positive character ID")
+			return
+		}
+
+		list, err := authorizeList(sessionValues, requestList(r.URL.Query().Get("list")), false)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to load trust list entries")
+			return
+		}
+
+		evaluation, err := trust.Evaluate(trustedData, id)
+		if err == trust.ErrUnknownCharacter {
+			writeAPIError(w, http.StatusNotFound, apiNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			xlog.Logf("Error evaluating character %d: %v", id, err)
+			writeAPIError(w, http.StatusBadGateway, apiInternal, "Failed to look up the character, try again later")
+			return
+		}
+
+		writeJSONResponse(w, evaluation, http.StatusOK)
+	}
+}
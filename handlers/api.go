@@ -0,0 +1,753 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// API error codes
+const (
+	apiBadRequest   = "bad_request"
+	apiUnauthorized = "unauthorized"
+	apiForbidden    = "forbidden"
+	apiNotFound     = "not_found"
+	apiConflict     = "conflict"
+	apiExists       = "exists"
+	apiInternal     = "internal"
+)
+
+// Entry paging limits
+const (
+	defaultEntryLimit = 100
+	maxEntryLimit     = 1000
+)
+
+// APIError is the body of every error returned by the JSON API. Current is set on conflicts
+// to the entry as it is now stored.
+type APIError struct {
+	Error   string      `json:"error"`
+	Code    string      `json:"code"`
+	Current interface{} `json:"current,omitempty"`
+}
+
+// EntriesResponse is a page of entries on a trust list.
+type EntriesResponse struct {
+	List     string             `json:"list"`
+	Revision int64              `json:"revision"`
+	Total    int                `json:"total"`
+	Offset   int                `json:"offset"`
+	Limit    int                `json:"limit"`
+	Entries  []model.TrustEntry `json:"entries"`
+}
+
+// apiEntryRequest is the body of a request to add an entry.
+type apiEntryRequest struct {
+	Status     string     `json:"status"`
+	Type       string     `json:"type"`
+	Identifier string     `json:"identifier"`
+	Comment    string     `json:"comment"`
+	Standing   *float64   `json:"standing"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Tags       []string   `json:"tags"`
+}
+
+// apiEntryPatch is the body of a request to change an entry. Fields that are not given are left as they are,
+// an empty ExpiresAt removes the expiry and Tags replaces all of the entry's tags.
+type apiEntryPatch struct {
+	Comment   *string   `json:"comment"`
+	Standing  *float64  `json:"standing"`
+	ExpiresAt *string   `json:"expires_at"`
+	Tags      *[]string `json:"tags"`
+	Revision  *int64    `json:"revision"`
+}
+
+// writeAPIError responds with a JSON API error.
+func writeAPIError(w http.ResponseWriter, statusCode int, code string, message string) {
+	writeJSONResponse(w, APIError{Error: message, Code: code}, statusCode)
+}
+
+// writeAPIListError responds with the error from authorizeList.
+func writeAPIListError(w http.ResponseWriter, err error) {
+	switch status := listErrorStatus(err); status {
+	case http.StatusNotFound:
+		writeAPIError(w, status, apiNotFound, err.Error())
+	case http.StatusForbidden:
+		writeAPIError(w, status, apiForbidden, err.Error())
+	default:
+		xlog.Logf("Error loading trust list: %v", err)
+		writeAPIError(w, status, apiInternal, "Failed to load trust list")
+	}
+}
+
+// writeAPIConflict responds with 409 and the entry as it is now stored.
+func writeAPIConflict(w http.ResponseWriter, list string, status string, entityType string, id int64, code string, message string) {
+	var current interface{}
+	if trustedData, err := db.LoadTrustedCharacters(list); err == nil {
+		if entry, ok := trust.FindEntry(trustedData, status, entityType, id); ok {
+			current = entry
+		}
+	}
+	writeJSONResponse(w, APIError{Error: message, Code: code, Current: current}, http.StatusConflict)
+}
+
+// authenticateAPI returns the user making an API request, from an Authorization: Bearer API token or
+// the session cookie. Requests that change data need a read-write token. Failures are answered with 401 or 403.
+func authenticateAPI(s *SessionService, w http.ResponseWriter, r *http.Request, write bool) (SessionValues, bool) {
+	if secret, ok := bearerToken(r); ok {
+		sessionValues, token, ok := authenticateToken(secret)
+		if !ok {
+			writeAPIError(w, http.StatusUnauthorized, apiUnauthorized, "Invalid or expired API token")
+			return sessionValues, false
+		}
+		if write && token.Scope != persist.TokenScopeReadWrite {
+			writeAPIError(w, http.StatusForbidden, apiForbidden, "This API token is read only")
+			return sessionValues, false
+		}
+		return sessionValues, true
+	}
+
+	session, err := s.Get(r, sessionName)
+	sessionValues := getSessionValues(session)
+	if err != nil || sessionValues.LoggedInUser == 0 {
+		writeAPIError(w, http.StatusUnauthorized, apiUnauthorized, "Authentication required")
+		return sessionValues, false
+	}
+	return sessionValues, true
+}
+
+// validEntryKind reports whether status and entityType name one of the six kinds of entry.
+func validEntryKind(status string, entityType string) bool {
+	return (status == trust.StatusTrusted || status == trust.StatusUntrusted) &&
+		(entityType == trust.TypeCharacter || entityType == trust.TypeCorporation || entityType == trust.TypeAlliance)
+}
+
+// validStanding checks a requested contact standing is in the range EVE allows.
+func validStanding(standing *float64) error {
+	if standing != nil && (*standing < -10 || *standing > 10) {
+		return fmt.Errorf("standing must be between -10 and 10")
+	}
+	return nil
+}
+
+// validExpiry checks a requested expiry is in the future.
+func validExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
+// parseExpiry reads the expiry of a change request, returning a zero time to remove the expiry.
+func parseExpiry(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expires_at must be an RFC 3339 time, or empty to remove the expiry")
+	}
+	return expiresAt, validExpiry(&expiresAt)
+}
+
+// entryStanding returns the standing an entry is synced with.
+func entryStanding(entry model.TrustEntry) float64 {
+	if entry.Standing != nil {
+		return *entry.Standing
+	}
+	return eveapi.DefaultStanding
+}
+
+// entryPath returns the API path of an entry.
+func entryPath(list string, entry model.TrustEntry) string {
+	return fmt.Sprintf("/api/v1/lists/%s/entries/%s/%s/%d", list, entry.Status, entry.Type, entry.ID)
+}
+
+// entryFromPath reads the entry a request is for from its path.
+func entryFromPath(r *http.Request) (string, string, int64, error) {
+	vars := mux.Vars(r)
+	status, entityType := vars["status"], vars["type"]
+	if !validEntryKind(status, entityType) {
+		return "", "", 0, fmt.Errorf("unknown entry kind: %s %s", status, entityType)
+	}
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil || id <= 0 {
+		return "", "", 0, fmt.Errorf("invalid id: %s", vars["id"])
+	}
+	return status, entityType, id, nil
+}
+
+// filterEntries returns the entries matching the query parameters of a request. The tag parameter takes
+// comma separated tags and matches entries with any of them.
+func filterEntries(entries []model.TrustEntry, query map[string][]string) ([]model.TrustEntry, error) {
+	get := func(name string) string {
+		if values := query[name]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+
+	status, entityType := get("status"), get("type")
+	search := strings.ToLower(get("q"))
+	addedBy := strings.ToLower(get("added_by"))
+	tags, err := trust.NormalizeTags(trust.SplitTags(get("tag")))
+	if err != nil {
+		return nil, err
+	}
+	var corporationID, allianceID int64
+	for name, target := range map[string]*int64{"corporation_id": &corporationID, "alliance_id": &allianceID} {
+		if value := get(name); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", name, value)
+			}
+			*target = id
+		}
+	}
+
+	filtered := []model.TrustEntry{}
+	for _, entry := range entries {
+		switch {
+		case status != "" && entry.Status != status:
+		case entityType != "" && entry.Type != entityType:
+		case search != "" && !strings.Contains(strings.ToLower(entry.Name), search) && !strings.Contains(strings.ToLower(entry.Comment), search):
+		case addedBy != "" && strings.ToLower(entry.AddedBy) != addedBy:
+		case corporationID != 0 && entry.CorporationID != corporationID && !(entry.Type == trust.TypeCorporation && entry.ID == corporationID):
+		case allianceID != 0 && entry.AllianceID != allianceID && !(entry.Type == trust.TypeAlliance && entry.ID == allianceID):
+		case !trust.HasAnyTag(entry.Tags, tags):
+		default:
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+// sortEntries orders entries by a field, descending if it starts with a dash. Ties are broken by name and ID.
+func sortEntries(entries []model.TrustEntry, sortBy string) error {
+	descending := strings.HasPrefix(sortBy, "-")
+	field := strings.TrimPrefix(sortBy, "-")
+
+	var compare func(a, b model.TrustEntry) int
+	switch field {
+	case "", "name":
+		compare = func(a, b model.TrustEntry) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+	case "id":
+		compare = func(a, b model.TrustEntry) int { return cmp.Compare(a.ID, b.ID) }
+	case "date_added":
+		compare = func(a, b model.TrustEntry) int { return a.DateAdded.Compare(b.DateAdded) }
+	case "added_by":
+		compare = func(a, b model.TrustEntry) int {
+			return strings.Compare(strings.ToLower(a.AddedBy), strings.ToLower(b.AddedBy))
+		}
+	case "corporation":
+		compare = func(a, b model.TrustEntry) int {
+			return strings.Compare(strings.ToLower(a.CorporationName), strings.ToLower(b.CorporationName))
+		}
+	case "alliance":
+		compare = func(a, b model.TrustEntry) int {
+			return strings.Compare(strings.ToLower(a.AllianceName), strings.ToLower(b.AllianceName))
+		}
+	case "standing":
+		compare = func(a, b model.TrustEntry) int { return cmp.Compare(entryStanding(a), entryStanding(b)) }
+	case "revision":
+		compare = func(a, b model.TrustEntry) int { return cmp.Compare(a.Revision, b.Revision) }
+	default:
+		return fmt.Errorf("cannot sort by %s", field)
+	}
+
+	slices.SortStableFunc(entries, func(a, b model.TrustEntry) int {
+		result := compare(a, b)
+		if descending {
+			result = -result
+		}
+		if result == 0 {
+			result = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+		if result == 0 {
+			result = cmp.Compare(a.ID, b.ID)
+		}
+		return result
+	})
+	return nil
+}
+
+// queryInt reads a non-negative integer query parameter, returning fallback if it is not set.
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return number, nil
+}
+
+// addEntry stores a new entry built from resolved entity data and reports whether it was added. An entry that is
+// already on the list is a conflict.
+func addEntry(list string, status string, entityType string, data EntityData, addedBy string, comment string, standing *float64, expiresAt *time.Time, tags []string) (bool, error) {
+	// Adding at revision 0 makes an existing entry a conflict rather than a silent success
+	const newEntry int64 = 0
+	now := time.Now()
+
+	if entityType == trust.TypeCharacter {
+		character := model.TrustedCharacter{
+			CharacterID:     data.ID,
+			CharacterName:   data.Name,
+			CorporationID:   data.CorporationID,
+			CorporationName: data.CorporationName,
+			AllianceID:      data.AllianceID,
+			AllianceName:    data.AllianceName,
+			AddedBy:         addedBy,
+			DateAdded:       now,
+			Comment:         comment,
+			Standing:        standing,
+			ExpiresAt:       expiresAt,
+			Tags:            tags,
+		}
+		if status == trust.StatusUntrusted {
+			return persist.AddUntrustedCharacter(db, list, character, newEntry)
+		}
+		return persist.AddTrustedCharacter(db, list, character, newEntry)
+	}
+
+	if entityType == trust.TypeAlliance {
+		alliance := model.TrustedAlliance{
+			AllianceID:   data.ID,
+			AllianceName: data.Name,
+			AddedBy:      addedBy,
+			DateAdded:    now,
+			Comment:      comment,
+			Standing:     standing,
+			ExpiresAt:    expiresAt,
+			Tags:         tags,
+		}
+		if status == trust.StatusUntrusted {
+			return persist.AddUntrustedAlliance(db, list, alliance, newEntry)
+		}
+		return persist.AddTrustedAlliance(db, list, alliance, newEntry)
+	}
+
+	corporation := model.TrustedCorporation{
+		CorporationID:   data.ID,
+		CorporationName: data.Name,
+		AllianceID:      data.AllianceID,
+		AllianceName:    data.AllianceName,
+		AddedBy:         addedBy,
+		DateAdded:       now,
+		Comment:         comment,
+		Standing:        standing,
+		ExpiresAt:       expiresAt,
+		Tags:            tags,
+	}
+	if status == trust.StatusUntrusted {
+		return persist.AddUntrustedCorporation(db, list, corporation, newEntry)
+	}
+	return persist.AddTrustedCorporation(db, list, corporation, newEntry)
+}
+
+// removeEntry removes an entry from a list if it is at the expected revision and reports whether it was removed.
+func removeEntry(list string, status string, entityType string, id int64, revision int64) (bool, error) {
+	switch {
+	case status == trust.StatusTrusted && entityType == trust.TypeCharacter:
+		return persist.RemoveTrustedCharacter(db, list, id, revision)
+	case status == trust.StatusTrusted && entityType == trust.TypeCorporation:
+		return persist.RemoveTrustedCorporation(db, list, id, revision)
+	case status == trust.StatusUntrusted && entityType == trust.TypeCharacter:
+		return persist.RemoveUntrustedCharacter(db, list, id, revision)
+	case status == trust.StatusTrusted && entityType == trust.TypeAlliance:
+		return persist.RemoveTrustedAlliance(db, list, id, revision)
+	case status == trust.StatusUntrusted && entityType == trust.TypeAlliance:
+		return persist.RemoveUntrustedAlliance(db, list, id, revision)
+	}
+	return persist.RemoveUntrustedCorporation(db, list, id, revision)
+}
+
+// APIListsHandler returns the lists the user can see.
+func APIListsHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, false)
+		if !ok {
+			return
+		}
+
+		lists, err := visibleLists(sessionValues)
+		if err != nil {
+			xlog.Logf("Error loading trust lists: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to load trust lists")
+			return
+		}
+		writeJSONResponse(w, map[string]interface{}{"lists": lists}, http.StatusOK)
+	}
+}
+
+// APIEntriesHandler returns a filtered, sorted page of the entries on a list.
+func APIEntriesHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, false)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], false)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		limit, err := queryInt(r, "limit", defaultEntryLimit)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+		if limit == 0 || limit > maxEntryLimit {
+			limit = maxEntryLimit
+		}
+		offset, err := queryInt(r, "offset", 0)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to load trust list entries")
+			return
+		}
+
+		entries, err := filterEntries(trust.Entries(trustedData), r.URL.Query())
+		if err == nil {
+			err = sortEntries(entries, r.URL.Query().Get("sort"))
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		total := len(entries)
+		end := min(offset+limit, total)
+		page := []model.TrustEntry{}
+		if offset < total {
+			page = entries[offset:end]
+		}
+
+		writeJSONResponse(w, EntriesResponse{
+			List:     list.Name,
+			Revision: trustedData.Revision,
+			Total:    total,
+			Offset:   offset,
+			Limit:    limit,
+			Entries:  page,
+		}, http.StatusOK)
+	}
+}
+
+// APIEntryHandler returns a single entry.
+func APIEntryHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, false)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], false)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		status, entityType, id, err := entryFromPath(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to load trust list entries")
+			return
+		}
+
+		entry, found := trust.FindEntry(trustedData, status, entityType, id)
+		if !found {
+			writeAPIError(w, http.StatusNotFound, apiNotFound, "Entry not found")
+			return
+		}
+		setRevisionHeader(w, entry.Revision)
+		writeJSONResponse(w, entry, http.StatusOK)
+	}
+}
+
+// APICreateEntryHandler resolves an entity by name or ID and adds it to a list.
+func APICreateEntryHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, true)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], true)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		var request apiEntryRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Invalid request payload")
+			return
+		}
+		if !validEntryKind(request.Status, request.Type) {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "status must be trusted or untrusted and type must be character, corporation or alliance")
+			return
+		}
+		if err := validStanding(request.Standing); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+		if err := validExpiry(request.ExpiresAt); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+		tags, err := trust.NormalizeTags(request.Tags)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		resolvedData, err := resolveIdentifier(request.Identifier, request.Type)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		token, err := persist.GetMainIdentityToken(db, sessionValues.LoggedInUser)
+		if err != nil {
+			xlog.Logf("Error retrieving token for main identity: %v", err)
+			writeAPIError(w, http.StatusUnauthorized, apiUnauthorized, "Failed to retrieve token, sign in again")
+			return
+		}
+
+		fetchedData, err := fetchEntityData(request.Type, resolvedData, &token)
+		if err != nil {
+			xlog.Logf("Entity data fetching error: %v", err)
+			writeAPIError(w, http.StatusBadGateway, apiInternal, "Entity data retrieval failed")
+			return
+		}
+
+		previousConflicts := currentConflicts(list.Name)
+		added, err := addEntry(list.Name, request.Status, request.Type, fetchedData, sessionValues.Actor(), request.Comment, request.Standing, request.ExpiresAt, tags)
+		if err == persist.ErrRevisionConflict {
+			writeAPIConflict(w, list.Name, request.Status, request.Type, fetchedData.ID, apiExists, "Entry is already on the list")
+			return
+		}
+		if err != nil {
+			xlog.Logf("Error saving %s %s: %v", request.Status, request.Type, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to save entry")
+			return
+		}
+
+		if added {
+			recordEvents(entryEvent(model.EventEntryAdded, sessionValues.Actor(), list.Name, request.Status, request.Type, fetchedData.ID, fetchedData.Name, ""))
+		}
+		recordEvents(onList(list.Name, trust.ConflictEvents(previousConflicts, currentConflicts(list.Name), sessionValues.Actor()))...)
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Entry was added but could not be read back")
+			return
+		}
+		entry, _ := trust.FindEntry(trustedData, request.Status, request.Type, fetchedData.ID)
+
+		w.Header().Set("Location", entryPath(list.Name, entry))
+		setRevisionHeader(w, entry.Revision)
+		writeJSONResponse(w, entry, http.StatusCreated)
+	}
+}
+
+// APIUpdateEntryHandler changes the comment, standing, expiry or tags of an entry.
+func APIUpdateEntryHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, true)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], true)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		status, entityType, id, err := entryFromPath(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		var request apiEntryPatch
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Invalid request payload")
+			return
+		}
+		if request.Comment == nil && request.Standing == nil && request.ExpiresAt == nil && request.Tags == nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Nothing to change, give a comment, standing, expires_at or tags")
+			return
+		}
+		if err := validStanding(request.Standing); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+		var expiresAt *time.Time
+		if request.ExpiresAt != nil {
+			parsed, err := parseExpiry(*request.ExpiresAt)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+				return
+			}
+			expiresAt = &parsed
+		}
+		var tags *[]string
+		if request.Tags != nil {
+			normalized, err := trust.NormalizeTags(*request.Tags)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+				return
+			}
+			tags = &normalized
+		}
+		revision, err := requestRevision(r, request.Revision)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		name, newRevision, err := persist.UpdateEntry(db, list.Name, status, entityType, id, revision, persist.EntryChange{
+			Comment:   request.Comment,
+			Standing:  request.Standing,
+			ExpiresAt: expiresAt,
+			Tags:      tags,
+		})
+		switch {
+		case err == persist.ErrRevisionConflict:
+			writeAPIConflict(w, list.Name, status, entityType, id, apiConflict, "This entry was changed by someone else")
+			return
+		case err == persist.ErrEntryNotFound:
+			writeAPIError(w, http.StatusNotFound, apiNotFound, "Entry not found")
+			return
+		case err != nil:
+			xlog.Logf("Error updating entry: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to save entry")
+			return
+		}
+
+		if request.Comment != nil {
+			recordEvents(entryEvent(model.EventCommentChanged, sessionValues.Actor(), list.Name, status, entityType, id, name, fmt.Sprintf("comment set to %q", *request.Comment)))
+		}
+		if request.Standing != nil {
+			recordEvents(entryEvent(model.EventStandingChanged, sessionValues.Actor(), list.Name, status, entityType, id, name, fmt.Sprintf("standing set to %+.1f", *request.Standing)))
+		}
+		if expiresAt != nil {
+			detail := "expiry removed"
+			if !expiresAt.IsZero() {
+				detail = "expires " + expiresAt.UTC().Format(time.RFC3339)
+			}
+			recordEvents(entryEvent(model.EventExpiryChanged, sessionValues.Actor(), list.Name, status, entityType, id, name, detail))
+		}
+		if tags != nil {
+			detail := "tags removed"
+			if len(*tags) > 0 {
+				detail = "tags set to " + strings.Join(*tags, ", ")
+			}
+			recordEvents(entryEvent(model.EventTagsChanged, sessionValues.Actor(), list.Name, status, entityType, id, name, detail))
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Entry was changed but could not be read back")
+			return
+		}
+		entry, _ := trust.FindEntry(trustedData, status, entityType, id)
+
+		setRevisionHeader(w, newRevision)
+		writeJSONResponse(w, entry, http.StatusOK)
+	}
+}
+
+// APIDeleteEntryHandler removes an entry from a list.
+func APIDeleteEntryHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, true)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], true)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		status, entityType, id, err := entryFromPath(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+		revision, err := requestRevision(r, nil)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		name := lookupEntityName(list.Name, status, entityType, id)
+		if name == "" && revision == persist.AnyRevision {
+			writeAPIError(w, http.StatusNotFound, apiNotFound, "Entry not found")
+			return
+		}
+
+		removed, err := removeEntry(list.Name, status, entityType, id, revision)
+		if err == persist.ErrRevisionConflict {
+			writeAPIConflict(w, list.Name, status, entityType, id, apiConflict, "This entry was changed by someone else")
+			return
+		}
+		if err != nil {
+			xlog.Logf("Error removing entry: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to remove entry")
+			return
+		}
+
+		if !removed {
+			// Removed by someone else since it was looked up
+			writeAPIError(w, http.StatusNotFound, apiNotFound, "Entry not found")
+			return
+		}
+
+		recordEvents(entryEvent(model.EventEntryRemoved, sessionValues.Actor(), list.Name, status, entityType, id, name, ""))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document describing the JSON API.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "static/openapi.json")
+}
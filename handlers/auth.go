@@ -46,6 +46,8 @@ func CallbackHandler(s *SessionService) http.HandlerFunc {
 
 		if state[:4] == "main" {
 			session.Values[loggedInUser] = user.CharacterID
+			session.Values[loggedInUserName] = user.CharacterName
+			updateSessionAffiliation(session, user.CharacterID)
 		}
 
 		if _, ok := session.Values[allAuthenticatedCharacters].([]int64); ok {
@@ -62,7 +64,7 @@ func CallbackHandler(s *SessionService) http.HandlerFunc {
 			return
 		}
 
-		err = persist.UpdateIdentities(mainIdentity, func(userConfig *persist.Identities) error {
+		err = persist.UpdateIdentities(db, mainIdentity, func(userConfig *persist.Identities) error {
 			userConfig.Tokens[user.CharacterID] = *token
 			return nil
 		})
@@ -98,7 +100,7 @@ func ResetIdentitiesHandler(s *SessionService) http.HandlerFunc {
 			return
 		}
 
-		err := persist.DeleteIdentity(mainIdentity)
+		err := db.DeleteIdentity(mainIdentity)
 		if err != nil {
 			xlog.Logf("Failed to delete identity %d: %v", mainIdentity, err)
 		}
@@ -2,77 +2,98 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
+	"github.com/gambtho/whototrust/model"
 	"github.com/gambtho/whototrust/persist"
 	"github.com/gambtho/whototrust/xlog"
 )
 
-// UpdateCommentHandler processes the request to add contacts to the EVE API.
-func UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
-
-	xlog.Logf("Update Comment Handler invoked")
-	var request struct {
-		ID      int64  `json:"id"`
-		Comment string `json:"comment"`
-		TableID string `json:"tableId"`
+// tableEntity maps a home page table id to the list and entity type it shows.
+func tableEntity(tableID string) (trustStatus string, entityType string, ok bool) {
+	switch tableID {
+	case "trusted-characters-table":
+		return "trusted", "character", true
+	case "trusted-corporations-table":
+		return "trusted", "corporation", true
+	case "untrusted-characters-table":
+		return "untrusted", "character", true
+	case "untrusted-corporations-table":
+		return "untrusted", "corporation", true
+	case "trusted-alliances-table":
+		return "trusted", "alliance", true
+	case "untrusted-alliances-table":
+		return "untrusted", "alliance", true
 	}
+	return "", "", false
+}
 
-	// Decode the JSON payload
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		xlog.Logf("Error decoding JSON: %v", err)
-		sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-	xlog.Logf("Received Update Comment request for: %v", request)
+// UpdateCommentHandler updates the comment on a trust list entry.
+func UpdateCommentHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 
-	data, err := persist.LoadTrustedCharacters()
-	if err != nil {
-		xlog.Logf("Error loading trusted characters: %v", err)
-		sendJSONError(w, "Error loading trusted characters", http.StatusInternalServerError)
-		return
-	}
+		xlog.Logf("Update Comment Handler invoked")
+		var request struct {
+			ID      int64  `json:"id"`
+			Comment string `json:"comment"`
+			TableID string `json:"tableId"`
+			List    string `json:"list"`
+			// Revision is the entry revision the comment was edited against
+			Revision *int64 `json:"revision"`
+		}
 
-	switch request.TableID {
-	case "trusted-characters-table":
-		for i, character := range data.TrustedCharacters {
-			if character.CharacterID == request.ID {
-				data.TrustedCharacters[i].Comment = request.Comment
-				break
-			}
+		// Decode the JSON payload
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			xlog.Logf("Error decoding JSON: %v", err)
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
 		}
-	case "trusted-corporations-table":
-		for i, corporation := range data.TrustedCorporations {
-			if corporation.CorporationID == request.ID {
-				data.TrustedCorporations[i].Comment = request.Comment
-				break
-			}
+		xlog.Logf("Received Update Comment request for: %v", request)
+
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
 		}
-	case "untrusted-characters-table":
-		for i, character := range data.UntrustedCharacters {
-			if character.CharacterID == request.ID {
-				data.UntrustedCharacters[i].Comment = request.Comment
-				break
-			}
+
+		list, err := authorizeList(sessionValues, requestList(request.List), true)
+		if err != nil {
+			sendJSONError(w, err.Error(), listErrorStatus(err))
+			return
 		}
-	case "unsanctioned-corporations-table":
-		for i, corporation := range data.UntrustedCorporations {
-			if corporation.CorporationID == request.ID {
-				data.UntrustedCorporations[i].Comment = request.Comment
-				break
-			}
+
+		revision, err := requestRevision(r, request.Revision)
+		if err != nil {
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-	default:
-		xlog.Logf("table id was not recognized: %v", request.TableID)
-		sendJSONError(w, "Error parsing tableID", http.StatusInternalServerError)
-		return
-	}
 
-	if err := persist.SaveTrustedCharacters(data); err != nil {
-		xlog.Logf("Error saving trusted characters: %v", err)
-		sendJSONError(w, "Error saving trusted characters", http.StatusInternalServerError)
-		return
-	}
+		trustStatus, entityType, ok := tableEntity(request.TableID)
+		if !ok {
+			xlog.Logf("table id was not recognized: %v", request.TableID)
+			sendJSONError(w, "Error parsing tableID", http.StatusInternalServerError)
+			return
+		}
+
+		entityName, newRevision, err := persist.UpdateEntry(db, list.Name, trustStatus, entityType, request.ID, revision, persist.EntryChange{Comment: &request.Comment})
+		switch {
+		case err == persist.ErrRevisionConflict:
+			writeRevisionConflict(w, list.Name, trustStatus, entityType, request.ID)
+			return
+		case err == persist.ErrEntryNotFound:
+			sendJSONError(w, "Entry not found", http.StatusNotFound)
+			return
+		case err != nil:
+			xlog.Logf("Error updating trusted characters: %v", err)
+			sendJSONError(w, "Error saving trusted characters", http.StatusInternalServerError)
+			return
+		}
 
-	sendJSONResponse(w, http.StatusOK, map[string]string{"message": "Comment updated successfully"})
+		recordEvents(entryEvent(model.EventCommentChanged, sessionValues.Actor(), list.Name, trustStatus, entityType, request.ID, entityName, fmt.Sprintf("comment set to %q", request.Comment)))
+
+		setRevisionHeader(w, newRevision)
+		sendJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "Comment updated successfully", "revision": newRevision})
+	}
 }
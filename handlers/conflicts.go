@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// ConflictsHandler returns every entry on a trust list whose current affiliation is on the opposing side,
+// for the list given by the list query parameter or the default list.
+func ConflictsHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		list, err := authorizeList(sessionValues, requestList(r.URL.Query().Get("list")), false)
+		if err != nil {
+			sendJSONError(w, err.Error(), listErrorStatus(err))
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading trusted characters: %v", err)
+			sendJSONError(w, "Error loading trusted characters", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, trust.FindConflicts(trustedData))
+	}
+}
@@ -1,15 +1,53 @@
 package handlers
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
 	"github.com/gambtho/whototrust/persist"
 	"github.com/gambtho/whototrust/xlog"
 )
 
+// syncFailureThreshold is the number of consecutive failed contact syncs for a character before a notification is sent
+const syncFailureThreshold = 3
+
+// syncFailures counts consecutive failed contact syncs by character
+var syncFailures = struct {
+	sync.Mutex
+	counts map[int64]int
+}{counts: make(map[int64]int)}
+
+// recordSyncResult tracks consecutive contact sync failures and records an event once they pile up
+func recordSyncResult(characterID int64, actor string, syncErr error) {
+	syncFailures.Lock()
+	if syncErr == nil {
+		delete(syncFailures.counts, characterID)
+		syncFailures.Unlock()
+		return
+	}
+	syncFailures.counts[characterID]++
+	count := syncFailures.counts[characterID]
+	syncFailures.Unlock()
+
+	if count == syncFailureThreshold {
+		recordEvents(model.AuditEvent{
+			Type:       model.EventSyncFailures,
+			Actor:      actor,
+			EntityType: "character",
+			EntityID:   characterID,
+			EntityName: fmt.Sprintf("character %d", characterID),
+			Detail:     fmt.Sprintf("contact sync failed %d times in a row: %v", count, syncErr),
+		})
+	}
+}
+
 // Helper function to send JSON responses
 func sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -30,6 +68,72 @@ func sendJSONError(w http.ResponseWriter, message string, statusCode int) {
 	}
 }
 
+// contactBatch is a group of contacts added in one request, with the same standing and labels
+type contactBatch struct {
+	Standing float64
+	LabelIDs []int64
+	IDs      []int64
+}
+
+// contactBatches groups contacts by standing and by the character's contact labels whose names match a list
+// or tag of the contact, ignoring case. Labels that have not been created in game are left out.
+func contactBatches(contacts []trustedContact, labels []model.ContactLabel) []contactBatch {
+	labelIDs := make(map[string]int64, len(labels))
+	for _, label := range labels {
+		labelIDs[strings.ToLower(strings.TrimSpace(label.LabelName))] = label.LabelID
+	}
+
+	var batches []contactBatch
+	for _, contact := range contacts {
+		var ids []int64
+		for _, name := range contact.Labels {
+			if id, ok := labelIDs[strings.ToLower(name)]; ok {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		ids = slices.Compact(ids)
+
+		index := slices.IndexFunc(batches, func(batch contactBatch) bool {
+			return batch.Standing == contact.Standing && slices.Equal(batch.LabelIDs, ids)
+		})
+		if index < 0 {
+			batches = append(batches, contactBatch{Standing: contact.Standing, LabelIDs: ids})
+			index = len(batches) - 1
+		}
+		batches[index].IDs = append(batches[index].IDs, contact.ID)
+	}
+
+	slices.SortStableFunc(batches, func(a, b contactBatch) int { return cmp.Compare(a.Standing, b.Standing) })
+	return batches
+}
+
+// manageContacts returns a character's managed contacts after a sync added contacts to it. Added contacts the
+// character already had are only managed if an earlier sync added them, so its own contacts are never taken over.
+func manageContacts(managed []int64, existing []int64, added []int64) []int64 {
+	result := slices.Clone(managed)
+	for _, id := range added {
+		if !slices.Contains(existing, id) {
+			result = append(result, id)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// managedRemovals splits a character's managed contacts into those no subscribed list trusts any more, which are
+// removed, and those that are kept
+func managedRemovals(managed []int64, trusted []trustedContact) (remove []int64, keep []int64) {
+	for _, id := range managed {
+		if slices.ContainsFunc(trusted, func(contact trustedContact) bool { return contact.ID == id }) {
+			keep = append(keep, id)
+		} else {
+			remove = append(remove, id)
+		}
+	}
+	return remove, keep
+}
+
 // AddContactsHandler processes the request to add contacts to the EVE API.
 func AddContactsHandler(s *SessionService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +160,7 @@ func AddContactsHandler(s *SessionService) http.HandlerFunc {
 		}
 		sessionValues := getSessionValues(session)
 
-		token, err := persist.LoadIdentityToken(sessionValues.LoggedInUser, request.CharacterID)
+		token, err := persist.LoadIdentityToken(db, sessionValues.LoggedInUser, request.CharacterID)
 		if err != nil {
 			xlog.Logf("Error loading identity token for CharacterID %v: %v", request.CharacterID, err)
 			sendJSONError(w, fmt.Sprintf("Character token not found: %v", err), http.StatusInternalServerError)
@@ -64,30 +168,54 @@ func AddContactsHandler(s *SessionService) http.HandlerFunc {
 		}
 		xlog.Logf("Loaded token for CharacterID %v: %+v", request.CharacterID, token)
 
-		// Load trusted contacts
-		trustedData, err := persist.LoadTrustedCharacters()
+		// Collect all trusted contacts on the lists the character subscribes to
+		contacts, _, err := subscribedContacts(sessionValues, request.CharacterID)
 		if err != nil {
 			xlog.Logf("Error loading trusted contacts: %v", err)
 			sendJSONError(w, "Failed to load trusted contacts", http.StatusInternalServerError)
 			return
 		}
-		xlog.Logf("Loaded trusted contacts: %d characters, %d corporations", len(trustedData.TrustedCharacters), len(trustedData.TrustedCorporations))
+		xlog.Logf("Collected %d contact IDs to add for CharacterID %v", len(contacts), request.CharacterID)
+		if len(contacts) == 0 {
+			sendJSONResponse(w, http.StatusOK, map[string]string{"message": "No contacts to add"})
+			return
+		}
 
-		// Collect IDs of all trusted contacts
-		var contactIDs []int64
-		for _, character := range trustedData.TrustedCharacters {
-			contactIDs = append(contactIDs, character.CharacterID)
+		managed, err := db.LoadManagedContacts(request.CharacterID)
+		if err != nil {
+			xlog.Logf("Error loading managed contacts: %v", err)
+			sendJSONError(w, "Failed to load managed contacts", http.StatusInternalServerError)
+			return
 		}
-		for _, corporation := range trustedData.TrustedCorporations {
-			contactIDs = append(contactIDs, corporation.CorporationID)
+		// Contacts the character already has are its own unless an earlier sync added them. If they cannot be
+		// read nothing is recorded, so a later sync never removes a contact it did not add.
+		existing, existingErr := eveapi.GetContactIDs(request.CharacterID, &token)
+		if existingErr != nil {
+			xlog.Logf("Not recording managed contacts, failed to read contacts for CharacterID %v: %v", request.CharacterID, existingErr)
 		}
-		xlog.Logf("Collected %d contact IDs to add for CharacterID %v", len(contactIDs), request.CharacterID)
-		if len(contactIDs) == 0 {
-			sendJSONResponse(w, http.StatusOK, map[string]string{"message": "No contacts to add"})
-			return
+
+		// Labels are optional, characters that signed in before contacts could be read are synced without them
+		labels, labelErr := eveapi.GetContactLabels(request.CharacterID, &token)
+		if labelErr != nil {
+			xlog.Logf("Adding contacts without labels, failed to read contact labels for CharacterID %v: %v", request.CharacterID, labelErr)
 		}
-		// Use AddContacts to perform the API call
-		if err := eveapi.AddContacts(request.CharacterID, &token, contactIDs); err != nil {
+		// Use AddContacts to perform the API call, once for each standing and set of labels
+		var added []int64
+		for _, batch := range contactBatches(contacts, labels) {
+			var batchAdded []int64
+			batchAdded, err = eveapi.AddContacts(request.CharacterID, &token, batch.IDs, batch.Standing, batch.LabelIDs)
+			added = append(added, batchAdded...)
+			if err != nil {
+				break
+			}
+		}
+		if existingErr == nil && len(added) > 0 {
+			if saveErr := db.SaveManagedContacts(request.CharacterID, manageContacts(managed, existing, added)); saveErr != nil {
+				xlog.Logf("Error saving managed contacts for CharacterID %v: %v", request.CharacterID, saveErr)
+			}
+		}
+		recordSyncResult(request.CharacterID, sessionValues.Actor(), err)
+		if err != nil {
 			xlog.Logf("Error adding contacts for CharacterID %v: %v", request.CharacterID, err)
 			sendJSONError(w, fmt.Sprintf("Error adding contacts: %v", err), http.StatusInternalServerError)
 			return
@@ -123,7 +251,7 @@ func DeleteContactsHandler(s *SessionService) http.HandlerFunc {
 		}
 		sessionValues := getSessionValues(session)
 
-		token, err := persist.LoadIdentityToken(sessionValues.LoggedInUser, request.CharacterID)
+		token, err := persist.LoadIdentityToken(db, sessionValues.LoggedInUser, request.CharacterID)
 		if err != nil {
 			xlog.Logf("Error loading identity token for CharacterID %v: %v", request.CharacterID, err)
 			sendJSONError(w, fmt.Sprintf("Character token not found: %v", err), http.StatusInternalServerError)
@@ -131,34 +259,53 @@ func DeleteContactsHandler(s *SessionService) http.HandlerFunc {
 		}
 		xlog.Logf("Loaded token for CharacterID %v: %+v", request.CharacterID, token)
 
-		// Load untrusted contacts
-		untrustedData, err := persist.LoadTrustedCharacters()
+		// Collect the contacts the lists the character subscribes to still trust, and the untrusted ones
+		trusted, untrusted, err := subscribedContacts(sessionValues, request.CharacterID)
 		if err != nil {
 			xlog.Logf("Error loading untrusted contacts: %v", err)
 			sendJSONError(w, "Failed to load untrusted contacts", http.StatusInternalServerError)
 			return
 		}
-		xlog.Logf("Loaded untrusted contacts: %d characters, %d corporations", len(untrustedData.UntrustedCharacters), len(untrustedData.UntrustedCorporations))
+		managed, err := db.LoadManagedContacts(request.CharacterID)
+		if err != nil {
+			xlog.Logf("Error loading managed contacts: %v", err)
+			sendJSONError(w, "Failed to load managed contacts", http.StatusInternalServerError)
+			return
+		}
 
-		// Collect IDs of all untrusted contacts
-		var contactIDs []int64
-		for _, character := range untrustedData.UntrustedCharacters {
-			contactIDs = append(contactIDs, character.CharacterID)
+		// Only contacts an earlier sync added are removed, the character's own contacts are left alone even if untrusted
+		contactIDs, keep := managedRemovals(managed, trusted)
+		skipped := 0
+		for _, id := range untrusted {
+			if !slices.Contains(managed, id) {
+				skipped++
+			}
 		}
-		for _, corporation := range untrustedData.UntrustedCorporations {
-			contactIDs = append(contactIDs, corporation.CorporationID)
+		xlog.Logf("Collected %d contact IDs to delete for CharacterID %v, leaving %d untrusted contacts it added itself", len(contactIDs), request.CharacterID, skipped)
+		if len(contactIDs) == 0 {
+			sendJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "No contacts to delete", "deleted": 0, "skipped": skipped})
+			return
 		}
-		xlog.Logf("Collected %d contact IDs to delete for CharacterID %v", len(contactIDs), request.CharacterID)
 
-		// Use DeleteContacts to perform the API call
-		if err := eveapi.DeleteContacts(request.CharacterID, &token, contactIDs); err != nil {
-			xlog.Logf("Error deleting contacts for CharacterID %v: %v", request.CharacterID, err)
+		// Use DeleteContacts to perform the API call. Contacts it did not get to stay in the ledger so a later sync removes them.
+		deleted, err := eveapi.DeleteContacts(request.CharacterID, &token, contactIDs)
+		for _, id := range contactIDs {
+			if !slices.Contains(deleted, id) {
+				keep = append(keep, id)
+			}
+		}
+		if saveErr := db.SaveManagedContacts(request.CharacterID, keep); saveErr != nil {
+			xlog.Logf("Error saving managed contacts for CharacterID %v: %v", request.CharacterID, saveErr)
+		}
+		recordSyncResult(request.CharacterID, sessionValues.Actor(), err)
+		if err != nil {
+			xlog.Logf("Error deleting contacts for CharacterID %v after deleting %d: %v", request.CharacterID, len(deleted), err)
 			sendJSONError(w, fmt.Sprintf("Error deleting contacts: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Send success response
 		xlog.Logf("Contacts deleted successfully for CharacterID %v", request.CharacterID)
-		sendJSONResponse(w, http.StatusOK, map[string]string{"message": "Contacts deleted successfully"})
+		sendJSONResponse(w, http.StatusOK, map[string]interface{}{"message": "Contacts deleted successfully", "deleted": len(contactIDs), "skipped": skipped})
 	}
 }
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/notify"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// recordEvents writes events to the audit log and sends them to the configured webhooks
+func recordEvents(events ...model.AuditEvent) {
+	for i := range events {
+		if events[i].Time.IsZero() {
+			events[i].Time = time.Now()
+		}
+	}
+
+	if err := db.AppendAuditEvents(events...); err != nil {
+		xlog.Logf("Failed to record audit events: %v", err)
+	}
+	notify.Notify(events...)
+}
+
+// entryEvent builds an event for a change to a trust list entry
+func entryEvent(eventType, actor, list, trustStatus, entityType string, id int64, name, detail string) model.AuditEvent {
+	if name == "" {
+		name = fmt.Sprintf("%s %d", entityType, id)
+	}
+	if detail == "" {
+		detail = fmt.Sprintf("%s %s", trustStatus, entityType)
+	}
+	return model.AuditEvent{
+		Type:       eventType,
+		Actor:      actor,
+		EntityType: entityType,
+		EntityID:   id,
+		EntityName: name,
+		Detail:     detail,
+		List:       list,
+	}
+}
+
+// onList sets the list events happened on
+func onList(list string, events []model.AuditEvent) []model.AuditEvent {
+	for i := range events {
+		events[i].List = list
+	}
+	return events
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// Export formats
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+	exportText = "txt"
+)
+
+// exportColumns is the CSV header, which the importer reads back by ID
+var exportColumns = []string{"status", "type", "id", "name", "corporation_id", "corporation_name", "alliance_id", "alliance_name", "added_by", "date_added", "comment", "standing", "expires_at", "tags"}
+
+// ExportHandler downloads a trust list as CSV, as JSON in the shape it is stored in, or as text with
+// one name per line. The status and type query parameters limit the export to one kind of entry.
+func ExportHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, false)
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = exportCSV
+		}
+		if format != exportCSV && format != exportJSON && format != exportText {
+			sendJSONError(w, "format must be csv, json or txt", http.StatusBadRequest)
+			return
+		}
+		status, entityType := query.Get("status"), query.Get("type")
+		if status != "" && status != trust.StatusTrusted && status != trust.StatusUntrusted {
+			sendJSONError(w, "status must be trusted or untrusted", http.StatusBadRequest)
+			return
+		}
+		if entityType != "" && entityType != trust.TypeCharacter && entityType != trust.TypeCorporation && entityType != trust.TypeAlliance {
+			sendJSONError(w, "type must be character, corporation or alliance", http.StatusBadRequest)
+			return
+		}
+
+		list, err := authorizeList(sessionValues, requestList(query.Get("list")), false)
+		if err != nil {
+			sendJSONError(w, err.Error(), listErrorStatus(err))
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading trusted characters for export: %v", err)
+			sendJSONError(w, "Error loading trusted characters", http.StatusInternalServerError)
+			return
+		}
+		trustedData = filterTrustedData(trustedData, status, entityType)
+
+		filename := fmt.Sprintf("%s-%s.%s", list.Name, time.Now().UTC().Format("2006-01-02"), format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		switch format {
+		case exportJSON:
+			w.Header().Set("Content-Type", "application/json")
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(trustedData)
+		case exportText:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, entry := range trust.Entries(trustedData) {
+				if _, err = fmt.Fprintln(w, entry.Name); err != nil {
+					break
+				}
+			}
+		default:
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = writeExportCSV(w, trust.Entries(trustedData))
+		}
+		if err != nil {
+			xlog.Logf("Error writing %s export of list %s: %v", format, list.Name, err)
+		}
+	}
+}
+
+// filterTrustedData returns only the entries with the given status and type, either of which may be empty to keep all
+func filterTrustedData(trustedData *model.TrustedCharacters, status string, entityType string) *model.TrustedCharacters {
+	keep := func(entryStatus, entryType string) bool {
+		return (status == "" || status == entryStatus) && (entityType == "" || entityType == entryType)
+	}
+
+	filtered := &model.TrustedCharacters{Revision: trustedData.Revision}
+	if keep(trust.StatusTrusted, trust.TypeCharacter) {
+		filtered.TrustedCharacters = trustedData.TrustedCharacters
+	}
+	if keep(trust.StatusTrusted, trust.TypeCorporation) {
+		filtered.TrustedCorporations = trustedData.TrustedCorporations
+	}
+	if keep(trust.StatusTrusted, trust.TypeAlliance) {
+		filtered.TrustedAlliances = trustedData.TrustedAlliances
+	}
+	if keep(trust.StatusUntrusted, trust.TypeCharacter) {
+		filtered.UntrustedCharacters = trustedData.UntrustedCharacters
+	}
+	if keep(trust.StatusUntrusted, trust.TypeCorporation) {
+		filtered.UntrustedCorporations = trustedData.UntrustedCorporations
+	}
+	if keep(trust.StatusUntrusted, trust.TypeAlliance) {
+		filtered.UntrustedAlliances = trustedData.UntrustedAlliances
+	}
+	return filtered
+}
+
+// writeExportCSV writes entries as CSV with a header row. Standing is left empty for entries using the default,
+// and tags are comma separated.
+func writeExportCSV(w http.ResponseWriter, entries []model.TrustEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+
+	optionalID := func(id int64) string {
+		if id == 0 {
+			return ""
+		}
+		return strconv.FormatInt(id, 10)
+	}
+	for _, entry := range entries {
+		standing, expiresAt := "", ""
+		if entry.Standing != nil {
+			standing = strconv.FormatFloat(*entry.Standing, 'f', -1, 64)
+		}
+		if entry.ExpiresAt != nil {
+			expiresAt = entry.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		record := []string{
+			entry.Status,
+			entry.Type,
+			strconv.FormatInt(entry.ID, 10),
+			entry.Name,
+			optionalID(entry.CorporationID),
+			entry.CorporationName,
+			optionalID(entry.AllianceID),
+			entry.AllianceName,
+			entry.AddedBy,
+			entry.DateAdded.UTC().Format(time.RFC3339),
+			entry.Comment,
+			standing,
+			expiresAt,
+			strings.Join(entry.Tags, ","),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
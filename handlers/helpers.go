@@ -15,6 +15,7 @@ import (
 	"github.com/gambtho/whototrust/model"
 	"github.com/gambtho/whototrust/persist"
 	"github.com/gambtho/whototrust/store"
+	"github.com/gambtho/whototrust/trust"
 	"github.com/gambtho/whototrust/xlog"
 )
 
@@ -28,6 +29,14 @@ var (
 
 const Title = "Who to Trust?"
 
+// db is the storage backend used by every handler
+var db persist.Store
+
+// SetStore sets the storage backend used by the handlers
+func SetStore(st persist.Store) {
+	db = st
+}
+
 func sameIdentities(users []int64, identities map[int64]model.CharacterData) bool {
 	var identitiesKeys []int64
 	for k, _ := range identities {
@@ -101,8 +110,9 @@ func clearSession(s *SessionService, w http.ResponseWriter, r *http.Request) {
 		xlog.Logf("Failed to get session to clear: %v", err)
 	}
 
-	// Clear the session
+	// Clear the session and delete it from the store
 	session.Values = make(map[interface{}]interface{})
+	session.Options.MaxAge = -1
 
 	// Save the session
 	err = sessions.Save(r, w)
@@ -140,7 +150,7 @@ func validateIdentities(session *sessions.Session, sessionValues SessionValues,
 	needIdentityPopulation := len(authenticatedUsers) == 0 || !sameIdentities(authenticatedUsers, storeData.Identities) || time.Since(time.Unix(sessionValues.LastRefreshTime, 0)) > 15*time.Minute
 
 	if needIdentityPopulation {
-		userConfig, err := persist.LoadIdentities(sessionValues.LoggedInUser)
+		userConfig, err := db.LoadIdentities(sessionValues.LoggedInUser)
 
 		if err != nil {
 			xlog.Logf("Failed to load identities: %v", err)
@@ -156,11 +166,12 @@ func validateIdentities(session *sessions.Session, sessionValues SessionValues,
 			return nil, fmt.Errorf("not a valid user - ask in discord if you think this is a mistake")
 		}
 
-		if err = persist.SaveIdentities(sessionValues.LoggedInUser, userConfig); err != nil {
+		if err = db.SaveIdentities(sessionValues.LoggedInUser, userConfig); err != nil {
 			return nil, fmt.Errorf("failed to save identities: %w", err)
 		}
 
 		session.Values[allAuthenticatedCharacters] = getAuthenticatedCharacterIDs(identities)
+		updateSessionAffiliation(session, sessionValues.LoggedInUser)
 		session.Values[lastRefreshTime] = time.Now().Unix()
 	}
 
@@ -175,51 +186,82 @@ func getAuthenticatedCharacterIDs(identities map[int64]model.CharacterData) []in
 	return authenticatedCharacters
 }
 
-func prepareHomeData(sessionValues SessionValues, identities map[int64]model.CharacterData) model.HomeData {
-	trustedCharacters, err := persist.LoadTrustedCharacters()
+func prepareHomeData(sessionValues SessionValues, identities map[int64]model.CharacterData, listName string) (model.HomeData, error) {
+	list, err := authorizeList(sessionValues, listName, false)
+	if err != nil {
+		return model.HomeData{}, err
+	}
+
+	lists, err := db.LoadTrustLists()
+	if err != nil {
+		return model.HomeData{}, fmt.Errorf("failed to load trust lists: %w", err)
+	}
+	var visible []model.TrustList
+	for _, l := range lists {
+		if canViewList(l, sessionValues) {
+			visible = append(visible, l)
+		}
+	}
+
+	trustedCharacters, err := db.LoadTrustedCharacters(list.Name)
 	if err != nil {
 		xlog.Logf("Error loading trusted characters %v", err)
+		trustedCharacters = &model.TrustedCharacters{}
+	}
+
+	subscriptions, err := db.LoadSubscriptions(sessionValues.LoggedInUser)
+	if err != nil {
+		xlog.Logf("Error loading subscriptions %v", err)
 	}
 
 	return model.HomeData{
 		Title:                 Title,
 		LoggedIn:              true,
 		Identities:            identities,
-		TabulatorIdentities:   convertIdentitiesToTabulatorData(identities),
+		TabulatorIdentities:   convertIdentitiesToTabulatorData(identities, trustedCharacters, subscriptions, list.Name),
 		MainIdentity:          sessionValues.LoggedInUser,
 		TrustedCharacters:     trustedCharacters.TrustedCharacters,
 		TrustedCorporations:   trustedCharacters.TrustedCorporations,
 		UntrustedCharacters:   trustedCharacters.UntrustedCharacters,
 		UntrustedCorporations: trustedCharacters.UntrustedCorporations,
-	}
+		TrustedAlliances:      nonNil(trustedCharacters.TrustedAlliances),
+		UntrustedAlliances:    nonNil(trustedCharacters.UntrustedAlliances),
+		Conflicts:             trust.FindConflicts(trustedCharacters),
+		Lists:                 visible,
+		CurrentList:           list,
+		CanEdit:               canEditList(list, sessionValues),
+	}, nil
 }
 
-func isTrusted(character model.CharacterData) bool {
-	trustedCharacters, _ := persist.LoadTrustedCharacters()
-
-	for _, char := range trustedCharacters.TrustedCharacters {
-		if char.CharacterID == character.CharacterID {
-			return true
-		}
+// nonNil returns an empty slice for nil, so the home page gets an empty table rather than null
+func nonNil[T any](entries []T) []T {
+	if entries == nil {
+		return []T{}
 	}
-	for _, corp := range trustedCharacters.TrustedCorporations {
-		if corp.CorporationID == character.CorporationID {
-			return true
-		}
-	}
-	return false
+	return entries
+}
+
+// isTrusted reports whether the list trusts a character, directly or through its corporation or alliance
+func isTrusted(character model.CharacterData, index *trust.Index) bool {
+	verdict := index.Classify(model.CharacterAffiliation{CharacterID: character.CharacterID, CorporationID: character.CorporationID, AllianceID: character.AllianceID})
+	return verdict.Verdict == trust.VerdictTrusted
 }
 
-func convertIdentitiesToTabulatorData(identities map[int64]model.CharacterData) []map[string]interface{} {
+func convertIdentitiesToTabulatorData(identities map[int64]model.CharacterData, trustedCharacters *model.TrustedCharacters, subscriptions map[int64][]model.Subscription, list string) []map[string]interface{} {
 	var tabulatorData []map[string]interface{}
 
+	index := trust.NewIndex(trustedCharacters)
 	for id, characterData := range identities {
+		subscription, subscribed := persist.FindSubscription(subscriptions, id, list)
 		row := map[string]interface{}{
-			"CharacterID":   characterData.CharacterID,
-			"CharacterName": characterData.CharacterName,
-			"Portrait":      characterData.Portrait,
-			"IsTrusted":     isTrusted(identities[id]),
-			"CorporationID": characterData.CorporationID,
+			"CharacterID":    characterData.CharacterID,
+			"CharacterName":  characterData.CharacterName,
+			"Portrait":       characterData.Portrait,
+			"IsTrusted":      isTrusted(identities[id], index),
+			"CorporationID":  characterData.CorporationID,
+			"AllianceID":     characterData.AllianceID,
+			"Subscribed":     subscribed,
+			"SubscribedTags": subscription.Tags,
 		}
 		tabulatorData = append(tabulatorData, row)
 	}
@@ -30,7 +30,11 @@ func HomeHandler(s *SessionService) http.HandlerFunc {
 			return
 		}
 
-		data := prepareHomeData(sessionValues, identities)
+		data, err := prepareHomeData(getSessionValues(session), identities, requestList(r.URL.Query().Get("list")))
+		if err != nil {
+			handleErrorWithRedirect(w, r, fmt.Sprintf("Failed to load list: %v", err), "/")
+			return
+		}
 
 		etag, err = updateStoreAndSession(storeData, data, etag, session, r, w)
 		if err != nil {
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/gambtho/whototrust/importer"
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// maxImportBytes limits the size of an import request
+const maxImportBytes = 2 << 20
+
+// importRequest is the body of an import request. Status, Type, Comment and Standing apply to rows that do not set their own.
+type importRequest struct {
+	Format   string   `json:"format"`
+	Data     string   `json:"data"`
+	Status   string   `json:"status"`
+	Type     string   `json:"type"`
+	Comment  string   `json:"comment"`
+	Standing *float64 `json:"standing"`
+	// Commit adds the entries, otherwise the import is only previewed
+	Commit bool `json:"commit"`
+}
+
+// ImportResponse reports what happened, or would happen, to each row of an import.
+type ImportResponse struct {
+	List      string         `json:"list"`
+	Format    string         `json:"format"`
+	Committed bool           `json:"committed"`
+	Added     int            `json:"added"`
+	Summary   map[string]int `json:"summary"`
+	Rows      []importer.Row `json:"rows"`
+}
+
+// APIImportHandler previews or commits a bulk import of names or IDs into a list.
+func APIImportHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValues, ok := authenticateAPI(s, w, r, true)
+		if !ok {
+			return
+		}
+
+		list, err := authorizeList(sessionValues, mux.Vars(r)["list"], true)
+		if err != nil {
+			writeAPIListError(w, err)
+			return
+		}
+
+		var request importRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes)).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, "Invalid request payload, imports are limited to 2MB")
+			return
+		}
+		if request.Status == "" {
+			request.Status = trust.StatusTrusted
+		}
+		if err := validStanding(request.Standing); err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+		if request.Format == "" {
+			request.Format = importer.DetectFormat(request.Data)
+		}
+
+		rows, err := importer.Parse(request.Format, request.Data, importer.Defaults{
+			Status:   request.Status,
+			Type:     request.Type,
+			Comment:  request.Comment,
+			Standing: request.Standing,
+		})
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiBadRequest, err.Error())
+			return
+		}
+
+		if err := importer.Resolve(rows); err != nil {
+			xlog.Logf("Error resolving import: %v", err)
+			writeAPIError(w, http.StatusBadGateway, apiInternal, "Failed to look up names and IDs, try again later")
+			return
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			xlog.Logf("Error loading list %s: %v", list.Name, err)
+			writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to load trust list entries")
+			return
+		}
+		importer.Check(rows, trustedData)
+
+		response := ImportResponse{List: list.Name, Format: request.Format, Rows: rows}
+		if request.Commit {
+			previousConflicts := currentConflicts(list.Name)
+			added, err := persist.AddEntries(db, list.Name, importer.Entries(rows, sessionValues.Actor(), time.Now()))
+			if err != nil {
+				xlog.Logf("Error importing into list %s: %v", list.Name, err)
+				writeAPIError(w, http.StatusInternalServerError, apiInternal, "Failed to save imported entries")
+				return
+			}
+			markSkipped(rows, added)
+
+			events := make([]model.AuditEvent, 0, len(added))
+			for _, entry := range added {
+				events = append(events, entryEvent(model.EventEntryAdded, sessionValues.Actor(), list.Name, entry.Status, entry.Type, entry.ID, entry.Name, "imported "+entry.Status+" "+entry.Type))
+			}
+			events = append(events, onList(list.Name, trust.ConflictEvents(previousConflicts, currentConflicts(list.Name), sessionValues.Actor()))...)
+			recordEvents(events...)
+
+			xlog.Logf("%s imported %d entries into list %s", sessionValues.Actor(), len(added), list.Name)
+			response.Committed = true
+			response.Added = len(added)
+		}
+		response.Summary = importer.Summarize(rows)
+
+		writeJSONResponse(w, response, http.StatusOK)
+	}
+}
+
+// markSkipped marks rows that were to be added but were not, because someone added them in the meantime.
+func markSkipped(rows []importer.Row, added []model.TrustEntry) {
+	type entryKey struct {
+		status, entityType string
+		id                 int64
+	}
+	wasAdded := make(map[entryKey]bool, len(added))
+	for _, entry := range added {
+		wasAdded[entryKey{entry.Status, entry.Type, entry.ID}] = true
+	}
+	for i := range rows {
+		if rows[i].Result == importer.ResultAdd && !wasAdded[entryKey{rows[i].Status, rows[i].Type, rows[i].ID}] {
+			rows[i].Result = importer.ResultExists
+			rows[i].Message = "added by someone else while importing"
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+var errListReadOnly = errors.New("you cannot edit this list")
+
+// TrustListSummary is a list definition along with what the logged in user may do with it.
+type TrustListSummary struct {
+	model.TrustList
+	CanEdit   bool `json:"can_edit"`
+	CanManage bool `json:"can_manage"`
+}
+
+// listRequest is the body of a request to create or change a list.
+type listRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Access      string  `json:"access"`
+	Viewers     []int64 `json:"viewers"`
+	Editors     []int64 `json:"editors"`
+}
+
+// requestList returns the list a request is for, the default list if none was given.
+func requestList(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return model.DefaultList
+	}
+	return name
+}
+
+// updateSessionAffiliation records the corporation and alliance of the logged in user, used for list access.
+func updateSessionAffiliation(session *sessions.Session, characterID int64) {
+	affiliations, err := eveapi.GetAffiliations([]int64{characterID})
+	if err != nil || len(affiliations) == 0 {
+		xlog.Logf("Failed to get affiliation of %d: %v", characterID, err)
+		return
+	}
+	session.Values[loggedInCorporation] = affiliations[0].CorporationID
+	session.Values[loggedInAlliance] = affiliations[0].AllianceID
+}
+
+// matchesUser reports whether any of ids is the logged in user or their corporation or alliance.
+func matchesUser(ids []int64, sessionValues SessionValues) bool {
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+		if id == sessionValues.LoggedInUser || id == sessionValues.LoggedInCorporation || id == sessionValues.LoggedInAlliance {
+			return true
+		}
+	}
+	return false
+}
+
+// canManageList reports whether the user may change a list's settings or delete it.
+func canManageList(list model.TrustList, sessionValues SessionValues) bool {
+	return adminIDs[sessionValues.LoggedInUser] || (list.Owner != 0 && list.Owner == sessionValues.LoggedInUser)
+}
+
+// canViewList reports whether the user may see a list and subscribe to it.
+func canViewList(list model.TrustList, sessionValues SessionValues) bool {
+	if list.Access != model.AccessRestricted || canManageList(list, sessionValues) {
+		return true
+	}
+	return matchesUser(list.Viewers, sessionValues) || matchesUser(list.Editors, sessionValues)
+}
+
+// canEditList reports whether the user may add, remove and comment on entries. Anyone who can see
+// a list with no editors may edit it.
+func canEditList(list model.TrustList, sessionValues SessionValues) bool {
+	if canManageList(list, sessionValues) {
+		return true
+	}
+	if !canViewList(list, sessionValues) {
+		return false
+	}
+	return len(list.Editors) == 0 || matchesUser(list.Editors, sessionValues)
+}
+
+// authorizeList returns the named list if the user may see it, or may edit it when edit is set.
+func authorizeList(sessionValues SessionValues, name string, edit bool) (model.TrustList, error) {
+	list, err := persist.FindTrustList(db, name)
+	if err != nil {
+		return list, err
+	}
+	if !canViewList(list, sessionValues) {
+		// Restricted lists look the same as missing ones to users who cannot see them
+		return list, persist.ErrListNotFound
+	}
+	if edit && !canEditList(list, sessionValues) {
+		return list, errListReadOnly
+	}
+	return list, nil
+}
+
+// listErrorStatus returns the response status for an error from authorizeList.
+func listErrorStatus(err error) int {
+	switch err {
+	case persist.ErrListNotFound:
+		return http.StatusNotFound
+	case errListReadOnly:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// visibleLists returns the lists the user can see, the default list first.
+func visibleLists(sessionValues SessionValues) ([]TrustListSummary, error) {
+	lists, err := db.LoadTrustLists()
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []TrustListSummary{}
+	for _, list := range lists {
+		if !canViewList(list, sessionValues) {
+			continue
+		}
+		visible = append(visible, TrustListSummary{
+			TrustList: list,
+			CanEdit:   canEditList(list, sessionValues),
+			CanManage: canManageList(list, sessionValues),
+		})
+	}
+	return visible, nil
+}
+
+// validateListRequest checks the access settings of a list request.
+func validateListRequest(request *listRequest) error {
+	request.Description = strings.TrimSpace(request.Description)
+	switch request.Access {
+	case "":
+		request.Access = model.AccessPublic
+	case model.AccessPublic, model.AccessRestricted:
+	default:
+		return fmt.Errorf("access must be %s or %s", model.AccessPublic, model.AccessRestricted)
+	}
+	return nil
+}
+
+// listEvent builds an event for a change to a list's settings.
+func listEvent(eventType string, actor string, list model.TrustList, detail string) model.AuditEvent {
+	return model.AuditEvent{
+		Type:       eventType,
+		Actor:      actor,
+		EntityType: "list",
+		EntityName: list.Name,
+		Detail:     detail,
+		List:       list.Name,
+	}
+}
+
+// ListsHandler returns the lists the logged in user can see.
+func ListsHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		lists, err := visibleLists(sessionValues)
+		if err != nil {
+			xlog.Logf("Error loading trust lists: %v", err)
+			sendJSONError(w, "Error loading trust lists", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, lists)
+	}
+}
+
+// CreateListHandler creates a new list owned by the logged in user.
+func CreateListHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request listRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		request.Name = strings.ToLower(strings.TrimSpace(request.Name))
+		if !persist.ValidListName(request.Name) {
+			sendJSONError(w, "List names must be 1-32 lowercase letters, digits or dashes", http.StatusBadRequest)
+			return
+		}
+		if err := validateListRequest(&request); err != nil {
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		list := model.TrustList{
+			Name:        request.Name,
+			Description: request.Description,
+			Owner:       sessionValues.LoggedInUser,
+			OwnerName:   sessionValues.LoggedInUserName,
+			Access:      request.Access,
+			Viewers:     request.Viewers,
+			Editors:     request.Editors,
+			CreatedAt:   time.Now(),
+		}
+
+		err = persist.CreateTrustList(db, list)
+		switch {
+		case err == persist.ErrListExists:
+			sendJSONError(w, "A list with that name already exists", http.StatusConflict)
+			return
+		case err != nil:
+			xlog.Logf("Error creating trust list: %v", err)
+			sendJSONError(w, "Error creating list", http.StatusInternalServerError)
+			return
+		}
+
+		recordEvents(listEvent(model.EventListCreated, sessionValues.Actor(), list, fmt.Sprintf("%s list created", list.Access)))
+
+		sendJSONResponse(w, http.StatusCreated, TrustListSummary{TrustList: list, CanEdit: true, CanManage: true})
+	}
+}
+
+// UpdateListHandler changes the description and access rules of a list. Only the owner or an admin may do this.
+func UpdateListHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request listRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if err := validateListRequest(&request); err != nil {
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		list, err := authorizeList(sessionValues, requestList(request.Name), false)
+		if err != nil {
+			sendJSONError(w, err.Error(), listErrorStatus(err))
+			return
+		}
+		if !canManageList(list, sessionValues) {
+			sendJSONError(w, "Only the list owner can change its settings", http.StatusForbidden)
+			return
+		}
+
+		list.Description = request.Description
+		list.Access = request.Access
+		list.Viewers = request.Viewers
+		list.Editors = request.Editors
+
+		if err := persist.SaveTrustList(db, list); err != nil {
+			xlog.Logf("Error saving trust list: %v", err)
+			sendJSONError(w, "Error saving list", http.StatusInternalServerError)
+			return
+		}
+
+		recordEvents(listEvent(model.EventListUpdated, sessionValues.Actor(), list,
+			fmt.Sprintf("%s, %d viewers, %d editors", list.Access, len(list.Viewers), len(list.Editors))))
+
+		sendJSONResponse(w, http.StatusOK, TrustListSummary{TrustList: list, CanEdit: true, CanManage: true})
+	}
+}
+
+// DeleteListHandler deletes a list and its entries. Only the owner or an admin may do this.
+func DeleteListHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Name == "" {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		list, err := authorizeList(sessionValues, request.Name, false)
+		if err != nil {
+			sendJSONError(w, err.Error(), listErrorStatus(err))
+			return
+		}
+		if !canManageList(list, sessionValues) {
+			sendJSONError(w, "Only the list owner can delete it", http.StatusForbidden)
+			return
+		}
+
+		err = db.DeleteTrustList(list.Name)
+		switch {
+		case err == persist.ErrDefaultList:
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			xlog.Logf("Error deleting trust list: %v", err)
+			sendJSONError(w, "Error deleting list", http.StatusInternalServerError)
+			return
+		}
+
+		recordEvents(listEvent(model.EventListDeleted, sessionValues.Actor(), list, "list deleted"))
+
+		sendJSONResponse(w, http.StatusOK, map[string]string{"message": "List deleted"})
+	}
+}
+
+// SubscribeHandler sets whether one of the logged in user's characters syncs its contacts from a list,
+// and which tags it is limited to.
+func SubscribeHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			CharacterID int64    `json:"characterID"`
+			List        string   `json:"list"`
+			Subscribed  bool     `json:"subscribed"`
+			Tags        []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CharacterID == 0 {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := persist.LoadIdentityToken(db, sessionValues.LoggedInUser, request.CharacterID); err != nil {
+			sendJSONError(w, "Character not found", http.StatusNotFound)
+			return
+		}
+
+		list, err := authorizeList(sessionValues, requestList(request.List), false)
+		if err != nil {
+			sendJSONError(w, err.Error(), listErrorStatus(err))
+			return
+		}
+
+		tags, err := trust.NormalizeTags(request.Tags)
+		if err != nil {
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		subscriptions, err := db.LoadSubscriptions(sessionValues.LoggedInUser)
+		if err != nil {
+			xlog.Logf("Error loading subscriptions: %v", err)
+			sendJSONError(w, "Error loading subscriptions", http.StatusInternalServerError)
+			return
+		}
+
+		subscribed := slices.DeleteFunc(slices.Clone(persist.SubscribedLists(subscriptions, request.CharacterID)), func(subscription model.Subscription) bool {
+			return subscription.List == list.Name
+		})
+		if request.Subscribed {
+			subscribed = append(subscribed, model.Subscription{List: list.Name, Tags: tags})
+		}
+		subscriptions[request.CharacterID] = subscribed
+
+		if err := db.SaveSubscriptions(sessionValues.LoggedInUser, subscriptions); err != nil {
+			xlog.Logf("Error saving subscriptions: %v", err)
+			sendJSONError(w, "Error saving subscriptions", http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, http.StatusOK, map[string]interface{}{"characterID": request.CharacterID, "lists": subscribed})
+	}
+}
+
+// trustedContact is a contact to add, with its standing and the names of the contact labels to add it with
+type trustedContact struct {
+	ID       int64
+	Standing float64
+	// Labels are the lists the contact was synced from and its tags, in lowercase
+	Labels []string
+}
+
+// subscribedContacts returns the contacts a character should be synced to from every list it subscribes to and
+// can still see. Subscriptions limited to tags only sync the trusted entries with one of those tags. An ID
+// untrusted on any of those lists is never returned as trusted, and an ID trusted on several lists takes its
+// standing from the first one and is labelled with all of them. Entries that have expired are left out, so the
+// next sync removes their contacts if it added them.
+func subscribedContacts(sessionValues SessionValues, characterID int64) (trusted []trustedContact, untrusted []int64, err error) {
+	subscriptions, err := db.LoadSubscriptions(sessionValues.LoggedInUser)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load subscriptions: %v", err)
+	}
+
+	lists, err := db.LoadTrustLists()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load trust lists: %v", err)
+	}
+
+	now := time.Now()
+	untrustedIDs := make(map[int64]bool)
+	var trustedEntries []model.TrustEntry
+	labels := make(map[int64][]string)
+	for _, subscription := range persist.SubscribedLists(subscriptions, characterID) {
+		index := slices.IndexFunc(lists, func(list model.TrustList) bool { return list.Name == subscription.List })
+		if index < 0 || !canViewList(lists[index], sessionValues) {
+			continue
+		}
+
+		trustedData, err := db.LoadTrustedCharacters(subscription.List)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load list %s: %v", subscription.List, err)
+		}
+
+		for _, entry := range trust.Entries(trustedData) {
+			if entry.ExpiresAt != nil && !entry.ExpiresAt.After(now) {
+				continue
+			}
+			if entry.Status == trust.StatusUntrusted {
+				untrustedIDs[entry.ID] = true
+				continue
+			}
+			if trust.HasAnyTag(entry.Tags, subscription.Tags) {
+				trustedEntries = append(trustedEntries, entry)
+				labels[entry.ID] = append(labels[entry.ID], subscription.List)
+				labels[entry.ID] = append(labels[entry.ID], entry.Tags...)
+			}
+		}
+	}
+
+	seen := make(map[int64]bool)
+	for _, entry := range trustedEntries {
+		if untrustedIDs[entry.ID] || seen[entry.ID] {
+			continue
+		}
+		seen[entry.ID] = true
+		contactLabels := labels[entry.ID]
+		slices.Sort(contactLabels)
+		trusted = append(trusted, trustedContact{ID: entry.ID, Standing: entryStanding(entry), Labels: slices.Compact(contactLabels)})
+	}
+	for id := range untrustedIDs {
+		untrusted = append(untrusted, id)
+	}
+	slices.Sort(untrusted)
+
+	return trusted, untrusted, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gambtho/whototrust/persist"
+)
+
+// RevisionConflictResponse is returned with a 409 when an entry was changed by someone else.
+// Current is the entry as it is now stored, or null if it has been removed.
+type RevisionConflictResponse struct {
+	Error   string      `json:"error"`
+	Current interface{} `json:"current"`
+}
+
+// requestRevision returns the entry revision a client expects, taken from the request body or an If-Match header.
+// Requests that give neither are not checked.
+func requestRevision(r *http.Request, bodyRevision *int64) (int64, error) {
+	if bodyRevision != nil {
+		return *bodyRevision, nil
+	}
+
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return persist.AnyRevision, nil
+	}
+
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, fmt.Errorf("invalid If-Match revision: %s", value)
+	}
+
+	return revision, nil
+}
+
+// writeRevisionConflict responds with 409 and the current state of the entry.
+func writeRevisionConflict(w http.ResponseWriter, list string, trustStatus string, entityType string, id int64) {
+	current, _ := lookupEntry(list, trustStatus, entityType, id)
+	writeJSONResponse(w, RevisionConflictResponse{
+		Error:   "This entry was changed by someone else, reload to see the latest version",
+		Current: current,
+	}, http.StatusConflict)
+}
+
+// setRevisionHeader sets the ETag of a response to an entry revision.
+func setRevisionHeader(w http.ResponseWriter, revision int64) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, revision))
+}
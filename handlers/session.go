@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"crypto/rand"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/sessions"
 )
@@ -11,6 +12,9 @@ const (
 	lastRefreshTime            = "last_refresh"
 	allAuthenticatedCharacters = "authenticated_characters"
 	loggedInUser               = "logged_in_user"
+	loggedInUserName           = "logged_in_user_name"
+	loggedInCorporation        = "logged_in_corporation"
+	loggedInAlliance           = "logged_in_alliance"
 	sessionName                = "session"
 	previousUserCount          = "previous_user_count"
 	previousInputSubbmited     = "previous_input_submitted"
@@ -20,13 +24,18 @@ const (
 type SessionValues struct {
 	LastRefreshTime        int64
 	LoggedInUser           int64
+	LoggedInUserName       string
+	LoggedInCorporation    int64
+	LoggedInAlliance       int64
 	PreviousUserCount      int
 	PreviousInputSubmitted string
 	PreviousEtagUsed       string
+	// APIToken is the name of the API token a JSON API request was made with, empty for browser sessions
+	APIToken string
 }
 
 type SessionService struct {
-	store *sessions.CookieStore
+	store *ServerStore
 }
 
 func getSessionValues(session *sessions.Session) SessionValues {
@@ -36,6 +45,18 @@ func getSessionValues(session *sessions.Session) SessionValues {
 		s.LoggedInUser = val
 	}
 
+	if val, ok := session.Values[loggedInUserName].(string); ok {
+		s.LoggedInUserName = val
+	}
+
+	if val, ok := session.Values[loggedInCorporation].(int64); ok {
+		s.LoggedInCorporation = val
+	}
+
+	if val, ok := session.Values[loggedInAlliance].(int64); ok {
+		s.LoggedInAlliance = val
+	}
+
 	if val, ok := session.Values[previousUserCount].(int); ok {
 		s.PreviousUserCount = val
 	}
@@ -55,20 +76,26 @@ func getSessionValues(session *sessions.Session) SessionValues {
 	return s
 }
 
-func NewSessionService(secret string) *SessionService {
+// Actor returns the name recorded against changes made by the logged in user
+func (s SessionValues) Actor() string {
+	actor := s.LoggedInUserName
+	if actor == "" {
+		actor = fmt.Sprintf("character %d", s.LoggedInUser)
+	}
+	if s.APIToken != "" {
+		actor = fmt.Sprintf("%s (API token %q)", actor, s.APIToken)
+	}
+	return actor
+}
+
+// NewSessionService creates a server side session store whose token cookie is protected by pairs of
+// authentication and encryption keys. The first pair protects new cookies, later pairs are only used to read existing ones.
+func NewSessionService(idleTimeout time.Duration, maxAge time.Duration, keyPairs ...[]byte) *SessionService {
 	return &SessionService{
-		store: sessions.NewCookieStore([]byte(secret)),
+		store: NewServerStore(idleTimeout, maxAge, keyPairs...),
 	}
 }
 
 func (s *SessionService) Get(r *http.Request, name string) (*sessions.Session, error) {
 	return s.store.Get(r, name)
 }
-
-func GenerateSecret() ([]byte, error) {
-	key := make([]byte, 32)
-	if _, err := rand.Read(key); err != nil {
-		return nil, err
-	}
-	return key, nil
-}
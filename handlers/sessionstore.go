@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// lastSeenInterval limits how often a session's last seen time is written back to storage
+const lastSeenInterval = time.Minute
+
+// ServerStore keeps session values in the persist layer and only a random session token in the cookie,
+// so sessions can be listed and revoked. Sessions end after IdleTimeout without a request or MaxAge after login.
+type ServerStore struct {
+	Codecs      []securecookie.Codec
+	Options     *sessions.Options
+	IdleTimeout time.Duration
+	MaxAge      time.Duration
+}
+
+// NewServerStore creates a server side session store. The key pairs protect the session token cookie.
+func NewServerStore(idleTimeout time.Duration, maxAge time.Duration, keyPairs ...[]byte) *ServerStore {
+	return &ServerStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   int(maxAge.Seconds()),
+			SameSite: http.SameSiteNoneMode,
+			Secure:   true,
+			HttpOnly: true,
+		},
+		IdleTimeout: idleTimeout,
+		MaxAge:      maxAge,
+	}
+}
+
+// Get returns the session for the request, cached for the rest of the request.
+func (s *ServerStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request cookie, or returns a new session if there is none or it has expired.
+func (s *ServerStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.Codecs...); err != nil {
+		// Most likely a cookie from before sessions were kept server side
+		return session, nil
+	}
+
+	record, err := db.LoadSession(sessionKey(token))
+	if err != nil {
+		return session, err
+	}
+	if record == nil {
+		return session, nil
+	}
+
+	now := time.Now()
+	if s.expired(record, now) {
+		if err := db.DeleteSession(record.ID); err != nil {
+			xlog.Logf("Failed to delete expired session: %v", err)
+		}
+		return session, nil
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(record.Values)).Decode(&session.Values); err != nil {
+		return session, fmt.Errorf("failed to decode session values: %v", err)
+	}
+	session.ID = token
+	session.IsNew = false
+
+	if now.Sub(record.LastSeen) > lastSeenInterval {
+		record.LastSeen = now
+		if err := db.SaveSession(record); err != nil {
+			xlog.Logf("Failed to update session last seen time: %v", err)
+		}
+	}
+
+	return session, nil
+}
+
+// Save stores the session values and sets the session token cookie. A negative MaxAge deletes the session.
+func (s *ServerStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := db.DeleteSession(sessionKey(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	now := time.Now()
+	record := &persist.Session{CreatedAt: now}
+	if session.ID == "" {
+		token, err := newSessionToken()
+		if err != nil {
+			return err
+		}
+		session.ID = token
+	} else {
+		existing, err := db.LoadSession(sessionKey(session.ID))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			record.CreatedAt = existing.CreatedAt
+		}
+	}
+
+	var values bytes.Buffer
+	if err := gob.NewEncoder(&values).Encode(session.Values); err != nil {
+		return fmt.Errorf("failed to encode session values: %v", err)
+	}
+
+	record.ID = sessionKey(session.ID)
+	record.Values = values.Bytes()
+	record.LastSeen = now
+	record.UserAgent = r.UserAgent()
+	if mainIdentity, ok := session.Values[loggedInUser].(int64); ok {
+		record.MainIdentity = mainIdentity
+	}
+
+	if err := db.SaveSession(record); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+
+	return nil
+}
+
+// ActiveSessions returns the unexpired sessions, deleting any that have expired.
+func (s *ServerStore) ActiveSessions() ([]persist.Session, error) {
+	records, err := db.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := make([]persist.Session, 0, len(records))
+	for _, record := range records {
+		if s.expired(&record, now) {
+			if err := db.DeleteSession(record.ID); err != nil {
+				xlog.Logf("Failed to delete expired session: %v", err)
+			}
+			continue
+		}
+		active = append(active, record)
+	}
+
+	return active, nil
+}
+
+func (s *ServerStore) expired(record *persist.Session, now time.Time) bool {
+	if s.IdleTimeout > 0 && now.Sub(record.LastSeen) > s.IdleTimeout {
+		return true
+	}
+	return s.MaxAge > 0 && now.Sub(record.CreatedAt) > s.MaxAge
+}
+
+// sessionKey returns the stored ID of the session with the given cookie token
+func sessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSessionToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %v", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(token), nil
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const (
+	// apiTokenPrefix marks personal API tokens so they are easy to recognise in scripts and logs
+	apiTokenPrefix = "wtt_"
+	// maxTokenNameLength limits the length of API token names
+	maxTokenNameLength = 64
+)
+
+// CreatedAPIToken is returned once when a token is created, the only time its secret is shown.
+type CreatedAPIToken struct {
+	persist.APIToken
+	Token string `json:"token"`
+}
+
+// bearerToken returns the token from an Authorization: Bearer header, if there is one.
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// authenticateToken returns the session values of the owner of an API token, or false if the token is
+// unknown or has expired, or its owner is no longer a valid user. List access uses the owner's current (cached)
+// corporation and alliance rather than the ones they had when the token was created.
+// The token's last use is recorded at most once every lastSeenInterval.
+func authenticateToken(secret string) (SessionValues, *persist.APIToken, bool) {
+	token, err := db.LoadAPIToken(sessionKey(secret))
+	if err != nil {
+		xlog.Logf("Error loading API token: %v", err)
+		return SessionValues{}, nil, false
+	}
+	now := time.Now()
+	if token == nil || token.Expired(now) {
+		return SessionValues{}, nil, false
+	}
+
+	affiliations, err := eveapi.CachedAffiliations([]int64{token.Owner})
+	affiliation, found := affiliations[token.Owner]
+	if err != nil || !found {
+		xlog.Logf("Rejecting API token %q, failed to look up the affiliation of %d: %v", token.Name, token.Owner, err)
+		return SessionValues{}, nil, false
+	}
+	owner := model.CharacterData{Character: model.Character{
+		User:          model.User{CharacterID: token.Owner, CharacterName: token.OwnerName},
+		CorporationID: affiliation.CorporationID,
+		AllianceID:    affiliation.AllianceID,
+	}}
+	if !validUser(owner) {
+		xlog.Logf("Rejecting API token %q, %d is no longer a valid user", token.Name, token.Owner)
+		return SessionValues{}, nil, false
+	}
+
+	if token.LastUsed == nil || now.Sub(*token.LastUsed) > lastSeenInterval {
+		token.LastUsed = &now
+		if err := db.SaveAPIToken(token); err != nil {
+			xlog.Logf("Error recording API token use: %v", err)
+		}
+	}
+
+	return SessionValues{
+		LoggedInUser:        token.Owner,
+		LoggedInUserName:    token.OwnerName,
+		LoggedInCorporation: affiliation.CorporationID,
+		LoggedInAlliance:    affiliation.AllianceID,
+		APIToken:            token.Name,
+	}, token, true
+}
+
+// TokensHandler lists the logged in user's API tokens.
+func TokensHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		tokens, err := db.ListAPITokens()
+		if err != nil {
+			xlog.Logf("Error listing API tokens: %v", err)
+			sendJSONError(w, "Error listing API tokens", http.StatusInternalServerError)
+			return
+		}
+
+		owned := []persist.APIToken{}
+		for _, token := range tokens {
+			if token.Owner == sessionValues.LoggedInUser {
+				owned = append(owned, token)
+			}
+		}
+		sort.Slice(owned, func(i, j int) bool { return owned[i].CreatedAt.After(owned[j].CreatedAt) })
+
+		sendJSONResponse(w, http.StatusOK, owned)
+	}
+}
+
+// CreateTokenHandler creates an API token for the logged in user and returns its secret.
+func CreateTokenHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Name  string `json:"name"`
+			Scope string `json:"scope"`
+			// ExpiresInDays is how long the token lasts, 0 for a token that does not expire
+			ExpiresInDays int `json:"expiresInDays"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		request.Name = strings.TrimSpace(request.Name)
+		if request.Name == "" || len(request.Name) > maxTokenNameLength {
+			sendJSONError(w, "Token names must be 1-64 characters", http.StatusBadRequest)
+			return
+		}
+		if request.Scope != persist.TokenScopeRead && request.Scope != persist.TokenScopeReadWrite {
+			sendJSONError(w, "Scope must be read or read-write", http.StatusBadRequest)
+			return
+		}
+		if request.ExpiresInDays < 0 {
+			sendJSONError(w, "Expiry must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		secret, err := newSessionToken()
+		if err != nil {
+			xlog.Logf("Error generating API token: %v", err)
+			sendJSONError(w, "Error creating API token", http.StatusInternalServerError)
+			return
+		}
+		secret = apiTokenPrefix + secret
+
+		now := time.Now()
+		token := persist.APIToken{
+			ID:            sessionKey(secret),
+			Name:          request.Name,
+			Owner:         sessionValues.LoggedInUser,
+			OwnerName:     sessionValues.LoggedInUserName,
+			CorporationID: sessionValues.LoggedInCorporation,
+			AllianceID:    sessionValues.LoggedInAlliance,
+			Scope:         request.Scope,
+			CreatedAt:     now,
+		}
+		if request.ExpiresInDays > 0 {
+			expiresAt := now.AddDate(0, 0, request.ExpiresInDays)
+			token.ExpiresAt = &expiresAt
+		}
+
+		if err := db.SaveAPIToken(&token); err != nil {
+			xlog.Logf("Error saving API token: %v", err)
+			sendJSONError(w, "Error creating API token", http.StatusInternalServerError)
+			return
+		}
+		xlog.Logf("Created %s API token %q for %d", token.Scope, token.Name, token.Owner)
+
+		sendJSONResponse(w, http.StatusCreated, CreatedAPIToken{APIToken: token, Token: secret})
+	}
+}
+
+// RevokeTokenHandler deletes one of the logged in user's API tokens.
+func RevokeTokenHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := s.Get(r, sessionName)
+		sessionValues := getSessionValues(session)
+		if err != nil || sessionValues.LoggedInUser == 0 {
+			sendJSONError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ID == "" {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		token, err := db.LoadAPIToken(request.ID)
+		if err != nil {
+			xlog.Logf("Error loading API token: %v", err)
+			sendJSONError(w, "Error revoking API token", http.StatusInternalServerError)
+			return
+		}
+		if token == nil || token.Owner != sessionValues.LoggedInUser {
+			sendJSONError(w, "API token not found", http.StatusNotFound)
+			return
+		}
+
+		if err := db.DeleteAPIToken(token.ID); err != nil {
+			xlog.Logf("Error deleting API token: %v", err)
+			sendJSONError(w, "Error revoking API token", http.StatusInternalServerError)
+			return
+		}
+		xlog.Logf("Revoked API token %q for %d", token.Name, token.Owner)
+
+		sendJSONResponse(w, http.StatusOK, map[string]string{"message": "API token revoked"})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/gambtho/whototrust/eveapi"
 	"github.com/gambtho/whototrust/model"
 	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/trust"
 	"github.com/gambtho/whototrust/xlog"
 )
 
@@ -73,7 +74,7 @@ func getSessionIdentity(s *SessionService, r *http.Request) (int64, oauth2.Token
 	}
 
 	// Retrieve token for main identity.
-	token, err := persist.GetMainIdentityToken(mainIdentity)
+	token, err := persist.GetMainIdentityToken(db, mainIdentity)
 	if err != nil {
 		errorMessage := fmt.Sprintf("Error retrieving token for main identity: %v", err)
 		xlog.Logf(errorMessage)
@@ -84,7 +85,7 @@ func getSessionIdentity(s *SessionService, r *http.Request) (int64, oauth2.Token
 }
 
 // Helper function to parse and resolve the identifier.
-func resolveIdentifier(identifier string, entityType string, mainIdentity int64, token *oauth2.Token) (EntityData, error) {
+func resolveIdentifier(identifier string, entityType string) (EntityData, error) {
 	// Trim spaces.
 	identifier = strings.TrimSpace(identifier)
 	if identifier == "" {
@@ -100,54 +101,58 @@ func resolveIdentifier(identifier string, entityType string, mainIdentity int64,
 	}
 
 	// Else, treat as name and resolve to ID.
-	var resolvedID int32
-	var err error
-	if entityType == "character" {
-		xlog.Logf("Resolving character name to ID: %v", identifier)
-		resolvedID, err = eveapi.CharacterIDSearch(mainIdentity, identifier, token)
-	} else if entityType == "corporation" {
-		xlog.Logf("Resolving corporation name to ID: %v", identifier)
-		resolvedID, err = eveapi.CorporationIDSearch(mainIdentity, identifier, token)
-	} else {
-		return EntityData{}, fmt.Errorf("unknown entity type: %s", entityType)
-	}
-
+	xlog.Logf("Resolving %s name to ID: %v", entityType, identifier)
+	resolved, err := eveapi.ResolveNames([]string{identifier})
 	if err != nil {
 		return EntityData{}, fmt.Errorf("failed to resolve name to ID: %v, try adding by ID instead", err)
 	}
 
-	if resolvedID <= 0 {
-		return EntityData{}, fmt.Errorf("resolved ID is invalid for identifier: %s", identifier)
+	var candidates []model.UniverseEntity
+	switch entityType {
+	case "character":
+		candidates = resolved.Characters
+	case "corporation":
+		candidates = resolved.Corporations
+	case "alliance":
+		candidates = resolved.Alliances
+	default:
+		return EntityData{}, fmt.Errorf("unknown entity type: %s", entityType)
+	}
+
+	entity, found := eveapi.FindByName(candidates, identifier)
+	if !found || entity.ID <= 0 {
+		return EntityData{}, fmt.Errorf("no %s found with name %s, try adding by ID instead", entityType, identifier)
 	}
 
-	return EntityData{ID: int64(resolvedID), Name: identifier}, nil
+	return EntityData{ID: entity.ID, Name: entity.Name}, nil
 }
 
 // Helper function to fetch entity data based on type and ID.
 func fetchEntityData(entityType string, data EntityData, token *oauth2.Token) (EntityData, error) {
 	if entityType == "character" {
-		xlog.Logf("Fetching character data for ID: %v", data.ID)
-		characterData, err := eveapi.GetPublicCharacterData(data.ID, token)
+		xlog.Logf("Fetching affiliation for CharacterID: %v", data.ID)
+		affiliations, err := eveapi.GetAffiliations([]int64{data.ID})
 		if err != nil {
-			return EntityData{}, fmt.Errorf("error retrieving character data: %v", err)
+			return EntityData{}, fmt.Errorf("error retrieving character affiliation: %v", err)
 		}
-
-		// Fetch corporation name.
-		xlog.Logf("Fetching corporation data for CharacterID: %v", data.ID)
-		corpID, err := eveapi.GetCharacterCorporation(data.ID, token)
-		if err != nil {
-			return EntityData{}, fmt.Errorf("error retrieving character's corporation ID: %v", err)
+		if len(affiliations) == 0 {
+			return EntityData{}, fmt.Errorf("no affiliation returned for character %d", data.ID)
 		}
+		affiliation := affiliations[0]
 
-		corp, err := eveapi.GetCorpInfo(int64(corpID), token)
+		// Resolve the character, corporation and alliance names in one call.
+		entities, err := eveapi.ResolveIDs([]int64{data.ID, affiliation.CorporationID, affiliation.AllianceID})
 		if err != nil {
-			return EntityData{}, fmt.Errorf("error retrieving corporation info: %v", err)
+			return EntityData{}, fmt.Errorf("error resolving character names: %v", err)
 		}
+		names := eveapi.NamesByID(entities)
 
 		// Assign fetched data to EntityData
-		data.Name = characterData.Name
-		data.CorporationID = int64(corpID)
-		data.CorporationName = corp.Name
+		data.Name = names[data.ID]
+		data.CorporationID = affiliation.CorporationID
+		data.CorporationName = names[affiliation.CorporationID]
+		data.AllianceID = affiliation.AllianceID
+		data.AllianceName = names[affiliation.AllianceID]
 
 		return data, nil
 
@@ -170,15 +175,27 @@ func fetchEntityData(entityType string, data EntityData, token *oauth2.Token) (E
 
 		data.Name = corp.Name
 		return data, nil
+
+	} else if entityType == "alliance" {
+		xlog.Logf("Fetching alliance name for ID: %v", data.ID)
+		alliance, err := eveapi.GetAllianceInfo(int32(data.ID), token)
+		if err != nil {
+			return EntityData{}, fmt.Errorf("error retrieving alliance info: %v", err)
+		}
+
+		data.Name = alliance.Name
+		return data, nil
 	}
 
 	return EntityData{}, fmt.Errorf("unknown entity type: %s", entityType)
 }
 
 func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request, trustStatus string, entityType string) {
-	// Decode request body to accept 'identifier'.
+	// Decode request body to accept 'identifier', an optional expected 'revision' and the 'list' to change.
 	var request struct {
 		Identifier string `json:"identifier"`
+		Revision   *int64 `json:"revision"`
+		List       string `json:"list"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -187,6 +204,12 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	revision, err := requestRevision(r, request.Revision)
+	if err != nil {
+		writeJSONError(w, err.Error(), request.Identifier, http.StatusBadRequest)
+		return
+	}
+
 	xlog.Logf("Adding %s %s with identifier: %v", trustStatus, entityType, request.Identifier)
 
 	// Retrieve main identity and token regardless of trustStatus.
@@ -196,8 +219,15 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	session, _ := s.Get(r, sessionName)
+	list, err := authorizeList(getSessionValues(session), requestList(request.List), true)
+	if err != nil {
+		writeJSONError(w, err.Error(), request.Identifier, listErrorStatus(err))
+		return
+	}
+
 	// Resolve identifier.
-	resolvedData, err := resolveIdentifier(request.Identifier, entityType, mainIdentity, &token)
+	resolvedData, err := resolveIdentifier(request.Identifier, entityType)
 	if err != nil {
 		xlog.Logf("Identifier resolution error: %v", err)
 		writeJSONError(w, "Identifier resolution failed", request.Identifier, http.StatusBadRequest)
@@ -224,6 +254,9 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		addedByName = addedByCharacter.Name
 	}
 
+	// Snapshot conflicts so any introduced by this addition can be reported.
+	previousConflicts := currentConflicts(list.Name)
+
 	// Create the corresponding model based on trustStatus and entityType.
 	switch {
 	case trustStatus == "trusted" && entityType == "character":
@@ -232,6 +265,8 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 			CharacterName:   fetchedData.Name,
 			CorporationID:   fetchedData.CorporationID,
 			CorporationName: fetchedData.CorporationName,
+			AllianceID:      fetchedData.AllianceID,
+			AllianceName:    fetchedData.AllianceName,
 			AddedBy:         addedByName,
 			DateAdded:       time.Now(),
 		}
@@ -239,14 +274,23 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		xlog.Logf("Adding new trusted character: %+v", trustedCharacter)
 
 		// Persist the trusted character.
-		if err := persist.AddTrustedCharacter(trustedCharacter); err != nil {
+		added, err := persist.AddTrustedCharacter(db, list.Name, trustedCharacter, revision)
+		if err != nil {
+			if err == persist.ErrRevisionConflict {
+				writeRevisionConflict(w, list.Name, trustStatus, entityType, trustedCharacter.CharacterID)
+				return
+			}
 			xlog.Logf("Error saving trusted character: %v", err)
 			writeJSONError(w, "Failed to save trusted character", request.Identifier, http.StatusInternalServerError)
 			return
 		}
 
-		// Respond with the new trusted character data.
-		writeJSONResponse(w, trustedCharacter, http.StatusOK)
+		if added {
+			recordEvents(entryEvent(model.EventEntryAdded, addedByName, list.Name, trustStatus, entityType, trustedCharacter.CharacterID, trustedCharacter.CharacterName, ""))
+		}
+
+		// Respond with the stored trusted character data, which carries its revision.
+		writeStoredEntry(w, list.Name, trustStatus, entityType, trustedCharacter.CharacterID, trustedCharacter)
 
 	case trustStatus == "trusted" && entityType == "corporation":
 		trustedCorporation := model.TrustedCorporation{
@@ -261,14 +305,23 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		xlog.Logf("Adding new trusted corporation: %+v", trustedCorporation)
 
 		// Persist the trusted corporation.
-		if err := persist.AddTrustedCorporation(trustedCorporation); err != nil {
+		added, err := persist.AddTrustedCorporation(db, list.Name, trustedCorporation, revision)
+		if err != nil {
+			if err == persist.ErrRevisionConflict {
+				writeRevisionConflict(w, list.Name, trustStatus, entityType, trustedCorporation.CorporationID)
+				return
+			}
 			xlog.Logf("Error saving trusted corporation: %v", err)
 			writeJSONError(w, "Failed to save trusted corporation", request.Identifier, http.StatusInternalServerError)
 			return
 		}
 
-		// Respond with the new trusted corporation data.
-		writeJSONResponse(w, trustedCorporation, http.StatusOK)
+		if added {
+			recordEvents(entryEvent(model.EventEntryAdded, addedByName, list.Name, trustStatus, entityType, trustedCorporation.CorporationID, trustedCorporation.CorporationName, ""))
+		}
+
+		// Respond with the stored trusted corporation data, which carries its revision.
+		writeStoredEntry(w, list.Name, trustStatus, entityType, trustedCorporation.CorporationID, trustedCorporation)
 
 	case trustStatus == "untrusted" && entityType == "character":
 		untrustedCharacter := model.TrustedCharacter{ // Correct model
@@ -276,6 +329,8 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 			CharacterName:   fetchedData.Name,
 			CorporationName: fetchedData.CorporationName,
 			CorporationID:   fetchedData.CorporationID,
+			AllianceID:      fetchedData.AllianceID,
+			AllianceName:    fetchedData.AllianceName,
 			DateAdded:       time.Now(),
 			AddedBy:         addedByName,
 		}
@@ -283,14 +338,23 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		xlog.Logf("Adding new untrusted character: %+v", untrustedCharacter)
 
 		// Persist the untrusted character.
-		if err := persist.AddUntrustedCharacter(untrustedCharacter); err != nil {
+		added, err := persist.AddUntrustedCharacter(db, list.Name, untrustedCharacter, revision)
+		if err != nil {
+			if err == persist.ErrRevisionConflict {
+				writeRevisionConflict(w, list.Name, trustStatus, entityType, untrustedCharacter.CharacterID)
+				return
+			}
 			xlog.Logf("Error saving untrusted character: %v", err)
 			writeJSONError(w, "Failed to save untrusted character", request.Identifier, http.StatusInternalServerError)
 			return
 		}
 
-		// Respond with the new untrusted character data.
-		writeJSONResponse(w, untrustedCharacter, http.StatusOK)
+		if added {
+			recordEvents(entryEvent(model.EventEntryAdded, addedByName, list.Name, trustStatus, entityType, untrustedCharacter.CharacterID, untrustedCharacter.CharacterName, ""))
+		}
+
+		// Respond with the stored untrusted character data, which carries its revision.
+		writeStoredEntry(w, list.Name, trustStatus, entityType, untrustedCharacter.CharacterID, untrustedCharacter)
 
 	case trustStatus == "untrusted" && entityType == "corporation":
 		untrustedCorporation := model.TrustedCorporation{ // Correct model
@@ -305,26 +369,143 @@ func handleAddEntity(s *SessionService, w http.ResponseWriter, r *http.Request,
 		xlog.Logf("Adding new untrusted corporation: %+v", untrustedCorporation)
 
 		// Persist the untrusted corporation.
-		if err := persist.AddUntrustedCorporation(untrustedCorporation); err != nil {
+		added, err := persist.AddUntrustedCorporation(db, list.Name, untrustedCorporation, revision)
+		if err != nil {
+			if err == persist.ErrRevisionConflict {
+				writeRevisionConflict(w, list.Name, trustStatus, entityType, untrustedCorporation.CorporationID)
+				return
+			}
 			xlog.Logf("Error saving untrusted corporation: %v", err)
 			writeJSONError(w, "Failed to save untrusted corporation", request.Identifier, http.StatusInternalServerError)
 			return
 		}
 
-		// Respond with the new untrusted corporation data.
-		writeJSONResponse(w, untrustedCorporation, http.StatusOK)
+		if added {
+			recordEvents(entryEvent(model.EventEntryAdded, addedByName, list.Name, trustStatus, entityType, untrustedCorporation.CorporationID, untrustedCorporation.CorporationName, ""))
+		}
+
+		// Respond with the stored untrusted corporation data, which carries its revision.
+		writeStoredEntry(w, list.Name, trustStatus, entityType, untrustedCorporation.CorporationID, untrustedCorporation)
+
+	case entityType == "alliance":
+		alliance := model.TrustedAlliance{
+			AllianceID:   fetchedData.ID,
+			AllianceName: fetchedData.Name,
+			DateAdded:    time.Now(),
+			AddedBy:      addedByName,
+		}
+
+		xlog.Logf("Adding new %s alliance: %+v", trustStatus, alliance)
+
+		// Persist the alliance.
+		addAlliance := persist.AddTrustedAlliance
+		if trustStatus == "untrusted" {
+			addAlliance = persist.AddUntrustedAlliance
+		}
+		added, err := addAlliance(db, list.Name, alliance, revision)
+		if err != nil {
+			if err == persist.ErrRevisionConflict {
+				writeRevisionConflict(w, list.Name, trustStatus, entityType, alliance.AllianceID)
+				return
+			}
+			xlog.Logf("Error saving %s alliance: %v", trustStatus, err)
+			writeJSONError(w, fmt.Sprintf("Failed to save %s alliance", trustStatus), request.Identifier, http.StatusInternalServerError)
+			return
+		}
+
+		if added {
+			recordEvents(entryEvent(model.EventEntryAdded, addedByName, list.Name, trustStatus, entityType, alliance.AllianceID, alliance.AllianceName, ""))
+		}
+
+		// Respond with the stored alliance data, which carries its revision.
+		writeStoredEntry(w, list.Name, trustStatus, entityType, alliance.AllianceID, alliance)
 
 	default:
 		xlog.Logf("Unsupported trustStatus or entityType: %s, %s", trustStatus, entityType)
 		writeJSONError(w, "Unsupported operation", request.Identifier, http.StatusBadRequest)
+		return
+	}
+
+	recordEvents(onList(list.Name, trust.ConflictEvents(previousConflicts, currentConflicts(list.Name), addedByName))...)
+}
+
+// currentConflicts returns the conflicts on a stored trust list, logging any load failure.
+func currentConflicts(list string) []model.TrustConflict {
+	trustedData, err := db.LoadTrustedCharacters(list)
+	if err != nil {
+		xlog.Logf("Error loading trusted characters for conflict check: %v", err)
+		return nil
+	}
+	return trust.FindConflicts(trustedData)
+}
+
+// lookupEntityName returns the stored name of a list entry, or an empty string if it is not on the list.
+func lookupEntityName(list string, trustStatus string, entityType string, id int64) string {
+	_, name := lookupEntry(list, trustStatus, entityType, id)
+	return name
+}
+
+// lookupEntry returns the stored list entry and its name, or nil if it is not on the list.
+func lookupEntry(list string, trustStatus string, entityType string, id int64) (interface{}, string) {
+	trustedData, err := db.LoadTrustedCharacters(list)
+	if err != nil {
+		return nil, ""
+	}
+
+	characters, corporations, alliances := trustedData.TrustedCharacters, trustedData.TrustedCorporations, trustedData.TrustedAlliances
+	if trustStatus == "untrusted" {
+		characters, corporations, alliances = trustedData.UntrustedCharacters, trustedData.UntrustedCorporations, trustedData.UntrustedAlliances
+	}
+
+	switch entityType {
+	case "character":
+		for _, char := range characters {
+			if char.CharacterID == id {
+				return char, char.CharacterName
+			}
+		}
+	case "alliance":
+		for _, alliance := range alliances {
+			if alliance.AllianceID == id {
+				return alliance, alliance.AllianceName
+			}
+		}
+	default:
+		for _, corp := range corporations {
+			if corp.CorporationID == id {
+				return corp, corp.CorporationName
+			}
+		}
+	}
+	return nil, ""
+}
+
+// writeStoredEntry responds with the entry as stored, falling back to the given entry if it cannot be read back.
+func writeStoredEntry(w http.ResponseWriter, list string, trustStatus string, entityType string, id int64, fallback interface{}) {
+	stored, _ := lookupEntry(list, trustStatus, entityType, id)
+	if stored == nil {
+		writeJSONResponse(w, fallback, http.StatusOK)
+		return
+	}
+
+	switch entry := stored.(type) {
+	case model.TrustedCharacter:
+		setRevisionHeader(w, entry.Revision)
+	case model.TrustedCorporation:
+		setRevisionHeader(w, entry.Revision)
+	case model.TrustedAlliance:
+		setRevisionHeader(w, entry.Revision)
 	}
+	writeJSONResponse(w, stored, http.StatusOK)
 }
 
 // Generic function to handle removing entities.
-func handleRemoveEntity(w http.ResponseWriter, r *http.Request, trustStatus string, entityType string) {
-	// Decode request body to accept 'identifier'.
+func handleRemoveEntity(s *SessionService, w http.ResponseWriter, r *http.Request, trustStatus string, entityType string) {
+	// Decode request body to accept 'identifier', an optional expected 'revision' and the 'list' to change.
 	var request struct {
 		Identifier string `json:"identifier"`
+		Revision   *int64 `json:"revision"`
+		List       string `json:"list"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -333,10 +514,29 @@ func handleRemoveEntity(w http.ResponseWriter, r *http.Request, trustStatus stri
 		return
 	}
 
+	revision, err := requestRevision(r, request.Revision)
+	if err != nil {
+		writeJSONError(w, err.Error(), request.Identifier, http.StatusBadRequest)
+		return
+	}
+
 	xlog.Logf("Removing %s %s with identifier: %v", trustStatus, entityType, request.Identifier)
 
+	session, err := s.Get(r, sessionName)
+	sessionValues := getSessionValues(session)
+	if err != nil || sessionValues.LoggedInUser == 0 {
+		writeJSONError(w, "Authentication required", request.Identifier, http.StatusUnauthorized)
+		return
+	}
+
+	list, err := authorizeList(sessionValues, requestList(request.List), true)
+	if err != nil {
+		writeJSONError(w, err.Error(), request.Identifier, listErrorStatus(err))
+		return
+	}
+
 	// Parse identifier.
-	resolvedData, err := resolveIdentifier(request.Identifier, entityType, 0, nil)
+	resolvedData, err := resolveIdentifier(request.Identifier, entityType)
 	if err != nil {
 		xlog.Logf("Identifier resolution error: %v", err)
 		writeJSONError(w, "Identifier resolution failed", request.Identifier, http.StatusBadRequest)
@@ -349,44 +549,94 @@ func handleRemoveEntity(w http.ResponseWriter, r *http.Request, trustStatus stri
 		return
 	}
 
+	removedName := lookupEntityName(list.Name, trustStatus, entityType, resolvedData.ID)
+
 	// Perform removal based on trustStatus and entityType.
 	switch {
 	case trustStatus == "trusted" && entityType == "character":
-		err = persist.RemoveTrustedCharacter(resolvedData.ID)
+		removed, err := persist.RemoveTrustedCharacter(db, list.Name, resolvedData.ID, revision)
+		if err == persist.ErrRevisionConflict {
+			writeRevisionConflict(w, list.Name, trustStatus, entityType, resolvedData.ID)
+			return
+		}
 		if err != nil {
 			xlog.Logf("Error removing trusted character: %v", err)
 			writeJSONError(w, "Failed to remove trusted character", request.Identifier, http.StatusInternalServerError)
 			return
 		}
+		if removed {
+			recordEvents(entryEvent(model.EventEntryRemoved, sessionValues.Actor(), list.Name, trustStatus, entityType, resolvedData.ID, removedName, ""))
+		}
 		writeJSONResponse(w, SuccessResponse{Message: "Trusted character removed successfully"}, http.StatusOK)
 
 	case trustStatus == "trusted" && entityType == "corporation":
-		err = persist.RemoveTrustedCorporation(resolvedData.ID)
+		removed, err := persist.RemoveTrustedCorporation(db, list.Name, resolvedData.ID, revision)
+		if err == persist.ErrRevisionConflict {
+			writeRevisionConflict(w, list.Name, trustStatus, entityType, resolvedData.ID)
+			return
+		}
 		if err != nil {
 			xlog.Logf("Error removing trusted corporation: %v", err)
 			writeJSONError(w, "Failed to remove trusted corporation", request.Identifier, http.StatusInternalServerError)
 			return
 		}
+		if removed {
+			recordEvents(entryEvent(model.EventEntryRemoved, sessionValues.Actor(), list.Name, trustStatus, entityType, resolvedData.ID, removedName, ""))
+		}
 		writeJSONResponse(w, SuccessResponse{Message: "Trusted corporation removed successfully"}, http.StatusOK)
 
 	case trustStatus == "untrusted" && entityType == "character":
-		err = persist.RemoveUntrustedCharacter(resolvedData.ID)
+		removed, err := persist.RemoveUntrustedCharacter(db, list.Name, resolvedData.ID, revision)
+		if err == persist.ErrRevisionConflict {
+			writeRevisionConflict(w, list.Name, trustStatus, entityType, resolvedData.ID)
+			return
+		}
 		if err != nil {
 			xlog.Logf("Error removing untrusted character: %v", err)
 			writeJSONError(w, "Failed to remove untrusted character", request.Identifier, http.StatusInternalServerError)
 			return
 		}
+		if removed {
+			recordEvents(entryEvent(model.EventEntryRemoved, sessionValues.Actor(), list.Name, trustStatus, entityType, resolvedData.ID, removedName, ""))
+		}
 		writeJSONResponse(w, SuccessResponse{Message: "Untrusted character removed successfully"}, http.StatusOK)
 
 	case trustStatus == "untrusted" && entityType == "corporation":
-		err = persist.RemoveUntrustedCorporation(resolvedData.ID)
+		removed, err := persist.RemoveUntrustedCorporation(db, list.Name, resolvedData.ID, revision)
+		if err == persist.ErrRevisionConflict {
+			writeRevisionConflict(w, list.Name, trustStatus, entityType, resolvedData.ID)
+			return
+		}
 		if err != nil {
 			xlog.Logf("Error removing untrusted corporation: %v", err)
 			writeJSONError(w, "Failed to remove untrusted corporation", request.Identifier, http.StatusInternalServerError)
 			return
 		}
+		if removed {
+			recordEvents(entryEvent(model.EventEntryRemoved, sessionValues.Actor(), list.Name, trustStatus, entityType, resolvedData.ID, removedName, ""))
+		}
 		writeJSONResponse(w, SuccessResponse{Message: "Untrusted corporation removed successfully"}, http.StatusOK)
 
+	case entityType == "alliance":
+		removeAlliance, message := persist.RemoveTrustedAlliance, "Trusted alliance removed successfully"
+		if trustStatus == "untrusted" {
+			removeAlliance, message = persist.RemoveUntrustedAlliance, "Untrusted alliance removed successfully"
+		}
+		removed, err := removeAlliance(db, list.Name, resolvedData.ID, revision)
+		if err == persist.ErrRevisionConflict {
+			writeRevisionConflict(w, list.Name, trustStatus, entityType, resolvedData.ID)
+			return
+		}
+		if err != nil {
+			xlog.Logf("Error removing %s alliance: %v", trustStatus, err)
+			writeJSONError(w, fmt.Sprintf("Failed to remove %s alliance", trustStatus), request.Identifier, http.StatusInternalServerError)
+			return
+		}
+		if removed {
+			recordEvents(entryEvent(model.EventEntryRemoved, sessionValues.Actor(), list.Name, trustStatus, entityType, resolvedData.ID, removedName, ""))
+		}
+		writeJSONResponse(w, SuccessResponse{Message: message}, http.StatusOK)
+
 	default:
 		xlog.Logf("Unsupported trustStatus or entityType: %s, %s", trustStatus, entityType)
 		writeJSONError(w, "Unsupported operation", request.Identifier, http.StatusBadRequest)
@@ -401,8 +651,10 @@ func AddTrustedCharacterHandler(s *SessionService) http.HandlerFunc {
 }
 
 // RemoveTrustedCharacterHandler removes a trusted character by identifier.
-func RemoveTrustedCharacterHandler(w http.ResponseWriter, r *http.Request) {
-	handleRemoveEntity(w, r, "trusted", "character")
+func RemoveTrustedCharacterHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveEntity(s, w, r, "trusted", "character")
+	}
 }
 
 // AddTrustedCorporationHandler validates and adds a trusted corporation.
@@ -413,8 +665,10 @@ func AddTrustedCorporationHandler(s *SessionService) http.HandlerFunc {
 }
 
 // RemoveTrustedCorporationHandler removes a trusted corporation by identifier.
-func RemoveTrustedCorporationHandler(w http.ResponseWriter, r *http.Request) {
-	handleRemoveEntity(w, r, "trusted", "corporation")
+func RemoveTrustedCorporationHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveEntity(s, w, r, "trusted", "corporation")
+	}
 }
 
 // AddUntrustedCharacterHandler validates and adds an untrusted character.
@@ -425,8 +679,10 @@ func AddUntrustedCharacterHandler(s *SessionService) http.HandlerFunc {
 }
 
 // RemoveUntrustedCharacterHandler removes an untrusted character by identifier.
-func RemoveUntrustedCharacterHandler(w http.ResponseWriter, r *http.Request) {
-	handleRemoveEntity(w, r, "untrusted", "character")
+func RemoveUntrustedCharacterHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveEntity(s, w, r, "untrusted", "character")
+	}
 }
 
 // AddUntrustedCorporationHandler validates and adds an untrusted corporation.
@@ -437,6 +693,36 @@ func AddUntrustedCorporationHandler(s *SessionService) http.HandlerFunc {
 }
 
 // RemoveUntrustedCorporationHandler removes an untrusted corporation by identifier.
-func RemoveUntrustedCorporationHandler(w http.ResponseWriter, r *http.Request) {
-	handleRemoveEntity(w, r, "untrusted", "corporation")
+func RemoveUntrustedCorporationHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveEntity(s, w, r, "untrusted", "corporation")
+	}
+}
+
+// AddTrustedAllianceHandler validates and adds a trusted alliance.
+func AddTrustedAllianceHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleAddEntity(s, w, r, "trusted", "alliance")
+	}
+}
+
+// RemoveTrustedAllianceHandler removes a trusted alliance by identifier.
+func RemoveTrustedAllianceHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveEntity(s, w, r, "trusted", "alliance")
+	}
+}
+
+// AddUntrustedAllianceHandler validates and adds an untrusted alliance.
+func AddUntrustedAllianceHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleAddEntity(s, w, r, "untrusted", "alliance")
+	}
+}
+
+// RemoveUntrustedAllianceHandler removes an untrusted alliance by identifier.
+func RemoveUntrustedAllianceHandler(s *SessionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveEntity(s, w, r, "untrusted", "alliance")
+	}
 }
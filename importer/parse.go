@@ -0,0 +1,310 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/trust"
+)
+
+// Import formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Row results
+const (
+	// ResultAdd rows will be added
+	ResultAdd = "add"
+	// ResultExists rows are already on the list
+	ResultExists = "exists"
+	// ResultDuplicate rows repeat an earlier row of the import
+	ResultDuplicate = "duplicate"
+	// ResultUnresolved rows did not match any character, corporation or alliance
+	ResultUnresolved = "unresolved"
+	// ResultAmbiguous rows matched more than one character, corporation or alliance
+	ResultAmbiguous = "ambiguous"
+	// ResultInvalid rows could not be read or name something that cannot be added
+	ResultInvalid = "invalid"
+)
+
+// MaxRows limits how many rows a single import may contain
+const MaxRows = 2000
+
+// Defaults are applied to rows that do not give their own status, type, comment or standing.
+// An empty Type lets names match a character, a corporation or an alliance.
+type Defaults struct {
+	Status   string
+	Type     string
+	Comment  string
+	Standing *float64
+}
+
+// Row is one entry of an import, as read and then as resolved
+type Row struct {
+	Line            int                    `json:"line"`
+	Input           string                 `json:"input"`
+	Status          string                 `json:"status"`
+	Type            string                 `json:"type,omitempty"`
+	ID              int64                  `json:"id,omitempty"`
+	Name            string                 `json:"name,omitempty"`
+	CorporationID   int64                  `json:"corporation_id,omitempty"`
+	CorporationName string                 `json:"corporation_name,omitempty"`
+	AllianceID      int64                  `json:"alliance_id,omitempty"`
+	AllianceName    string                 `json:"alliance_name,omitempty"`
+	Comment         string                 `json:"comment,omitempty"`
+	Standing        *float64               `json:"standing,omitempty"`
+	ExpiresAt       *time.Time             `json:"expires_at,omitempty"`
+	Tags            []string               `json:"tags,omitempty"`
+	Result          string                 `json:"result"`
+	Message         string                 `json:"message,omitempty"`
+	Candidates      []model.UniverseEntity `json:"candidates,omitempty"`
+
+	// byID is set when the input was an ID rather than a name
+	byID bool
+}
+
+// pending reports whether the row still needs resolving
+func (r Row) pending() bool {
+	return r.Result == ""
+}
+
+// invalidate marks the row as unusable
+func (r *Row) invalidate(format string, args ...interface{}) {
+	r.Result = ResultInvalid
+	r.Message = fmt.Sprintf(format, args...)
+}
+
+// DetectFormat guesses the format of import data: JSON if it is an object, CSV if the first line
+// has a comma or tab, which EVE names cannot contain, and a plain list otherwise.
+func DetectFormat(data string) string {
+	trimmed := strings.TrimSpace(data)
+	if strings.HasPrefix(trimmed, "{") {
+		return FormatJSON
+	}
+	firstLine, _, _ := strings.Cut(trimmed, "\n")
+	if strings.ContainsAny(firstLine, ",\t") {
+		return FormatCSV
+	}
+	return FormatText
+}
+
+// Parse reads import data in the given format, or the detected format if it is empty
+func Parse(format string, data string, defaults Defaults) ([]Row, error) {
+	if format == "" {
+		format = DetectFormat(data)
+	}
+
+	var rows []Row
+	var err error
+	switch format {
+	case FormatText:
+		rows = parseText(data, defaults)
+	case FormatCSV:
+		rows, err = parseCSV(data, defaults)
+	case FormatJSON:
+		rows, err = parseJSON(data, defaults)
+	default:
+		return nil, fmt.Errorf("unknown import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("nothing to import")
+	}
+	if len(rows) > MaxRows {
+		return nil, fmt.Errorf("imports are limited to %d rows, this one has %d", MaxRows, len(rows))
+	}
+	return rows, nil
+}
+
+// newRow creates a row for a name or ID with the defaults applied
+func newRow(line int, input string, defaults Defaults) Row {
+	input = strings.TrimSpace(input)
+	row := Row{
+		Line:     line,
+		Input:    input,
+		Status:   defaults.Status,
+		Type:     defaults.Type,
+		Comment:  defaults.Comment,
+		Standing: defaults.Standing,
+	}
+	if id, err := strconv.ParseInt(input, 10, 64); err == nil {
+		row.ID = id
+		row.byID = true
+		if id <= 0 {
+			row.invalidate("IDs must be positive")
+		}
+	} else {
+		row.Name = input
+	}
+	return row
+}
+
+// parseText reads one name or ID per line, skipping blank lines and lines starting with #
+func parseText(data string, defaults Defaults) []Row {
+	var rows []Row
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, newRow(i+1, line, defaults))
+	}
+	return rows
+}
+
+// csvColumns are the header names recognised in CSV imports
+var csvColumns = map[string]string{
+	"name":       "identifier",
+	"id":         "identifier",
+	"identifier": "identifier",
+	"status":     "status",
+	"type":       "type",
+	"comment":    "comment",
+	"standing":   "standing",
+	"expires_at": "expires_at",
+	"tags":       "tags",
+}
+
+// parseCSV reads CSV with an optional header row naming the identifier, status, type, comment, standing, expires_at
+// and tags columns. Without a header the first column is the name or ID and the second an optional comment.
+func parseCSV(data string, defaults Defaults) ([]Row, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if !strings.Contains(strings.SplitN(data, "\n", 2)[0], ",") {
+		reader.Comma = '\t'
+	}
+
+	columns := map[string]int{"identifier": 0, "comment": 1}
+	var rows []Row
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			if header, ok := csvHeader(record); ok {
+				columns = header
+				continue
+			}
+		}
+
+		field := func(name string) string {
+			index, ok := columns[name]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		if field("identifier") == "" {
+			continue
+		}
+		row := newRow(line, field("identifier"), defaults)
+		if status := strings.ToLower(field("status")); status != "" {
+			row.Status = status
+		}
+		if entityType := strings.ToLower(field("type")); entityType != "" {
+			row.Type = entityType
+		}
+		if comment := field("comment"); comment != "" {
+			row.Comment = comment
+		}
+		if value := field("standing"); value != "" {
+			standing, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				row.invalidate("invalid standing: %s", value)
+			} else {
+				row.Standing = &standing
+			}
+		}
+		if value := field("expires_at"); value != "" {
+			expiresAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				row.invalidate("invalid expiry, use an RFC 3339 time: %s", value)
+			} else {
+				row.ExpiresAt = &expiresAt
+			}
+		}
+		row.Tags = trust.SplitTags(field("tags"))
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// csvHeader returns the column positions named by a header record, if it is one
+func csvHeader(record []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, value := range record {
+		if column, ok := csvColumns[strings.ToLower(strings.TrimSpace(value))]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+	_, ok := columns["identifier"]
+	return columns, ok
+}
+
+// parseJSON reads a trust list in the shape it is stored in, keeping the comments, standings, expiries and tags of its entries
+func parseJSON(data string, defaults Defaults) ([]Row, error) {
+	var trustedData model.TrustedCharacters
+	if err := json.Unmarshal([]byte(data), &trustedData); err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %v", err)
+	}
+
+	var rows []Row
+	addRow := func(status, entityType string, id int64, name, comment string, standing *float64, expiresAt *time.Time, tags []string) {
+		row := newRow(len(rows)+1, strconv.FormatInt(id, 10), defaults)
+		row.Status, row.Type = status, entityType
+		if name != "" {
+			row.Input = name
+		}
+		if comment != "" {
+			row.Comment = comment
+		}
+		if standing != nil {
+			row.Standing = standing
+		}
+		row.ExpiresAt = expiresAt
+		row.Tags = tags
+		rows = append(rows, row)
+	}
+
+	for _, char := range trustedData.TrustedCharacters {
+		addRow(trust.StatusTrusted, trust.TypeCharacter, char.CharacterID, char.CharacterName, char.Comment, char.Standing, char.ExpiresAt, char.Tags)
+	}
+	for _, corp := range trustedData.TrustedCorporations {
+		addRow(trust.StatusTrusted, trust.TypeCorporation, corp.CorporationID, corp.CorporationName, corp.Comment, corp.Standing, corp.ExpiresAt, corp.Tags)
+	}
+	for _, alliance := range trustedData.TrustedAlliances {
+		addRow(trust.StatusTrusted, trust.TypeAlliance, alliance.AllianceID, alliance.AllianceName, alliance.Comment, alliance.Standing, alliance.ExpiresAt, alliance.Tags)
+	}
+	for _, char := range trustedData.UntrustedCharacters {
+		addRow(trust.StatusUntrusted, trust.TypeCharacter, char.CharacterID, char.CharacterName, char.Comment, char.Standing, char.ExpiresAt, char.Tags)
+	}
+	for _, corp := range trustedData.UntrustedCorporations {
+		addRow(trust.StatusUntrusted, trust.TypeCorporation, corp.CorporationID, corp.CorporationName, corp.Comment, corp.Standing, corp.ExpiresAt, corp.Tags)
+	}
+	for _, alliance := range trustedData.UntrustedAlliances {
+		addRow(trust.StatusUntrusted, trust.TypeAlliance, alliance.AllianceID, alliance.AllianceName, alliance.Comment, alliance.Standing, alliance.ExpiresAt, alliance.Tags)
+	}
+	return rows, nil
+}
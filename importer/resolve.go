@@ -0,0 +1,337 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// maxConcurrentCorpLookups limits the number of parallel corporation requests made while resolving
+const maxConcurrentCorpLookups = 10
+
+// validate marks rows with an unknown status or type, a standing EVE does not allow, an expiry that has passed
+// or unusable tags as invalid
+func validate(rows []Row) {
+	for i := range rows {
+		row := &rows[i]
+		if !row.pending() {
+			continue
+		}
+		tags, err := trust.NormalizeTags(row.Tags)
+		row.Tags = tags
+		switch {
+		case err != nil:
+			row.invalidate("%v", err)
+		case row.Status != trust.StatusTrusted && row.Status != trust.StatusUntrusted:
+			row.invalidate("status must be trusted or untrusted, not %q", row.Status)
+		case row.Type != "" && row.Type != trust.TypeCharacter && row.Type != trust.TypeCorporation && row.Type != trust.TypeAlliance:
+			row.invalidate("type must be character, corporation or alliance, not %q", row.Type)
+		case row.Standing != nil && (*row.Standing < -10 || *row.Standing > 10):
+			row.invalidate("standing must be between -10 and 10")
+		case row.ExpiresAt != nil && !row.ExpiresAt.After(time.Now()):
+			row.invalidate("expiry has already passed")
+		}
+	}
+}
+
+// Resolve looks up every pending row in bulk, filling in its type, name and affiliation or marking it
+// unresolved, ambiguous or invalid. An error is only returned if ESI could not be reached.
+func Resolve(rows []Row) error {
+	validate(rows)
+
+	if err := resolveNames(rows); err != nil {
+		return err
+	}
+	if err := resolveIDs(rows); err != nil {
+		return err
+	}
+	return resolveAffiliations(rows)
+}
+
+// resolveNames resolves the rows given by name with a single bulk lookup
+func resolveNames(rows []Row) error {
+	var names []string
+	for _, row := range rows {
+		if row.pending() && !row.byID {
+			names = append(names, row.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	resolved, err := eveapi.ResolveNames(names)
+	if err != nil {
+		return fmt.Errorf("failed to resolve names: %v", err)
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		if !row.pending() || row.byID {
+			continue
+		}
+
+		var candidates []model.UniverseEntity
+		if row.Type == "" || row.Type == trust.TypeCharacter {
+			candidates = append(candidates, withCategory(matching(resolved.Characters, row.Name), trust.TypeCharacter)...)
+		}
+		if row.Type == "" || row.Type == trust.TypeCorporation {
+			candidates = append(candidates, withCategory(matching(resolved.Corporations, row.Name), trust.TypeCorporation)...)
+		}
+		if row.Type == "" || row.Type == trust.TypeAlliance {
+			candidates = append(candidates, withCategory(matching(resolved.Alliances, row.Name), trust.TypeAlliance)...)
+		}
+
+		switch {
+		case len(candidates) == 1:
+			row.ID, row.Name, row.Type = candidates[0].ID, candidates[0].Name, candidates[0].Category
+		case len(candidates) > 1:
+			row.Result = ResultAmbiguous
+			row.Message = "matches more than one character, corporation or alliance, add it by ID or set its type"
+			row.Candidates = candidates
+		default:
+			row.Result = ResultUnresolved
+			row.Message = fmt.Sprintf("no %s found with this name", describeType(row.Type))
+		}
+	}
+	return nil
+}
+
+// resolveIDs checks the rows given by ID exist and are characters, corporations or alliances
+func resolveIDs(rows []Row) error {
+	var ids []int64
+	for _, row := range rows {
+		if row.pending() && row.byID {
+			ids = append(ids, row.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	entities, err := eveapi.ResolveIDs(ids)
+	if err != nil {
+		return fmt.Errorf("failed to resolve IDs: %v", err)
+	}
+	byID := make(map[int64]model.UniverseEntity, len(entities))
+	for _, entity := range entities {
+		byID[entity.ID] = entity
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		if !row.pending() || !row.byID {
+			continue
+		}
+
+		entity, ok := byID[row.ID]
+		switch {
+		case !ok:
+			row.Result = ResultUnresolved
+			row.Message = "no character, corporation or alliance has this ID"
+		case entity.Category != trust.TypeCharacter && entity.Category != trust.TypeCorporation && entity.Category != trust.TypeAlliance:
+			row.invalidate("%s is a %s, only characters, corporations and alliances can be imported", entity.Name, entity.Category)
+		case row.Type != "" && row.Type != entity.Category:
+			row.invalidate("%s is a %s, not a %s", entity.Name, entity.Category, row.Type)
+		default:
+			row.Name, row.Type = entity.Name, entity.Category
+		}
+	}
+	return nil
+}
+
+// resolveAffiliations fills in the corporation and alliance of every resolved character and corporation row
+func resolveAffiliations(rows []Row) error {
+	var characterIDs, corporationIDs []int64
+	seenCorporations := make(map[int64]bool)
+	for _, row := range rows {
+		if !row.pending() {
+			continue
+		}
+		if row.Type == trust.TypeCharacter {
+			characterIDs = append(characterIDs, row.ID)
+		} else if row.Type == trust.TypeCorporation && !seenCorporations[row.ID] {
+			seenCorporations[row.ID] = true
+			corporationIDs = append(corporationIDs, row.ID)
+		}
+	}
+
+	affiliations := make(map[int64]model.CharacterAffiliation)
+	if len(characterIDs) > 0 {
+		results, err := eveapi.GetAffiliations(characterIDs)
+		if err != nil {
+			return fmt.Errorf("failed to get character affiliations: %v", err)
+		}
+		for _, affiliation := range results {
+			affiliations[affiliation.CharacterID] = affiliation
+		}
+	}
+	corpAlliances, err := corporationAlliances(corporationIDs)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	for _, affiliation := range affiliations {
+		ids = append(ids, affiliation.CorporationID, affiliation.AllianceID)
+	}
+	for _, allianceID := range corpAlliances {
+		ids = append(ids, allianceID)
+	}
+	entities, err := eveapi.ResolveIDs(ids)
+	if err != nil {
+		return fmt.Errorf("failed to resolve affiliation names: %v", err)
+	}
+	names := eveapi.NamesByID(entities)
+
+	for i := range rows {
+		row := &rows[i]
+		if !row.pending() {
+			continue
+		}
+		if row.Type == trust.TypeCharacter {
+			affiliation := affiliations[row.ID]
+			row.CorporationID, row.CorporationName = affiliation.CorporationID, names[affiliation.CorporationID]
+			row.AllianceID, row.AllianceName = affiliation.AllianceID, names[affiliation.AllianceID]
+		} else if row.Type == trust.TypeCorporation {
+			allianceID := corpAlliances[row.ID]
+			row.AllianceID, row.AllianceName = allianceID, names[allianceID]
+		}
+	}
+	return nil
+}
+
+// corporationAlliances returns the alliance of each corporation. There is no bulk lookup for this,
+// so corporations are fetched in parallel.
+func corporationAlliances(corporationIDs []int64) (map[int64]int64, error) {
+	alliances := make(map[int64]int64, len(corporationIDs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan struct{}, maxConcurrentCorpLookups)
+	for _, corporationID := range corporationIDs {
+		wg.Add(1)
+		go func(corporationID int64) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			corpInfo, err := eveapi.GetCorpInfo(corporationID, nil)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				xlog.Logf("Failed to look up corporation %d: %v", corporationID, err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to look up corporation %d: %v", corporationID, err)
+				}
+				return
+			}
+			if corpInfo.AllianceID != nil {
+				alliances[corporationID] = int64(*corpInfo.AllianceID)
+			}
+		}(corporationID)
+	}
+	wg.Wait()
+
+	return alliances, firstErr
+}
+
+// Check marks resolved rows that are already on the list or repeat an earlier row, and the rest as to be added
+func Check(rows []Row, existing *model.TrustedCharacters) {
+	onList := make(map[string]bool)
+	for _, entry := range trust.Entries(existing) {
+		onList[entryKey(entry.Status, entry.Type, entry.ID)] = true
+	}
+
+	seen := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		if !row.pending() {
+			continue
+		}
+		key := entryKey(row.Status, row.Type, row.ID)
+		switch {
+		case onList[key]:
+			row.Result = ResultExists
+			row.Message = fmt.Sprintf("already %s on this list", row.Status)
+		case seen[key] != 0:
+			row.Result = ResultDuplicate
+			row.Message = fmt.Sprintf("same as line %d", seen[key])
+		default:
+			seen[key] = row.Line
+			row.Result = ResultAdd
+		}
+	}
+}
+
+// Entries returns the entries for the rows to be added
+func Entries(rows []Row, addedBy string, now time.Time) []model.TrustEntry {
+	var added []model.TrustEntry
+	for _, row := range rows {
+		if row.Result != ResultAdd {
+			continue
+		}
+		added = append(added, model.TrustEntry{
+			Status:          row.Status,
+			Type:            row.Type,
+			ID:              row.ID,
+			Name:            row.Name,
+			CorporationID:   row.CorporationID,
+			CorporationName: row.CorporationName,
+			AllianceID:      row.AllianceID,
+			AllianceName:    row.AllianceName,
+			AddedBy:         addedBy,
+			DateAdded:       now,
+			Comment:         row.Comment,
+			Standing:        row.Standing,
+			ExpiresAt:       row.ExpiresAt,
+			Tags:            row.Tags,
+		})
+	}
+	return added
+}
+
+// Summarize counts the rows with each result
+func Summarize(rows []Row) map[string]int {
+	summary := make(map[string]int)
+	for _, row := range rows {
+		summary[row.Result]++
+	}
+	return summary
+}
+
+func entryKey(status string, entityType string, id int64) string {
+	return fmt.Sprintf("%s/%s/%d", status, entityType, id)
+}
+
+// matching returns the entities with exactly the given name, ignoring case
+func matching(entities []model.UniverseEntity, name string) []model.UniverseEntity {
+	var matches []model.UniverseEntity
+	for _, entity := range entities {
+		if strings.EqualFold(entity.Name, strings.TrimSpace(name)) {
+			matches = append(matches, entity)
+		}
+	}
+	return matches
+}
+
+func withCategory(entities []model.UniverseEntity, category string) []model.UniverseEntity {
+	for i := range entities {
+		entities[i].Category = category
+	}
+	return entities
+}
+
+func describeType(entityType string) string {
+	if entityType == "" {
+		return "character, corporation or alliance"
+	}
+	return entityType
+}
@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/notify"
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const expiryActor = "expiry job"
+
+// What happens to trusted entries when they expire. Untrusted entries are always removed.
+const (
+	// ExpiryRemove removes expired entries
+	ExpiryRemove = "remove"
+	// ExpiryDemote moves expired trusted entries to the untrusted list
+	ExpiryDemote = "demote"
+)
+
+// StartExpirySweep removes or demotes expired entries immediately and then on every interval
+func StartExpirySweep(st persist.Store, interval time.Duration, action string) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := SweepExpiredEntries(st, time.Now(), action); err != nil {
+				xlog.Logf("Failed to sweep expired entries: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// SweepExpiredEntries removes or demotes every entry that expired by now and records an audit event for each
+func SweepExpiredEntries(st persist.Store, now time.Time, action string) error {
+	lists, err := st.LoadTrustLists()
+	if err != nil {
+		return fmt.Errorf("failed to load trust lists: %v", err)
+	}
+
+	var events []model.AuditEvent
+	for _, list := range lists {
+		var listEvents []model.AuditEvent
+		err := st.UpdateTrusted(list.Name, func(trustedData *model.TrustedCharacters) error {
+			previousConflicts := trust.FindConflicts(trustedData)
+			listEvents = expireEntries(trustedData, now, action)
+			if len(listEvents) == 0 {
+				return persist.ErrUnchanged
+			}
+			listEvents = append(listEvents, trust.ConflictEvents(previousConflicts, trust.FindConflicts(trustedData), expiryActor)...)
+			return nil
+		})
+		if err == persist.ErrListNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save trusted data for list %s: %v", list.Name, err)
+		}
+
+		for i := range listEvents {
+			listEvents[i].List = list.Name
+		}
+		events = append(events, listEvents...)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	notify.Notify(events...)
+	if err := st.AppendAuditEvents(events...); err != nil {
+		return fmt.Errorf("failed to record expired entries: %v", err)
+	}
+
+	xlog.Logf("Swept expired entries, %d events", len(events))
+	return nil
+}
+
+// expireEntries removes or demotes the expired entries of a list and returns an event for each
+func expireEntries(trustedData *model.TrustedCharacters, now time.Time, action string) []model.AuditEvent {
+	var events []model.AuditEvent
+	expired := func(expiresAt *time.Time) bool {
+		return expiresAt != nil && !expiresAt.After(now)
+	}
+	event := func(status, entityType string, id int64, name, detail string) model.AuditEvent {
+		return model.AuditEvent{
+			Time:       now,
+			Type:       model.EventEntryExpired,
+			Actor:      expiryActor,
+			EntityType: entityType,
+			EntityID:   id,
+			EntityName: name,
+			Detail:     fmt.Sprintf("%s %s %s", status, entityType, detail),
+		}
+	}
+
+	keptCharacters := make([]model.TrustedCharacter, 0, len(trustedData.TrustedCharacters))
+	for _, char := range trustedData.TrustedCharacters {
+		switch {
+		case !expired(char.ExpiresAt):
+			keptCharacters = append(keptCharacters, char)
+		case action == ExpiryDemote:
+			if !slices.ContainsFunc(trustedData.UntrustedCharacters, func(untrusted model.TrustedCharacter) bool { return untrusted.CharacterID == char.CharacterID }) {
+				char.ExpiresAt, char.Standing, char.Revision = nil, nil, trustedData.Revision
+				trustedData.UntrustedCharacters = append(trustedData.UntrustedCharacters, char)
+			}
+			events = append(events, event(trust.StatusTrusted, trust.TypeCharacter, char.CharacterID, char.CharacterName, "expired and was moved to untrusted"))
+		default:
+			events = append(events, event(trust.StatusTrusted, trust.TypeCharacter, char.CharacterID, char.CharacterName, "expired and was removed"))
+		}
+	}
+	trustedData.TrustedCharacters = keptCharacters
+
+	keptCorporations := make([]model.TrustedCorporation, 0, len(trustedData.TrustedCorporations))
+	for _, corp := range trustedData.TrustedCorporations {
+		switch {
+		case !expired(corp.ExpiresAt):
+			keptCorporations = append(keptCorporations, corp)
+		case action == ExpiryDemote:
+			if !slices.ContainsFunc(trustedData.UntrustedCorporations, func(untrusted model.TrustedCorporation) bool { return untrusted.CorporationID == corp.CorporationID }) {
+				corp.ExpiresAt, corp.Standing, corp.Revision = nil, nil, trustedData.Revision
+				trustedData.UntrustedCorporations = append(trustedData.UntrustedCorporations, corp)
+			}
+			events = append(events, event(trust.StatusTrusted, trust.TypeCorporation, corp.CorporationID, corp.CorporationName, "expired and was moved to untrusted"))
+		default:
+			events = append(events, event(trust.StatusTrusted, trust.TypeCorporation, corp.CorporationID, corp.CorporationName, "expired and was removed"))
+		}
+	}
+	trustedData.TrustedCorporations = keptCorporations
+
+	var keptAlliances []model.TrustedAlliance
+	for _, alliance := range trustedData.TrustedAlliances {
+		switch {
+		case !expired(alliance.ExpiresAt):
+			keptAlliances = append(keptAlliances, alliance)
+		case action == ExpiryDemote:
+			if !slices.ContainsFunc(trustedData.UntrustedAlliances, func(untrusted model.TrustedAlliance) bool { return untrusted.AllianceID == alliance.AllianceID }) {
+				alliance.ExpiresAt, alliance.Standing, alliance.Revision = nil, nil, trustedData.Revision
+				trustedData.UntrustedAlliances = append(trustedData.UntrustedAlliances, alliance)
+			}
+			events = append(events, event(trust.StatusTrusted, trust.TypeAlliance, alliance.AllianceID, alliance.AllianceName, "expired and was moved to untrusted"))
+		default:
+			events = append(events, event(trust.StatusTrusted, trust.TypeAlliance, alliance.AllianceID, alliance.AllianceName, "expired and was removed"))
+		}
+	}
+	trustedData.TrustedAlliances = keptAlliances
+
+	untrustedCharacters := make([]model.TrustedCharacter, 0, len(trustedData.UntrustedCharacters))
+	for _, char := range trustedData.UntrustedCharacters {
+		if !expired(char.ExpiresAt) {
+			untrustedCharacters = append(untrustedCharacters, char)
+			continue
+		}
+		events = append(events, event(trust.StatusUntrusted, trust.TypeCharacter, char.CharacterID, char.CharacterName, "expired and was removed"))
+	}
+	trustedData.UntrustedCharacters = untrustedCharacters
+
+	untrustedCorporations := make([]model.TrustedCorporation, 0, len(trustedData.UntrustedCorporations))
+	for _, corp := range trustedData.UntrustedCorporations {
+		if !expired(corp.ExpiresAt) {
+			untrustedCorporations = append(untrustedCorporations, corp)
+			continue
+		}
+		events = append(events, event(trust.StatusUntrusted, trust.TypeCorporation, corp.CorporationID, corp.CorporationName, "expired and was removed"))
+	}
+	trustedData.UntrustedCorporations = untrustedCorporations
+
+	var untrustedAlliances []model.TrustedAlliance
+	for _, alliance := range trustedData.UntrustedAlliances {
+		if !expired(alliance.ExpiresAt) {
+			untrustedAlliances = append(untrustedAlliances, alliance)
+			continue
+		}
+		events = append(events, event(trust.StatusUntrusted, trust.TypeAlliance, alliance.AllianceID, alliance.AllianceName, "expired and was removed"))
+	}
+	trustedData.UntrustedAlliances = untrustedAlliances
+
+	return events
+}
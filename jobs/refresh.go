@@ -0,0 +1,382 @@
+package jobs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/notify"
+	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/trust"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const (
+	refreshActor = "refresh job"
+
+	// lastRefreshSetting records when the trust lists were last refreshed
+	lastRefreshSetting = "last_refresh"
+
+	// maxConcurrentCorpLookups limits the number of parallel corporation requests made during a refresh
+	maxConcurrentCorpLookups = 10
+)
+
+// affiliationSnapshot holds the current affiliation data fetched from ESI for every list entry
+type affiliationSnapshot struct {
+	characters     map[int64]model.CharacterAffiliation
+	corpAlliances  map[int64]int64
+	names          map[int64]string
+	failedCorpIDs  map[int64]bool
+	refreshStarted time.Time
+}
+
+// StartRefresh refreshes trust list metadata immediately and then on every interval
+func StartRefresh(st persist.Store, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := RefreshTrustLists(st); err != nil {
+				xlog.Logf("Failed to refresh trust lists: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RefreshTrustLists re-resolves the affiliation of every entry on every list, updates names, including alliance
+// entry names, and records an audit event for each character or corporation that changed affiliation
+func RefreshTrustLists(st persist.Store) error {
+	defer xlog.Logt("RefreshTrustLists", time.Now())
+
+	lists, err := st.LoadTrustLists()
+	if err != nil {
+		return fmt.Errorf("failed to load trust lists: %v", err)
+	}
+
+	// Fetch every entry once, however many lists it is on
+	combined := &model.TrustedCharacters{}
+	for _, list := range lists {
+		trustedData, err := st.LoadTrustedCharacters(list.Name)
+		if err != nil {
+			return fmt.Errorf("failed to load trusted data for list %s: %v", list.Name, err)
+		}
+		combined.TrustedCharacters = append(combined.TrustedCharacters, trustedData.TrustedCharacters...)
+		combined.UntrustedCharacters = append(combined.UntrustedCharacters, trustedData.UntrustedCharacters...)
+		combined.TrustedCorporations = append(combined.TrustedCorporations, trustedData.TrustedCorporations...)
+		combined.UntrustedCorporations = append(combined.UntrustedCorporations, trustedData.UntrustedCorporations...)
+		combined.TrustedAlliances = append(combined.TrustedAlliances, trustedData.TrustedAlliances...)
+		combined.UntrustedAlliances = append(combined.UntrustedAlliances, trustedData.UntrustedAlliances...)
+	}
+
+	snapshot, err := fetchAffiliations(combined)
+	if err != nil {
+		return err
+	}
+
+	// Apply the changes to the current lists so edits made while fetching are not overwritten
+	var events []model.AuditEvent
+	for _, list := range lists {
+		var listEvents []model.AuditEvent
+		err = st.UpdateTrusted(list.Name, func(trustedData *model.TrustedCharacters) error {
+			previousConflicts := trust.FindConflicts(trustedData)
+			var changed bool
+			listEvents, changed = applyAffiliations(trustedData, snapshot)
+			if !changed {
+				// Nothing was updated, so the conflicts cannot have changed either
+				return persist.ErrUnchanged
+			}
+			listEvents = append(listEvents, trust.ConflictEvents(previousConflicts, trust.FindConflicts(trustedData), refreshActor)...)
+			return nil
+		})
+		if err == persist.ErrListNotFound {
+			// Deleted while fetching
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save trusted data for list %s: %v", list.Name, err)
+		}
+
+		for i := range listEvents {
+			listEvents[i].List = list.Name
+		}
+		events = append(events, listEvents...)
+	}
+
+	notify.Notify(events...)
+	if err := st.AppendAuditEvents(events...); err != nil {
+		return fmt.Errorf("failed to record affiliation changes: %v", err)
+	}
+
+	if err := st.SaveSetting(lastRefreshSetting, snapshot.refreshStarted.Format(time.RFC3339)); err != nil {
+		xlog.Logf("Failed to record refresh time: %v", err)
+	}
+
+	xlog.Logf("Refreshed %d trust lists, %d affiliation changes", len(lists), len(events))
+	return nil
+}
+
+func fetchAffiliations(trustedData *model.TrustedCharacters) (*affiliationSnapshot, error) {
+	snapshot := &affiliationSnapshot{
+		characters:     make(map[int64]model.CharacterAffiliation),
+		corpAlliances:  make(map[int64]int64),
+		failedCorpIDs:  make(map[int64]bool),
+		refreshStarted: time.Now(),
+	}
+
+	var characterIDs []int64
+	for _, char := range trustedData.TrustedCharacters {
+		characterIDs = append(characterIDs, char.CharacterID)
+	}
+	for _, char := range trustedData.UntrustedCharacters {
+		characterIDs = append(characterIDs, char.CharacterID)
+	}
+
+	// A corporation can be on several lists but only needs looking up once
+	var corporationIDs []int64
+	seenCorporations := make(map[int64]bool)
+	for _, corps := range [][]model.TrustedCorporation{trustedData.TrustedCorporations, trustedData.UntrustedCorporations} {
+		for _, corp := range corps {
+			if !seenCorporations[corp.CorporationID] {
+				seenCorporations[corp.CorporationID] = true
+				corporationIDs = append(corporationIDs, corp.CorporationID)
+			}
+		}
+	}
+
+	affiliations, err := eveapi.GetAffiliations(characterIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get character affiliations: %v", err)
+	}
+
+	ids := append([]int64{}, characterIDs...)
+	for _, alliances := range [][]model.TrustedAlliance{trustedData.TrustedAlliances, trustedData.UntrustedAlliances} {
+		for _, alliance := range alliances {
+			ids = append(ids, alliance.AllianceID)
+		}
+	}
+	for _, affiliation := range affiliations {
+		snapshot.characters[affiliation.CharacterID] = affiliation
+		ids = append(ids, affiliation.CorporationID, affiliation.AllianceID)
+	}
+
+	// There is no bulk lookup for corporation alliances, so fetch each corporation in parallel
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentCorpLookups)
+	for _, corporationID := range corporationIDs {
+		wg.Add(1)
+		go func(corporationID int64) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			corpInfo, err := eveapi.GetCorpInfo(corporationID, nil)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				xlog.Logf("Failed to refresh corporation %d: %v", corporationID, err)
+				snapshot.failedCorpIDs[corporationID] = true
+				return
+			}
+			if corpInfo.AllianceID != nil {
+				snapshot.corpAlliances[corporationID] = int64(*corpInfo.AllianceID)
+			} else {
+				snapshot.corpAlliances[corporationID] = 0
+			}
+			ids = append(ids, corporationID, snapshot.corpAlliances[corporationID])
+		}(corporationID)
+	}
+	wg.Wait()
+
+	entities, err := eveapi.ResolveIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve names: %v", err)
+	}
+	snapshot.names = eveapi.NamesByID(entities)
+
+	return snapshot, nil
+}
+
+// applyAffiliations updates every entry from the snapshot, stamping entries that changed affiliation with the list revision.
+// It reports whether any entry was modified, including name-only updates that produce no event.
+func applyAffiliations(trustedData *model.TrustedCharacters, snapshot *affiliationSnapshot) ([]model.AuditEvent, bool) {
+	revision := trustedData.Revision
+	var events []model.AuditEvent
+	changed := false
+	for _, characters := range [][]model.TrustedCharacter{trustedData.TrustedCharacters, trustedData.UntrustedCharacters} {
+		listEvents, listChanged := applyCharacterAffiliations(characters, snapshot, revision)
+		events = append(events, listEvents...)
+		changed = changed || listChanged
+	}
+	for _, corporations := range [][]model.TrustedCorporation{trustedData.TrustedCorporations, trustedData.UntrustedCorporations} {
+		listEvents, listChanged := applyCorporationAffiliations(corporations, snapshot, revision)
+		events = append(events, listEvents...)
+		changed = changed || listChanged
+	}
+	for _, alliances := range [][]model.TrustedAlliance{trustedData.TrustedAlliances, trustedData.UntrustedAlliances} {
+		changed = applyAllianceNames(alliances, snapshot) || changed
+	}
+	return events, changed
+}
+
+// applyAllianceNames updates the names of alliance entries, alliances have no affiliation to follow
+func applyAllianceNames(alliances []model.TrustedAlliance, snapshot *affiliationSnapshot) bool {
+	changed := false
+	for i := range alliances {
+		if name := snapshot.names[alliances[i].AllianceID]; name != "" && name != alliances[i].AllianceName {
+			alliances[i].AllianceName = name
+			changed = true
+		}
+	}
+	return changed
+}
+
+func applyCharacterAffiliations(characters []model.TrustedCharacter, snapshot *affiliationSnapshot, revision int64) ([]model.AuditEvent, bool) {
+	var events []model.AuditEvent
+	changed := false
+	for i := range characters {
+		char := &characters[i]
+		affiliation, ok := snapshot.characters[char.CharacterID]
+		if !ok {
+			continue
+		}
+		before := *char
+
+		if name := snapshot.names[char.CharacterID]; name != "" {
+			char.CharacterName = name
+		}
+
+		// Names ESI left out of its response keep their stored value, an entry that moved to an organisation
+		// whose name is unknown waits for a later refresh
+		newCorporationName, corporationOK := refreshedName(snapshot, affiliation.CorporationID, char.CorporationID, char.CorporationName)
+		newAllianceName, allianceOK := refreshedName(snapshot, affiliation.AllianceID, char.AllianceID, char.AllianceName)
+		if !corporationOK || !allianceOK {
+			xlog.Logf("Not updating the affiliation of character %d, the name of its new corporation or alliance is unknown", char.CharacterID)
+			changed = changed || characterChanged(before, *char)
+			continue
+		}
+
+		if char.CorporationID != 0 && char.CorporationID != affiliation.CorporationID {
+			events = append(events, model.AuditEvent{
+				Time:       snapshot.refreshStarted,
+				Type:       model.EventCorporationChanged,
+				Actor:      refreshActor,
+				EntityType: "character",
+				EntityID:   char.CharacterID,
+				EntityName: char.CharacterName,
+				Detail:     fmt.Sprintf("moved from %s to %s", corporationLabel(char.CorporationName, char.CorporationID), corporationLabel(newCorporationName, affiliation.CorporationID)),
+			})
+			changedAt := snapshot.refreshStarted
+			char.PreviousCorporationName = char.CorporationName
+			char.ChangedAt = &changedAt
+			char.Revision = revision
+		}
+
+		char.CorporationID = affiliation.CorporationID
+		char.CorporationName = newCorporationName
+		char.AllianceID = affiliation.AllianceID
+		char.AllianceName = newAllianceName
+		changed = changed || characterChanged(before, *char)
+	}
+	return events, changed
+}
+
+func applyCorporationAffiliations(corporations []model.TrustedCorporation, snapshot *affiliationSnapshot, revision int64) ([]model.AuditEvent, bool) {
+	var events []model.AuditEvent
+	changed := false
+	for i := range corporations {
+		corp := &corporations[i]
+		allianceID, ok := snapshot.corpAlliances[corp.CorporationID]
+		if !ok || snapshot.failedCorpIDs[corp.CorporationID] {
+			continue
+		}
+		before := *corp
+
+		if name := snapshot.names[corp.CorporationID]; name != "" {
+			corp.CorporationName = name
+		}
+
+		newAllianceName, ok := refreshedName(snapshot, allianceID, corp.AllianceID, corp.AllianceName)
+		if !ok {
+			xlog.Logf("Not updating the alliance of corporation %d, the name of its new alliance is unknown", corp.CorporationID)
+			changed = changed || corporationChanged(before, *corp)
+			continue
+		}
+
+		if corp.AllianceID != allianceID {
+			events = append(events, model.AuditEvent{
+				Time:       snapshot.refreshStarted,
+				Type:       model.EventAllianceChanged,
+				Actor:      refreshActor,
+				EntityType: "corporation",
+				EntityID:   corp.CorporationID,
+				EntityName: corp.CorporationName,
+				Detail:     fmt.Sprintf("moved from %s to %s", allianceLabel(corp.AllianceName, corp.AllianceID), allianceLabel(newAllianceName, allianceID)),
+			})
+			changedAt := snapshot.refreshStarted
+			corp.PreviousAllianceName = corp.AllianceName
+			corp.ChangedAt = &changedAt
+			corp.Revision = revision
+		}
+
+		corp.AllianceID = allianceID
+		corp.AllianceName = newAllianceName
+		changed = changed || corporationChanged(before, *corp)
+	}
+	return events, changed
+}
+
+// characterChanged reports whether a refresh modified any field it manages on a character entry
+func characterChanged(before, after model.TrustedCharacter) bool {
+	return before.CharacterName != after.CharacterName ||
+		before.CorporationID != after.CorporationID ||
+		before.CorporationName != after.CorporationName ||
+		before.AllianceID != after.AllianceID ||
+		before.AllianceName != after.AllianceName ||
+		before.Revision != after.Revision
+}
+
+// corporationChanged reports whether a refresh modified any field it manages on a corporation entry
+func corporationChanged(before, after model.TrustedCorporation) bool {
+	return before.CorporationName != after.CorporationName ||
+		before.AllianceID != after.AllianceID ||
+		before.AllianceName != after.AllianceName ||
+		before.Revision != after.Revision
+}
+
+// refreshedName returns the name to store for id. A name missing from the lookup keeps the stored one when id has not
+// changed, and is reported as unknown when it has.
+func refreshedName(snapshot *affiliationSnapshot, id int64, currentID int64, current string) (string, bool) {
+	if id == 0 {
+		return "", true
+	}
+	if name := snapshot.names[id]; name != "" {
+		return name, true
+	}
+	if id == currentID {
+		return current, true
+	}
+	return "", false
+}
+
+func corporationLabel(name string, id int64) string {
+	if name == "" {
+		return fmt.Sprintf("corporation %d", id)
+	}
+	return name
+}
+
+func allianceLabel(name string, id int64) string {
+	if id == 0 {
+		return "no alliance"
+	}
+	if name == "" {
+		return fmt.Sprintf("alliance %d", id)
+	}
+	return name
+}
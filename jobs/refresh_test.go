@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+func TestApplyAffiliationsKeepsNamesMissingFromLookup(t *testing.T) {
+	trustedData := &model.TrustedCharacters{
+		TrustedCharacters: []model.TrustedCharacter{
+			{CharacterID: 1, CharacterName: "Pilot", CorporationID: 10, CorporationName: "Corp", AllianceID: 20, AllianceName: "Alliance"},
+		},
+		TrustedCorporations: []model.TrustedCorporation{
+			{CorporationID: 10, CorporationName: "Corp", AllianceID: 20, AllianceName: "Alliance"},
+		},
+	}
+	// ESI left every name out of its response
+	snapshot := &affiliationSnapshot{
+		characters:     map[int64]model.CharacterAffiliation{1: {CharacterID: 1, CorporationID: 10, AllianceID: 20}},
+		corpAlliances:  map[int64]int64{10: 20},
+		names:          map[int64]string{},
+		failedCorpIDs:  map[int64]bool{},
+		refreshStarted: time.Now(),
+	}
+
+	events, changed := applyAffiliations(trustedData, snapshot)
+	if changed || len(events) != 0 {
+		t.Errorf("got changed=%v and %d events, want no change", changed, len(events))
+	}
+	char := trustedData.TrustedCharacters[0]
+	if char.CharacterName != "Pilot" || char.CorporationName != "Corp" || char.AllianceName != "Alliance" {
+		t.Errorf("character names were overwritten: %+v", char)
+	}
+	if corp := trustedData.TrustedCorporations[0]; corp.CorporationName != "Corp" || corp.AllianceName != "Alliance" {
+		t.Errorf("corporation names were overwritten: %+v", corp)
+	}
+}
+
+func TestApplyAffiliationsWaitsForNewAffiliationName(t *testing.T) {
+	trustedData := &model.TrustedCharacters{
+		TrustedCharacters: []model.TrustedCharacter{
+			{CharacterID: 1, CharacterName: "Pilot", CorporationID: 10, CorporationName: "Corp"},
+		},
+		TrustedCorporations: []model.TrustedCorporation{
+			{CorporationID: 10, CorporationName: "Corp", AllianceID: 20, AllianceName: "Alliance"},
+		},
+	}
+	snapshot := &affiliationSnapshot{
+		characters:     map[int64]model.CharacterAffiliation{1: {CharacterID: 1, CorporationID: 11}},
+		corpAlliances:  map[int64]int64{10: 0},
+		names:          map[int64]string{},
+		failedCorpIDs:  map[int64]bool{},
+		refreshStarted: time.Now(),
+	}
+
+	events, _ := applyAffiliations(trustedData, snapshot)
+
+	// The character's new corporation has no name yet, so it is left until a later refresh
+	if char := trustedData.TrustedCharacters[0]; char.CorporationID != 10 || char.CorporationName != "Corp" {
+		t.Errorf("character moved to a corporation with an unknown name: %+v", char)
+	}
+	// Leaving an alliance needs no name
+	if len(events) != 1 || events[0].Detail != "moved from Alliance to no alliance" {
+		t.Fatalf("got events %+v, want the corporation leaving its alliance", events)
+	}
+}
+
+func TestAllianceLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		id   int64
+		want string
+	}{
+		{"", 0, "no alliance"},
+		{"", 5, "alliance 5"},
+		{"Alliance", 5, "Alliance"},
+	}
+	for _, tt := range tests {
+		if got := allianceLabel(tt.name, tt.id); got != tt.want {
+			t.Errorf("allianceLabel(%q, %d) = %q, want %q", tt.name, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestApplyAffiliationsRenamesAlliances(t *testing.T) {
+	trustedData := &model.TrustedCharacters{
+		TrustedAlliances:   []model.TrustedAlliance{{AllianceID: 20, AllianceName: "Old Name"}},
+		UntrustedAlliances: []model.TrustedAlliance{{AllianceID: 21, AllianceName: "Unchanged"}},
+	}
+	snapshot := &affiliationSnapshot{
+		characters:     map[int64]model.CharacterAffiliation{},
+		corpAlliances:  map[int64]int64{},
+		names:          map[int64]string{20: "New Name"},
+		failedCorpIDs:  map[int64]bool{},
+		refreshStarted: time.Now(),
+	}
+
+	if _, changed := applyAffiliations(trustedData, snapshot); !changed {
+		t.Error("renaming an alliance was not reported as a change")
+	}
+	if name := trustedData.TrustedAlliances[0].AllianceName; name != "New Name" {
+		t.Errorf("got alliance name %q, want %q", name, "New Name")
+	}
+	if name := trustedData.UntrustedAlliances[0].AllianceName; name != "Unchanged" {
+		t.Errorf("alliance missing from the lookup was renamed to %q", name)
+	}
+}
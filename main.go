@@ -1,22 +1,31 @@
 package main
 
 import (
-	"encoding/base64"
+	"flag"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/gambtho/whototrust/eveapi"
 	"github.com/gambtho/whototrust/handlers"
+	"github.com/gambtho/whototrust/jobs"
+	"github.com/gambtho/whototrust/notify"
 	"github.com/gambtho/whototrust/persist"
+	"github.com/gambtho/whototrust/secrets"
 	"github.com/gambtho/whototrust/xlog"
 )
 
 var version = "0.0.0"
 
 func main() {
+	rekey := flag.Bool("rekey", false, "re-encrypt all stored identities with the current data key and exit")
+	flag.Parse()
+
 	xlog.Logf("Starting application, version %s", version)
 
 	// Read environment variables
@@ -24,7 +33,7 @@ func main() {
 	clientSecret := os.Getenv("EVE_CLIENT_SECRET")
 	callbackURL := os.Getenv("EVE_CALLBACK_URL")
 
-	if clientID == "" || clientSecret == "" || callbackURL == "" {
+	if !*rekey && (clientID == "" || clientSecret == "" || callbackURL == "") {
 		log.Fatalf("EVE_CLIENT_ID, EVE_CLIENT_SECRET, and EVE_CALLBACK_URL must be set")
 	}
 
@@ -33,33 +42,93 @@ func main() {
 		port = "8080"
 	}
 
-	var key []byte
-	var err error
-	secret := os.Getenv("SECRET_KEY")
-	if secret == "" {
-		key, err = handlers.GenerateSecret()
-		if err != nil {
-			log.Fatalf("Failed to generate key: %v", err)
-		}
-		secret = base64.StdEncoding.EncodeToString(key)
+	// Separate keys for cookie authentication, cookie encryption and data at rest
+	appKeys, err := secrets.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load keys: %v", err)
+	}
+	if *rekey && os.Getenv("SECRET_KEY") == "" && os.Getenv("DATA_KEY") == "" {
+		log.Fatalf("SECRET_KEY or DATA_KEY must be set to re-encrypt identities")
+	}
+	if appKeys.GeneratedSecret != "" {
+		log.Printf("Generated key: %s -- this should only be used for testing", appKeys.GeneratedSecret)
+	}
 
-		log.Printf("Generated key: %s -- this should only be used for testing", secret)
-	} else {
-		key, err = base64.StdEncoding.DecodeString(secret)
+	// Open the configured storage backend
+	backups := 0
+	if value := os.Getenv("TRUST_LIST_BACKUPS"); value != "" {
+		backups, err = strconv.Atoi(value)
 		if err != nil {
-			log.Fatalf("Failed to decode key: %v", err)
+			log.Fatalf("Failed to parse TRUST_LIST_BACKUPS: %v", err)
 		}
+		if backups == 0 {
+			backups = -1
+		}
+	}
+
+	dataStore, err := persist.Open(persist.Config{
+		Backend:      os.Getenv("STORAGE_BACKEND"),
+		Key:          appKeys.Data[0],
+		PreviousKeys: appKeys.Data[1:],
+		Backups:      backups,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
 	}
+	defer dataStore.Close()
 
-	// Initialize configuration directory
-	if err = persist.Initialize(key); err != nil {
-		log.Fatalf("Failed to initialize identity: %v", err)
+	if *rekey {
+		rekeyed, err := dataStore.RekeyIdentities()
+		if err != nil {
+			log.Fatalf("Failed to re-encrypt identities: %v", err)
+		}
+		xlog.Logf("Re-encrypted %d identities with the current data key", rekeyed)
+		return
 	}
+	handlers.SetStore(dataStore)
 
 	// Initialize OAuth2 configuration
 	eveapi.InitializeOAuth(clientID, clientSecret, callbackURL)
 
-	sessionStore := handlers.NewSessionService(secret)
+	// Send list changes and conflicts to any configured webhooks
+	notify.Initialize(notify.ConfigFromEnv())
+
+	// Keep trust list affiliations current
+	refreshInterval := envDuration("REFRESH_INTERVAL", time.Hour)
+	if refreshInterval > 0 {
+		jobs.StartRefresh(dataStore, refreshInterval)
+	}
+
+	// Remove or demote entries whose expiry has passed
+	expiryInterval := envDuration("EXPIRY_SWEEP_INTERVAL", 5*time.Minute)
+	if expiryInterval > 0 {
+		expiryAction := os.Getenv("EXPIRED_ENTRY_ACTION")
+		if expiryAction != jobs.ExpiryDemote {
+			expiryAction = jobs.ExpiryRemove
+		}
+		jobs.StartExpirySweep(dataStore, expiryInterval, expiryAction)
+	}
+
+	sessionStore := handlers.NewSessionService(
+		envDuration("SESSION_IDLE_TIMEOUT", 7*24*time.Hour),
+		envDuration("SESSION_MAX_AGE", 30*24*time.Hour),
+		appKeys.CookieKeyPairs()...,
+	)
+
+	// Characters allowed to use the admin endpoints
+	var adminIDs []int64
+	for _, value := range strings.Split(os.Getenv("ADMIN_CHARACTER_IDS"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Fatalf("Failed to parse ADMIN_CHARACTER_IDS: %v", err)
+		}
+		adminIDs = append(adminIDs, id)
+	}
+	handlers.SetAdmins(adminIDs)
 
 	// Router setup
 	r := mux.NewRouter()
@@ -74,28 +143,81 @@ func main() {
 	r.HandleFunc("/auth-character", handlers.AuthCharacterHandler)
 	r.HandleFunc("/logout", handlers.LogoutHandler(sessionStore))
 
-	r.HandleFunc("/update-comment", handlers.UpdateCommentHandler)
+	r.HandleFunc("/update-comment", handlers.UpdateCommentHandler(sessionStore))
 
 	r.HandleFunc("/validate-and-add-trusted-character", handlers.AddTrustedCharacterHandler(sessionStore)) // POST
-	r.HandleFunc("/remove-trusted-character", handlers.RemoveTrustedCharacterHandler)
+	r.HandleFunc("/remove-trusted-character", handlers.RemoveTrustedCharacterHandler(sessionStore))
 
 	r.HandleFunc("/validate-and-add-trusted-corporation", handlers.AddTrustedCorporationHandler(sessionStore)) // POST
-	r.HandleFunc("/remove-trusted-corporation", handlers.RemoveTrustedCorporationHandler)
+	r.HandleFunc("/remove-trusted-corporation", handlers.RemoveTrustedCorporationHandler(sessionStore))
+
+	r.HandleFunc("/validate-and-add-trusted-alliance", handlers.AddTrustedAllianceHandler(sessionStore)) // POST
+	r.HandleFunc("/remove-trusted-alliance", handlers.RemoveTrustedAllianceHandler(sessionStore))
+
+	r.HandleFunc("/conflicts", handlers.ConflictsHandler(sessionStore)) // GET
+	r.HandleFunc("/export", handlers.ExportHandler(sessionStore)).Methods(http.MethodGet)
+
+	r.HandleFunc("/lists", handlers.ListsHandler(sessionStore)).Methods(http.MethodGet)
+	r.HandleFunc("/lists", handlers.CreateListHandler(sessionStore)).Methods(http.MethodPost)
+	r.HandleFunc("/lists/update", handlers.UpdateListHandler(sessionStore)).Methods(http.MethodPost)
+	r.HandleFunc("/lists/delete", handlers.DeleteListHandler(sessionStore)).Methods(http.MethodPost)
+	r.HandleFunc("/subscriptions", handlers.SubscribeHandler(sessionStore)).Methods(http.MethodPost)
+
+	r.HandleFunc("/sessions", handlers.SessionsHandler(sessionStore))             // GET
+	r.HandleFunc("/sessions/revoke", handlers.RevokeSessionHandler(sessionStore)) // POST
+
+	r.HandleFunc("/tokens", handlers.TokensHandler(sessionStore)).Methods(http.MethodGet)
+	r.HandleFunc("/tokens", handlers.CreateTokenHandler(sessionStore)).Methods(http.MethodPost)
+	r.HandleFunc("/tokens/revoke", handlers.RevokeTokenHandler(sessionStore)).Methods(http.MethodPost)
 
 	r.HandleFunc("/add-contacts", handlers.AddContactsHandler(sessionStore))
 	r.HandleFunc("/delete-contacts", handlers.DeleteContactsHandler(sessionStore))
 
 	r.HandleFunc("/validate-and-add-untrusted-character", handlers.AddUntrustedCharacterHandler(sessionStore)) // POST
-	r.HandleFunc("/remove-untrusted-character", handlers.RemoveUntrustedCharacterHandler)
+	r.HandleFunc("/remove-untrusted-character", handlers.RemoveUntrustedCharacterHandler(sessionStore))
 
 	r.HandleFunc("/validate-and-add-untrusted-corporation", handlers.AddUntrustedCorporationHandler(sessionStore)) // POST
-	r.HandleFunc("/remove-untrusted-corporation", handlers.RemoveUntrustedCorporationHandler)
+	r.HandleFunc("/remove-untrusted-corporation", handlers.RemoveUntrustedCorporationHandler(sessionStore))
+
+	r.HandleFunc("/validate-and-add-untrusted-alliance", handlers.AddUntrustedAllianceHandler(sessionStore)) // POST
+	r.HandleFunc("/remove-untrusted-alliance", handlers.RemoveUntrustedAllianceHandler(sessionStore))
+
+	// JSON API
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/openapi.json", handlers.OpenAPIHandler).Methods(http.MethodGet)
+	api.HandleFunc("/lists", handlers.APIListsHandler(sessionStore)).Methods(http.MethodGet)
+	api.HandleFunc("/lists/{list}/entries", handlers.APIEntriesHandler(sessionStore)).Methods(http.MethodGet)
+	api.HandleFunc("/lists/{list}/entries", handlers.APICreateEntryHandler(sessionStore)).Methods(http.MethodPost)
+	api.HandleFunc("/lists/{list}/entries/{status}/{type}/{id}", handlers.APIEntryHandler(sessionStore)).Methods(http.MethodGet)
+	api.HandleFunc("/lists/{list}/entries/{status}/{type}/{id}", handlers.APIUpdateEntryHandler(sessionStore)).Methods(http.MethodPatch)
+	api.HandleFunc("/lists/{list}/entries/{status}/{type}/{id}", handlers.APIDeleteEntryHandler(sessionStore)).Methods(http.MethodDelete)
+	api.HandleFunc("/lists/{list}/import", handlers.APIImportHandler(sessionStore)).Methods(http.MethodPost)
+	api.HandleFunc("/lists/{list}/analyze", handlers.APIAnalyzeHandler(sessionStore)).Methods(http.MethodPost)
+	api.HandleFunc("/evaluate/{id}", handlers.APIEvaluateHandler(sessionStore)).Methods(http.MethodGet)
 
 	// admin routes
 	r.HandleFunc("/reset-identities", handlers.ResetIdentitiesHandler(sessionStore))
+	r.HandleFunc("/admin/sessions", handlers.AdminSessionsHandler(sessionStore)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/sessions/logout", handlers.ForceLogoutHandler(sessionStore)).Methods(http.MethodPost)
+	r.HandleFunc("/admin/quarantine", handlers.QuarantineHandler(sessionStore)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/quarantine/restore", handlers.RestoreQuarantineHandler(sessionStore)).Methods(http.MethodPost)
 
 	http.Handle("/", r)
 
 	xlog.Logf("Listening on port %s", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
+
+// envDuration parses a Go duration from an environment variable, returning fallback if it is not set
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", name, err)
+	}
+	return duration
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -22,12 +23,20 @@ type HomeData struct {
 	TrustedCorporations   []TrustedCorporation
 	UntrustedCharacters   []TrustedCharacter
 	UntrustedCorporations []TrustedCorporation
+	TrustedAlliances      []TrustedAlliance
+	UntrustedAlliances    []TrustedAlliance
+	Conflicts             []TrustConflict
+	// Lists are the trust lists the user can see, CurrentList is the one shown
+	Lists       []TrustList
+	CurrentList TrustList
+	CanEdit     bool
 }
 
 // Character represents the user information
 type Character struct {
 	User
 	CorporationID int64  `json:"CorporationID"`
+	AllianceID    int64  `json:"AllianceID,omitempty"`
 	Portrait      string `json:"Portrait"`
 }
 
@@ -62,9 +71,23 @@ type TrustedCharacter struct {
 	CharacterName   string    `json:"CharacterName"`
 	CorporationID   int64     `json:"CorporationID"`
 	CorporationName string    `json:"CorporationName"`
+	AllianceID      int64     `json:"AllianceID,omitempty"`
+	AllianceName    string    `json:"AllianceName,omitempty"`
 	AddedBy         string    `json:"AddedBy"`
 	DateAdded       time.Time `json:"DateAdded"`
 	Comment         string    `json:"Comment"`
+	// Standing is the contact standing synced for the entry, nil uses the default
+	Standing *float64 `json:"Standing,omitempty"`
+	// ExpiresAt is when the expiry job removes or demotes the entry, nil keeps it until it is removed
+	ExpiresAt *time.Time `json:"ExpiresAt,omitempty"`
+	// Tags categorize the entry, for filtering and for limiting which entries a subscription syncs
+	Tags []string `json:"Tags,omitempty"`
+	// Revision is the trust list revision the entry was last changed at
+	Revision int64 `json:"Revision"`
+
+	// PreviousCorporationName and ChangedAt are set by the refresh job when the character changes corporation
+	PreviousCorporationName string     `json:"PreviousCorporationName,omitempty"`
+	ChangedAt               *time.Time `json:"ChangedAt,omitempty"`
 }
 
 type TrustedCorporation struct {
@@ -75,6 +98,34 @@ type TrustedCorporation struct {
 	DateAdded       time.Time `json:"DateAdded"`
 	AddedBy         string    `json:"AddedBy"`
 	Comment         string    `json:"Comment"`
+	// Standing is the contact standing synced for the entry, nil uses the default
+	Standing *float64 `json:"Standing,omitempty"`
+	// ExpiresAt is when the expiry job removes or demotes the entry, nil keeps it until it is removed
+	ExpiresAt *time.Time `json:"ExpiresAt,omitempty"`
+	// Tags categorize the entry, for filtering and for limiting which entries a subscription syncs
+	Tags []string `json:"Tags,omitempty"`
+	// Revision is the trust list revision the entry was last changed at
+	Revision int64 `json:"Revision"`
+
+	// PreviousAllianceName and ChangedAt are set by the refresh job when the corporation changes alliance
+	PreviousAllianceName string     `json:"PreviousAllianceName,omitempty"`
+	ChangedAt            *time.Time `json:"ChangedAt,omitempty"`
+}
+
+type TrustedAlliance struct {
+	AllianceID   int64     `json:"AllianceID"`
+	AllianceName string    `json:"AllianceName"`
+	DateAdded    time.Time `json:"DateAdded"`
+	AddedBy      string    `json:"AddedBy"`
+	Comment      string    `json:"Comment"`
+	// Standing is the contact standing synced for the entry, nil uses the default
+	Standing *float64 `json:"Standing,omitempty"`
+	// ExpiresAt is when the expiry job removes or demotes the entry, nil keeps it until it is removed
+	ExpiresAt *time.Time `json:"ExpiresAt,omitempty"`
+	// Tags categorize the entry, for filtering and for limiting which entries a subscription syncs
+	Tags []string `json:"Tags,omitempty"`
+	// Revision is the trust list revision the entry was last changed at
+	Revision int64 `json:"Revision"`
 }
 
 type TrustedCharacters struct {
@@ -82,6 +133,116 @@ type TrustedCharacters struct {
 	TrustedCorporations   []TrustedCorporation `json:"corporations"`
 	UntrustedCharacters   []TrustedCharacter   `json:"untrusted_characters"`
 	UntrustedCorporations []TrustedCorporation `json:"untrusted_corporations"`
+	// TrustedAlliances and UntrustedAlliances were added later, so lists stored before them have neither
+	TrustedAlliances   []TrustedAlliance `json:"alliances,omitempty"`
+	UntrustedAlliances []TrustedAlliance `json:"untrusted_alliances,omitempty"`
+	// Revision increases every time the list is saved
+	Revision int64 `json:"revision"`
+}
+
+// TrustEntry is a trust list entry of any status and type, as returned by the JSON API
+type TrustEntry struct {
+	Status          string     `json:"status"`
+	Type            string     `json:"type"`
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	CorporationID   int64      `json:"corporation_id,omitempty"`
+	CorporationName string     `json:"corporation_name,omitempty"`
+	AllianceID      int64      `json:"alliance_id,omitempty"`
+	AllianceName    string     `json:"alliance_name,omitempty"`
+	AddedBy         string     `json:"added_by"`
+	DateAdded       time.Time  `json:"date_added"`
+	Comment         string     `json:"comment"`
+	Standing        *float64   `json:"standing,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+	Revision        int64      `json:"revision"`
+}
+
+// DefaultList is the trust list every instance starts with, kept in the original trust list storage
+const DefaultList = "default"
+
+// Trust list access levels
+const (
+	// AccessPublic lists can be seen and subscribed to by every user
+	AccessPublic = "public"
+	// AccessRestricted lists can only be seen by their owner, viewers and editors
+	AccessRestricted = "restricted"
+)
+
+// TrustList describes a named trust list and who may use it. Viewers and Editors hold character,
+// corporation or alliance IDs. A list with no editors can be edited by anyone who can see it.
+type TrustList struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Owner       int64     `json:"owner"`
+	OwnerName   string    `json:"owner_name,omitempty"`
+	Access      string    `json:"access"`
+	Viewers     []int64   `json:"viewers,omitempty"`
+	Editors     []int64   `json:"editors,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Subscription is a trust list a character syncs its contacts from. When Tags is set only trusted entries
+// with at least one of the tags are synced; untrusted entries are always removed.
+type Subscription struct {
+	List string   `json:"list"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// UnmarshalJSON also reads subscriptions stored before they had tags, which were just the list name
+func (s *Subscription) UnmarshalJSON(data []byte) error {
+	var list string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = Subscription{List: list}
+		return nil
+	}
+
+	type subscription Subscription
+	return json.Unmarshal(data, (*subscription)(s))
+}
+
+// Audit event types
+const (
+	EventEntryAdded         = "entry_added"
+	EventEntryRemoved       = "entry_removed"
+	EventCommentChanged     = "comment_changed"
+	EventStandingChanged    = "standing_changed"
+	EventExpiryChanged      = "expiry_changed"
+	EventTagsChanged        = "tags_changed"
+	EventEntryExpired       = "entry_expired"
+	EventCorporationChanged = "corporation_changed"
+	EventAllianceChanged    = "alliance_changed"
+	EventConflictDetected   = "conflict_detected"
+	EventSyncFailures       = "sync_failures"
+	EventListCreated        = "list_created"
+	EventListUpdated        = "list_updated"
+	EventListDeleted        = "list_deleted"
+)
+
+// AuditEvent records a change made to the trust list, either by a user or by a background job
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Actor      string    `json:"actor"`
+	EntityType string    `json:"entity_type"`
+	EntityID   int64     `json:"entity_id"`
+	EntityName string    `json:"entity_name"`
+	Detail     string    `json:"detail,omitempty"`
+	// List is the trust list the event happened on, empty for events recorded before there were named lists
+	List string `json:"list,omitempty"`
+}
+
+// TrustConflict describes a list entry whose current affiliation places it on the opposing list
+type TrustConflict struct {
+	EntityType   string `json:"entity_type"`
+	EntityID     int64  `json:"entity_id"`
+	EntityName   string `json:"entity_name"`
+	Status       string `json:"status"`
+	ConflictType string `json:"conflict_type"`
+	ConflictID   int64  `json:"conflict_id"`
+	ConflictName string `json:"conflict_name"`
+	Description  string `json:"description"`
 }
 
 // CharacterSearchResponse represents the array of character IDs returned from the search
@@ -89,6 +250,34 @@ type CharacterSearchResponse struct {
 	CharacterIDs []int32 `json:"get_characters_character_id_search_character"`
 }
 
+// UniverseEntity is an id and name pair returned by the universe endpoints
+type UniverseEntity struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+// UniverseIDsResponse represents the entities returned from a bulk name lookup
+type UniverseIDsResponse struct {
+	Characters   []UniverseEntity `json:"characters,omitempty"`
+	Corporations []UniverseEntity `json:"corporations,omitempty"`
+	Alliances    []UniverseEntity `json:"alliances,omitempty"`
+}
+
+// ContactLabel is a label a character created in game to group its contacts
+type ContactLabel struct {
+	LabelID   int64  `json:"label_id"`
+	LabelName string `json:"label_name"`
+}
+
+// CharacterAffiliation represents the current corporation, alliance and faction of a character
+type CharacterAffiliation struct {
+	CharacterID   int64 `json:"character_id"`
+	CorporationID int64 `json:"corporation_id"`
+	AllianceID    int64 `json:"alliance_id,omitempty"`
+	FactionID     int64 `json:"faction_id,omitempty"`
+}
+
 type CorporationInfo struct {
 	AllianceID    *int32  `json:"alliance_id,omitempty"`     // CorporationID of the alliance, if any
 	CEOId         int32   `json:"ceo_id"`                    // CEO CorporationID (required)
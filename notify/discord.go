@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+const (
+	webhookUsername = "Who to Trust?"
+	retryBaseDelay  = 1 * time.Second
+	retryMaxDelay   = 30 * time.Second
+)
+
+// Embed colors by event type
+var eventColors = map[string]int{
+	model.EventEntryAdded:         0x00bcd4,
+	model.EventEntryRemoved:       0xffeb3b,
+	model.EventCommentChanged:     0x9e9e9e,
+	model.EventStandingChanged:    0x9e9e9e,
+	model.EventCorporationChanged: 0xff9800,
+	model.EventAllianceChanged:    0xff9800,
+	model.EventConflictDetected:   0xff5252,
+	model.EventSyncFailures:       0xff5252,
+	model.EventListCreated:        0x4caf50,
+	model.EventListUpdated:        0x9e9e9e,
+	model.EventListDeleted:        0xffeb3b,
+}
+
+// Embed titles by event type
+var eventTitles = map[string]string{
+	model.EventEntryAdded:         "Entry added",
+	model.EventEntryRemoved:       "Entry removed",
+	model.EventCommentChanged:     "Comment changed",
+	model.EventStandingChanged:    "Standing changed",
+	model.EventCorporationChanged: "Corporation changed",
+	model.EventAllianceChanged:    "Alliance changed",
+	model.EventConflictDetected:   "Trust conflict",
+	model.EventSyncFailures:       "Contact sync failing",
+	model.EventListCreated:        "List created",
+	model.EventListUpdated:        "List settings changed",
+	model.EventListDeleted:        "List deleted",
+}
+
+type webhookMessage struct {
+	Username string         `json:"username"`
+	Embeds   []webhookEmbed `json:"embeds"`
+}
+
+type webhookEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp"`
+	Footer      *webhookFooter `json:"footer,omitempty"`
+}
+
+type webhookFooter struct {
+	Text string `json:"text"`
+}
+
+func newWebhookMessage(events []model.AuditEvent) webhookMessage {
+	message := webhookMessage{Username: webhookUsername}
+	for _, event := range events {
+		title, ok := eventTitles[event.Type]
+		if !ok {
+			title = event.Type
+		}
+		if event.List != "" && event.List != model.DefaultList && event.EntityType != "list" {
+			title = fmt.Sprintf("%s on %s", title, event.List)
+		}
+
+		description := event.EntityName
+		if event.Detail != "" {
+			description = fmt.Sprintf("**%s** %s", event.EntityName, event.Detail)
+		}
+
+		embed := webhookEmbed{
+			Title:       title,
+			Description: description,
+			Color:       eventColors[event.Type],
+			Timestamp:   event.Time.UTC().Format(time.RFC3339),
+		}
+		if event.Actor != "" {
+			embed.Footer = &webhookFooter{Text: event.Actor}
+		}
+		message.Embeds = append(message.Embeds, embed)
+	}
+	return message
+}
+
+// post sends a message to a webhook, retrying when rate limited or when the webhook returns a server error
+func (n *Notifier) post(webhookURL string, message webhookMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook message: %w", err)
+	}
+
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := n.postOnce(webhookURL, payload)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= n.config.MaxRetries {
+			return err
+		}
+
+		if retryAfter == 0 {
+			retryAfter = delay
+			delay = min(delay*2, retryMaxDelay)
+		}
+		time.Sleep(min(retryAfter, retryMaxDelay))
+	}
+}
+
+// postOnce makes a single delivery attempt. A negative retry delay means the request should not be retried,
+// a zero delay means the caller should pick one.
+func (n *Notifier) postOnce(webhookURL string, payload []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return -1, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.config.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryAfterDelay(resp), fmt.Errorf("webhook rate limited")
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return -1, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, body)
+	}
+}
+
+// retryAfterDelay reads the delay requested by a rate limited webhook response
+func retryAfterDelay(resp *http.Response) time.Duration {
+	var body struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.RetryAfter > 0 {
+		return time.Duration(body.RetryAfter * float64(time.Second))
+	}
+
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	return 0
+}
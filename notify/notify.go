@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const (
+	defaultBatchInterval = 10 * time.Second
+	defaultMaxRetries    = 5
+	defaultQueueSize     = 1000
+
+	// maxEmbedsPerMessage is the most embeds Discord accepts in a single webhook message
+	maxEmbedsPerMessage = 10
+)
+
+// Default is the notifier used by the package level Notify function
+var Default *Notifier
+
+// Config controls where notifications are sent and which events are sent
+type Config struct {
+	WebhookURLs []string
+	// Events lists the enabled event types, an empty map enables every event type
+	Events        map[string]bool
+	BatchInterval time.Duration
+	MaxRetries    int
+	Client        *http.Client
+}
+
+// Notifier batches audit events and posts them to Discord compatible webhooks
+type Notifier struct {
+	config     Config
+	queue      chan model.AuditEvent
+	flush      chan chan struct{}
+	deliveries chan delivery
+	once       sync.Once
+}
+
+// delivery is a batch handed to the delivery goroutine, done is closed once it has been sent when set
+type delivery struct {
+	events []model.AuditEvent
+	done   chan struct{}
+}
+
+// ConfigFromEnv builds a Config from DISCORD_WEBHOOK_URLS, NOTIFY_EVENTS and NOTIFY_BATCH_INTERVAL
+func ConfigFromEnv() Config {
+	config := Config{
+		WebhookURLs:   splitList(os.Getenv("DISCORD_WEBHOOK_URLS")),
+		Events:        make(map[string]bool),
+		BatchInterval: defaultBatchInterval,
+	}
+
+	for _, eventType := range splitList(os.Getenv("NOTIFY_EVENTS")) {
+		config.Events[eventType] = true
+	}
+
+	if value := os.Getenv("NOTIFY_BATCH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			xlog.Logf("Ignoring invalid NOTIFY_BATCH_INTERVAL %q: %v", value, err)
+		} else {
+			config.BatchInterval = interval
+		}
+	}
+
+	return config
+}
+
+// New creates a notifier, call Start to begin delivering events
+func New(config Config) *Notifier {
+	if config.BatchInterval <= 0 {
+		config.BatchInterval = defaultBatchInterval
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.Events == nil {
+		config.Events = make(map[string]bool)
+	}
+
+	return &Notifier{
+		config:     config,
+		queue:      make(chan model.AuditEvent, defaultQueueSize),
+		flush:      make(chan chan struct{}),
+		deliveries: make(chan delivery),
+	}
+}
+
+// Initialize configures and starts the default notifier. Nothing is sent when no webhook URLs are configured.
+func Initialize(config Config) {
+	if len(config.WebhookURLs) == 0 {
+		xlog.Log("No webhook URLs configured, notifications are disabled")
+		return
+	}
+
+	Default = New(config)
+	Default.Start()
+	xlog.Logf("Sending notifications to %d webhooks", len(config.WebhookURLs))
+}
+
+// Notify queues events on the default notifier
+func Notify(events ...model.AuditEvent) {
+	if Default == nil {
+		return
+	}
+	Default.Notify(events...)
+}
+
+// Enabled reports whether events of the given type are sent
+func (n *Notifier) Enabled(eventType string) bool {
+	return len(n.config.Events) == 0 || n.config.Events[eventType]
+}
+
+// Notify queues events for delivery, dropping them if the queue is full
+func (n *Notifier) Notify(events ...model.AuditEvent) {
+	for _, event := range events {
+		if !n.Enabled(event.Type) {
+			continue
+		}
+		select {
+		case n.queue <- event:
+		default:
+			xlog.Logf("Notification queue full, dropping %s event for %s", event.Type, event.EntityName)
+		}
+	}
+}
+
+// Start begins delivering queued events in batches
+func (n *Notifier) Start() {
+	n.once.Do(func() {
+		go n.run()
+		go n.deliverLoop()
+	})
+}
+
+// Flush delivers every queued event and waits for delivery to finish
+func (n *Notifier) Flush() {
+	done := make(chan struct{})
+	n.flush <- done
+	<-done
+}
+
+// run collects queued events into batches. Batches are sent by deliverLoop so a slow or rate limited webhook
+// never stops events being taken off the queue.
+func (n *Notifier) run() {
+	ticker := time.NewTicker(n.config.BatchInterval)
+	defer ticker.Stop()
+
+	// batch collects events until the next tick, pending holds events waiting for the delivery goroutine
+	var batch, pending []model.AuditEvent
+	for {
+		var deliveries chan delivery
+		if len(pending) > 0 {
+			deliveries = n.deliveries
+		}
+
+		select {
+		case event := <-n.queue:
+			batch = append(batch, event)
+		case <-ticker.C:
+			pending = n.addPending(pending, batch)
+			batch = nil
+		case deliveries <- delivery{events: pending}:
+			pending = nil
+		case done := <-n.flush:
+			for len(n.queue) > 0 {
+				batch = append(batch, <-n.queue)
+			}
+			n.deliveries <- delivery{events: n.addPending(pending, batch), done: done}
+			batch, pending = nil, nil
+		}
+	}
+}
+
+// addPending adds a batch to the events waiting for delivery, dropping the oldest once more than a queue's worth are waiting
+func (n *Notifier) addPending(pending []model.AuditEvent, batch []model.AuditEvent) []model.AuditEvent {
+	pending = append(pending, batch...)
+	if dropped := len(pending) - defaultQueueSize; dropped > 0 {
+		xlog.Logf("Notification delivery is behind, dropping %d undelivered events", dropped)
+		pending = pending[dropped:]
+	}
+	return pending
+}
+
+// deliverLoop sends batches handed over by run
+func (n *Notifier) deliverLoop() {
+	for d := range n.deliveries {
+		n.deliver(d.events)
+		if d.done != nil {
+			close(d.done)
+		}
+	}
+}
+
+func (n *Notifier) deliver(batch []model.AuditEvent) {
+	for start := 0; start < len(batch); start += maxEmbedsPerMessage {
+		message := newWebhookMessage(batch[start:min(start+maxEmbedsPerMessage, len(batch))])
+		for _, webhookURL := range n.config.WebhookURLs {
+			if err := n.post(webhookURL, message); err != nil {
+				xlog.Logf("Failed to deliver %d notifications: %v", len(message.Embeds), err)
+			}
+		}
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
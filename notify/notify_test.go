@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+// webhookServer records the messages posted to it and answers with the statuses returned by respond
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	attempts int
+	messages []webhookMessage
+}
+
+func newWebhookServer(t *testing.T, respond func(attempt int, w http.ResponseWriter) bool) *webhookServer {
+	t.Helper()
+
+	ws := &webhookServer{}
+	ws.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message webhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("failed to decode webhook message: %v", err)
+		}
+
+		ws.mu.Lock()
+		ws.attempts++
+		attempt := ws.attempts
+		ws.mu.Unlock()
+
+		if !respond(attempt, w) {
+			return
+		}
+
+		ws.mu.Lock()
+		ws.messages = append(ws.messages, message)
+		ws.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ws.Close)
+	return ws
+}
+
+func (ws *webhookServer) counts() (int, []webhookMessage) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.attempts, ws.messages
+}
+
+func startNotifier(webhookURL string, maxRetries int) *Notifier {
+	n := New(Config{
+		WebhookURLs:   []string{webhookURL},
+		BatchInterval: time.Hour,
+		MaxRetries:    maxRetries,
+	})
+	n.Start()
+	return n
+}
+
+func testEvents(count int) []model.AuditEvent {
+	events := make([]model.AuditEvent, count)
+	for i := range events {
+		events[i] = model.AuditEvent{Time: time.Now(), Type: model.EventEntryAdded, EntityName: "Pilot"}
+	}
+	return events
+}
+
+func TestNotifierBatchesEvents(t *testing.T) {
+	ws := newWebhookServer(t, func(int, http.ResponseWriter) bool { return true })
+	n := startNotifier(ws.URL, 1)
+
+	n.Notify(testEvents(25)...)
+	n.Flush()
+
+	attempts, messages := ws.counts()
+	if attempts != 3 {
+		t.Fatalf("got %d webhook calls, want 3", attempts)
+	}
+	for i, want := range []int{10, 10, 5} {
+		if got := len(messages[i].Embeds); got != want {
+			t.Errorf("message %d has %d embeds, want %d", i, got, want)
+		}
+	}
+}
+
+func TestNotifierRetriesAfterRateLimit(t *testing.T) {
+	ws := newWebhookServer(t, func(attempt int, w http.ResponseWriter) bool {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return false
+		}
+		return true
+	})
+	n := startNotifier(ws.URL, 3)
+
+	n.Notify(testEvents(1)...)
+	n.Flush()
+
+	attempts, messages := ws.counts()
+	if attempts != 2 {
+		t.Errorf("got %d webhook calls, want 2", attempts)
+	}
+	if len(messages) != 1 {
+		t.Errorf("got %d delivered messages, want 1", len(messages))
+	}
+}
+
+func TestNotifierStopsAfterMaxRetries(t *testing.T) {
+	ws := newWebhookServer(t, func(_ int, w http.ResponseWriter) bool {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"retry_after": 0.01}`))
+		return false
+	})
+	n := startNotifier(ws.URL, 3)
+
+	n.Notify(testEvents(1)...)
+	n.Flush()
+
+	attempts, messages := ws.counts()
+	if attempts != 3 {
+		t.Errorf("got %d webhook calls, want 3", attempts)
+	}
+	if len(messages) != 0 {
+		t.Errorf("got %d delivered messages, want 0", len(messages))
+	}
+}
+
+// TestNotifierQueuesWhileDelivering checks events keep being taken off the queue while a webhook call is in progress
+func TestNotifierQueuesWhileDelivering(t *testing.T) {
+	release := make(chan struct{})
+	ws := newWebhookServer(t, func(attempt int, w http.ResponseWriter) bool {
+		if attempt == 1 {
+			<-release
+		}
+		return true
+	})
+	n := New(Config{WebhookURLs: []string{ws.URL}, BatchInterval: 10 * time.Millisecond, MaxRetries: 1})
+	n.Start()
+
+	n.Notify(testEvents(1)...)
+	deadline := time.Now().Add(time.Second)
+	for attempts, _ := ws.counts(); attempts == 0; attempts, _ = ws.counts() {
+		if time.Now().After(deadline) {
+			t.Fatal("first batch was never delivered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The first call is blocked, queued events must still be taken off the queue
+	n.Notify(testEvents(defaultQueueSize)...)
+	for len(n.queue) > 0 {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatal("queue was not drained while a delivery was in progress")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	n.Flush()
+
+	_, messages := ws.counts()
+	delivered := 0
+	for _, message := range messages {
+		delivered += len(message.Embeds)
+	}
+	if delivered != defaultQueueSize+1 {
+		t.Errorf("delivered %d events, want %d", delivered, defaultQueueSize+1)
+	}
+}
@@ -0,0 +1,166 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gambtho/whototrust/xlog"
+)
+
+// defaultBackups is the number of previous trust list versions kept when no count is configured
+const defaultBackups = 5
+
+// writeFileAtomic writes data to a temporary file in the same directory, syncs it and renames it over path,
+// so a crash or full disk leaves either the old or the new file in place and never a partial one
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once the rename succeeds
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %v", err)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %v", err)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir flushes a rename in dir to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Some platforms do not support syncing directories, the rename has still happened
+	_ = d.Sync()
+	return nil
+}
+
+// backupName returns the name of the nth previous version of path
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateBackups shifts the previous versions of path up by one and makes the current file the newest backup
+func rotateBackups(path string, backups int) error {
+	if backups <= 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	_ = os.Remove(backupName(path, backups))
+	for n := backups - 1; n >= 1; n-- {
+		if err := os.Rename(backupName(path, n), backupName(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate backup %d: %v", n, err)
+		}
+	}
+
+	// Link rather than rename so the live file is never missing
+	if err := os.Link(path, backupName(path, 1)); err != nil {
+		if err := copyFile(path, backupName(path, 1)); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// validJSONFile reports whether path holds a JSON document that decodes into v
+func validJSONFile(path string, v interface{}) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// recoverJSONFile checks that path decodes into v and, if it is corrupt, restores the newest valid backup.
+// The corrupt file is kept alongside with a .corrupt suffix. A missing file is not an error.
+func recoverJSONFile(path string, backups int, v interface{}) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+
+	if validJSONFile(path, v) {
+		return nil
+	}
+
+	for n := 1; n <= backups; n++ {
+		if !validJSONFile(backupName(path, n), v) {
+			continue
+		}
+
+		data, err := os.ReadFile(backupName(path, n))
+		if err != nil {
+			return fmt.Errorf("failed to read backup %d: %v", n, err)
+		}
+
+		corruptName := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+		if err := os.Rename(path, corruptName); err != nil {
+			return fmt.Errorf("failed to move corrupt file aside: %v", err)
+		}
+		if err := writeFileAtomic(path, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to restore backup %d: %v", n, err)
+		}
+
+		xlog.Logf("%s was corrupt, moved it to %s and restored backup %d", path, corruptName, n)
+		return nil
+	}
+
+	return fmt.Errorf("%s is corrupt and no valid backup was found", path)
+}
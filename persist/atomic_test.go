@@ -0,0 +1,52 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRecoverJSONFileKeepsPermissions checks a corrupt file is replaced by its backup with the same permissions
+func TestRecoverJSONFileKeepsPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte("{corrupt"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(backupName(path, 1), []byte(`{"revision": 3}`), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var v struct {
+		Revision int `json:"revision"`
+	}
+	if err := recoverJSONFile(path, 1, &v); err != nil {
+		t.Fatalf("recoverJSONFile: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0644 {
+		t.Errorf("restored file has mode %v, want %v", got, os.FileMode(0644))
+	}
+	if !validJSONFile(path, &v) || v.Revision != 3 {
+		t.Errorf("restored file does not hold the backup")
+	}
+}
+
+// TestRecoverJSONFileWithoutBackup checks a corrupt file stays in place when no backup can replace it
+func TestRecoverJSONFileWithoutBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte("{corrupt"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var v map[string]interface{}
+	if err := recoverJSONFile(path, 2, &v); err == nil {
+		t.Fatal("recoverJSONFile succeeded without a valid backup")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("corrupt file was moved: %v", err)
+	}
+}
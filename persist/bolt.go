@@ -0,0 +1,756 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/oauth2"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const boltFileName = "whototrust.db"
+
+// Bucket names
+var (
+	trustBucket         = []byte("trust")
+	identitiesBucket    = []byte("identities")
+	auditBucket         = []byte("audit")
+	settingsBucket      = []byte("settings")
+	quarantineBucket    = []byte("quarantine")
+	sessionsBucket      = []byte("sessions")
+	listsBucket         = []byte("lists")
+	subscriptionsBucket = []byte("subscriptions")
+	tokensBucket        = []byte("api_tokens")
+	managedBucket       = []byte("managed_contacts")
+
+	trustListKey = []byte("list")
+)
+
+// BoltStore keeps all data in a single embedded bbolt key-value database
+type BoltStore struct {
+	db   *bolt.DB
+	keys [][]byte
+}
+
+// NewBoltStore opens or creates the database at path.
+// Identities are encrypted with the first of keys and can be read with any of them.
+func NewBoltStore(path string, keys [][]byte) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{trustBucket, identitiesBucket, auditBucket, settingsBucket, quarantineBucket, sessionsBucket, listsBucket, subscriptionsBucket, tokensBucket, managedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %v", err)
+	}
+
+	return &BoltStore{db: db, keys: keys}, nil
+}
+
+// LoadTrustedCharacters loads the trusted characters and corporations on a list from the database
+func (s *BoltStore) LoadTrustedCharacters(list string) (*model.TrustedCharacters, error) {
+	var trustedData *model.TrustedCharacters
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		trustedData, err = loadTrustList(tx, list)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return trustedData, nil
+}
+
+// SaveTrustedCharacters saves the trusted characters and corporations on a list to the database
+func (s *BoltStore) SaveTrustedCharacters(list string, trustedData *model.TrustedCharacters) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return saveTrustList(tx, list, trustedData)
+	})
+}
+
+// UpdateTrusted loads a trust list, applies updateFunc and saves the result in a single transaction
+func (s *BoltStore) UpdateTrusted(list string, updateFunc func(*model.TrustedCharacters) error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		lists, err := loadListDefinitions(tx)
+		if err != nil {
+			return err
+		}
+		if !hasList(lists, list) {
+			return ErrListNotFound
+		}
+
+		trustedData, err := loadTrustList(tx, list)
+		if err != nil {
+			return err
+		}
+
+		trustedData.Revision++
+
+		if err := updateFunc(trustedData); err != nil {
+			return err
+		}
+
+		return saveTrustList(tx, list, trustedData)
+	})
+	if err == ErrUnchanged {
+		return nil
+	}
+	return err
+}
+
+// trustListKeyFor returns the trust bucket key a list is kept under. The default list keeps the original key.
+func trustListKeyFor(list string) []byte {
+	if list == model.DefaultList {
+		return trustListKey
+	}
+	return []byte("list/" + list)
+}
+
+func loadTrustList(tx *bolt.Tx, list string) (*model.TrustedCharacters, error) {
+	data := tx.Bucket(trustBucket).Get(trustListKeyFor(list))
+	if data == nil {
+		return emptyTrustedCharacters(), nil
+	}
+
+	var trustedData model.TrustedCharacters
+	if err := json.Unmarshal(data, &trustedData); err != nil {
+		return nil, fmt.Errorf("failed to decode trusted characters: %v", err)
+	}
+
+	return &trustedData, nil
+}
+
+func saveTrustList(tx *bolt.Tx, list string, trustedData *model.TrustedCharacters) error {
+	data, err := json.Marshal(trustedData)
+	if err != nil {
+		return fmt.Errorf("failed to encode trusted characters: %v", err)
+	}
+
+	return tx.Bucket(trustBucket).Put(trustListKeyFor(list), data)
+}
+
+// LoadTrustLists returns the list definitions from the database
+func (s *BoltStore) LoadTrustLists() ([]model.TrustList, error) {
+	var lists []model.TrustList
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		lists, err = loadListDefinitions(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// UpdateTrustLists loads the list definitions, applies updateFunc and saves the result in a single transaction
+func (s *BoltStore) UpdateTrustLists(updateFunc func(*[]model.TrustList) error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		lists, err := loadListDefinitions(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := updateFunc(&lists); err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(listsBucket)
+		names := make(map[string]bool, len(lists))
+		for _, list := range lists {
+			data, err := json.Marshal(list)
+			if err != nil {
+				return fmt.Errorf("failed to encode trust list: %v", err)
+			}
+			if err := bucket.Put([]byte(list.Name), data); err != nil {
+				return err
+			}
+			names[list.Name] = true
+		}
+
+		// Drop definitions the update removed
+		var removed [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			if !names[string(k)] {
+				removed = append(removed, copyBytes(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range removed {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrUnchanged {
+		return nil
+	}
+	return err
+}
+
+// DeleteTrustList removes a list definition and its entries in a single transaction
+func (s *BoltStore) DeleteTrustList(name string) error {
+	if name == model.DefaultList {
+		return ErrDefaultList
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(listsBucket)
+		if bucket.Get([]byte(name)) == nil {
+			return ErrListNotFound
+		}
+		if err := bucket.Delete([]byte(name)); err != nil {
+			return err
+		}
+		return tx.Bucket(trustBucket).Delete(trustListKeyFor(name))
+	})
+}
+
+// loadListDefinitions reads the list definitions, with the default list first
+func loadListDefinitions(tx *bolt.Tx) ([]model.TrustList, error) {
+	var lists []model.TrustList
+	err := tx.Bucket(listsBucket).ForEach(func(k, v []byte) error {
+		var list model.TrustList
+		if err := json.Unmarshal(v, &list); err != nil {
+			return fmt.Errorf("failed to decode trust list %s: %v", k, err)
+		}
+		lists = append(lists, list)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return withDefaultList(lists), nil
+}
+
+// LoadSubscriptions returns the list subscriptions of a main identity's characters from the database
+func (s *BoltStore) LoadSubscriptions(mainIdentity int64) (map[int64][]model.Subscription, error) {
+	subscriptions := map[int64][]model.Subscription{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(subscriptionsBucket).Get(int64Key(mainIdentity))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &subscriptions)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// SaveSubscriptions stores the list subscriptions of a main identity's characters
+func (s *BoltStore) SaveSubscriptions(mainIdentity int64, subscriptions map[int64][]model.Subscription) error {
+	data, err := json.Marshal(subscriptions)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscriptions: %v", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).Put(int64Key(mainIdentity), data)
+	})
+}
+
+// LoadManagedContacts returns the IDs of the contacts the contact sync added to a character from the database
+func (s *BoltStore) LoadManagedContacts(characterID int64) ([]int64, error) {
+	var contactIDs []int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(managedBucket).Get(int64Key(characterID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &contactIDs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed contacts: %v", err)
+	}
+
+	return contactIDs, nil
+}
+
+// SaveManagedContacts stores the IDs of the contacts the contact sync added to a character
+func (s *BoltStore) SaveManagedContacts(characterID int64, contactIDs []int64) error {
+	if len(contactIDs) == 0 {
+		return s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(managedBucket).Delete(int64Key(characterID))
+		})
+	}
+
+	data, err := json.Marshal(contactIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode managed contacts: %v", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(managedBucket).Put(int64Key(characterID), data)
+	})
+}
+
+// LoadIdentities loads and decrypts the identities for a main identity
+func (s *BoltStore) LoadIdentities(mainIdentity int64) (*Identities, error) {
+	if mainIdentity == 0 {
+		return nil, fmt.Errorf("logged in user not provided")
+	}
+
+	identities := &Identities{Tokens: make(map[int64]oauth2.Token)}
+
+	var encrypted []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		encrypted = copyBytes(tx.Bucket(identitiesBucket).Get(int64Key(mainIdentity)))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identities: %v", err)
+	}
+
+	if len(encrypted) == 0 {
+		return identities, nil
+	}
+
+	keyIndex, err := decryptData(s.keys, encrypted, identities)
+	if err != nil {
+		// Keep the value so it can be restored once the right key is configured, and let the user sign in again
+		xlog.Logf("error decrypting identities for %d, quarantining them: %v", mainIdentity, err)
+		if err := s.quarantineIdentity(mainIdentity, encrypted, quarantineReason(err)); err != nil {
+			return nil, err
+		}
+		return &Identities{Tokens: make(map[int64]oauth2.Token)}, nil
+	}
+
+	// Rewrite values in the legacy format or encrypted with a previous key
+	if needsReencryption(encrypted, keyIndex) {
+		if err := s.SaveIdentities(mainIdentity, identities); err != nil {
+			xlog.Logf("failed to migrate identities for %d: %v", mainIdentity, err)
+		}
+	}
+
+	return identities, nil
+}
+
+// SaveIdentities encrypts and stores the identities for a main identity
+func (s *BoltStore) SaveIdentities(mainIdentity int64, ids *Identities) error {
+	if mainIdentity == 0 {
+		return fmt.Errorf("no main identity provided")
+	}
+
+	encrypted, err := encryptData(s.keys[0], ids)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(identitiesBucket).Put(int64Key(mainIdentity), encrypted)
+	})
+}
+
+// DeleteIdentity removes the identities for a main identity
+func (s *BoltStore) DeleteIdentity(mainIdentity int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(identitiesBucket).Delete(int64Key(mainIdentity))
+	})
+}
+
+// quarantinedValue is stored in the quarantine bucket for identities that could not be decrypted
+type quarantinedValue struct {
+	Data          []byte    `json:"data"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// quarantineIdentity moves identities into the quarantine bucket under a key of their own, so a copy quarantined
+// earlier is never overwritten. The identities are only deleted if they were not saved again since they were read.
+func (s *BoltStore) quarantineIdentity(mainIdentity int64, encrypted []byte, reason string) error {
+	now := time.Now()
+	value, err := json.Marshal(quarantinedValue{Data: encrypted, Reason: reason, QuarantinedAt: now})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(fmt.Sprintf("%d.%s", mainIdentity, quarantineStamp(now)))
+		if err := tx.Bucket(quarantineBucket).Put(key, value); err != nil {
+			return err
+		}
+		if !bytes.Equal(tx.Bucket(identitiesBucket).Get(int64Key(mainIdentity)), encrypted) {
+			return nil
+		}
+		return tx.Bucket(identitiesBucket).Delete(int64Key(mainIdentity))
+	})
+}
+
+// quarantinedKeys returns the quarantine keys of a main identity newest first, with a copy quarantined before
+// copies were keyed uniquely last
+func quarantinedKeys(tx *bolt.Tx, mainIdentity int64) [][]byte {
+	var keys [][]byte
+	prefix := []byte(fmt.Sprintf("%d.", mainIdentity))
+	cursor := tx.Bucket(quarantineBucket).Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		keys = append(keys, copyBytes(k))
+	}
+	slices.Reverse(keys)
+
+	if tx.Bucket(quarantineBucket).Get(int64Key(mainIdentity)) != nil {
+		keys = append(keys, int64Key(mainIdentity))
+	}
+	return keys
+}
+
+// ListQuarantined returns the identities in the quarantine bucket
+func (s *BoltStore) ListQuarantined() ([]QuarantinedIdentity, error) {
+	quarantined := []QuarantinedIdentity{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(quarantineBucket).ForEach(func(k, v []byte) error {
+			id, _, _ := strings.Cut(string(k), ".")
+			mainIdentity, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return nil
+			}
+
+			var value quarantinedValue
+			if err := json.Unmarshal(v, &value); err != nil {
+				return fmt.Errorf("failed to decode quarantined identity %d: %v", mainIdentity, err)
+			}
+
+			quarantined = append(quarantined, QuarantinedIdentity{
+				ID:            string(k),
+				MainIdentity:  mainIdentity,
+				Reason:        value.Reason,
+				QuarantinedAt: value.QuarantinedAt,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return quarantined, nil
+}
+
+// RestoreQuarantined decrypts every quarantined copy of an identity that the configured keys can read and merges
+// them back. Copies that still cannot be decrypted stay in quarantine.
+func (s *BoltStore) RestoreQuarantined(mainIdentity int64) error {
+	// Restore in a single transaction so a concurrent LoadIdentities cannot quarantine the identities being restored into
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keys := quarantinedKeys(tx, mainIdentity)
+		if len(keys) == 0 {
+			return ErrNotQuarantined
+		}
+
+		copies := make([][]byte, len(keys))
+		for i, key := range keys {
+			var value quarantinedValue
+			if err := json.Unmarshal(tx.Bucket(quarantineBucket).Get(key), &value); err != nil {
+				return fmt.Errorf("failed to decode quarantined identity %s: %v", key, err)
+			}
+			copies[i] = value.Data
+		}
+
+		current := &Identities{Tokens: make(map[int64]oauth2.Token)}
+		if err := decryptCurrent(s.keys, tx.Bucket(identitiesBucket).Get(int64Key(mainIdentity)), current); err != nil {
+			return err
+		}
+
+		restored, err := restoreCopies(s.keys, copies, current)
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := encryptData(s.keys[0], current)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(identitiesBucket).Put(int64Key(mainIdentity), encrypted); err != nil {
+			return err
+		}
+		for i, key := range keys {
+			if !restored[i] {
+				continue
+			}
+			if err := tx.Bucket(quarantineBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RekeyIdentities re-encrypts every stored identity with the primary key in a single transaction
+func (s *BoltStore) RekeyIdentities() (int, error) {
+	rekeyed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(identitiesBucket)
+
+		type rekeyedValue struct{ key, value []byte }
+		var values []rekeyedValue
+		err := bucket.ForEach(func(k, v []byte) error {
+			if len(v) == 0 {
+				return nil
+			}
+
+			identities := &Identities{Tokens: make(map[int64]oauth2.Token)}
+			if _, err := decryptData(s.keys, v, identities); err != nil {
+				return fmt.Errorf("failed to decrypt identities for %s: %v", k, err)
+			}
+
+			encrypted, err := encryptData(s.keys[0], identities)
+			if err != nil {
+				return err
+			}
+			values = append(values, rekeyedValue{key: copyBytes(k), value: encrypted})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Keys cannot be modified while iterating
+		for _, value := range values {
+			if err := bucket.Put(value.key, value.value); err != nil {
+				return err
+			}
+		}
+		rekeyed = len(values)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rekeyed, nil
+}
+
+// LoadSession returns a login session from the sessions bucket
+func (s *BoltStore) LoadSession(id string) (*Session, error) {
+	var session *Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		session = &Session{}
+		return json.Unmarshal(data, session)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %v", err)
+	}
+
+	return session, nil
+}
+
+// SaveSession writes a login session to the sessions bucket
+func (s *BoltStore) SaveSession(session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %v", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(session.ID), data)
+	})
+}
+
+// DeleteSession removes a login session from the sessions bucket
+func (s *BoltStore) DeleteSession(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(id))
+	})
+}
+
+// ListSessions returns every login session in the sessions bucket
+func (s *BoltStore) ListSessions() ([]Session, error) {
+	sessions := []Session{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return fmt.Errorf("failed to decode session: %v", err)
+			}
+			sessions = append(sessions, session)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// LoadAPIToken returns an API token from the tokens bucket
+func (s *BoltStore) LoadAPIToken(id string) (*APIToken, error) {
+	var token *APIToken
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(tokensBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		token = &APIToken{}
+		return json.Unmarshal(data, token)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API token: %v", err)
+	}
+
+	return token, nil
+}
+
+// SaveAPIToken writes an API token to the tokens bucket
+func (s *BoltStore) SaveAPIToken(token *APIToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to encode API token: %v", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).Put([]byte(token.ID), data)
+	})
+}
+
+// DeleteAPIToken removes an API token from the tokens bucket
+func (s *BoltStore) DeleteAPIToken(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).Delete([]byte(id))
+	})
+}
+
+// ListAPITokens returns every API token in the tokens bucket
+func (s *BoltStore) ListAPITokens() ([]APIToken, error) {
+	tokens := []APIToken{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).ForEach(func(k, v []byte) error {
+			var token APIToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return fmt.Errorf("failed to decode API token: %v", err)
+			}
+			tokens = append(tokens, token)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// AppendAuditEvents appends events to the audit bucket, keyed by sequence number
+func (s *BoltStore) AppendAuditEvents(events ...model.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditBucket)
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to encode audit event: %v", err)
+			}
+
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			if err := bucket.Put(uint64Key(seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadAuditEvents returns the most recent events, newest last. A limit of 0 returns every event.
+func (s *BoltStore) LoadAuditEvents(limit int) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(auditBucket).Cursor()
+		for k, v := cursor.Last(); k != nil && (limit <= 0 || len(events) < limit); k, v = cursor.Prev() {
+			var event model.AuditEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return fmt.Errorf("failed to decode audit event: %v", err)
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Events were read newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	return events, nil
+}
+
+// LoadSetting returns a setting from the settings bucket
+func (s *BoltStore) LoadSetting(name string) (string, error) {
+	var value string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket(settingsBucket).Get([]byte(name)))
+		return nil
+	})
+	return value, err
+}
+
+// SaveSetting writes a setting to the settings bucket
+func (s *BoltStore) SaveSetting(name string, value string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(settingsBucket).Put([]byte(name), []byte(value))
+	})
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func int64Key(id int64) []byte {
+	return []byte(strconv.FormatInt(id, 10))
+}
+
+// uint64Key encodes a sequence number big endian so keys sort in insertion order
+func uint64Key(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// copyBytes copies a value out of a bolt transaction, values are only valid while the transaction is open
+func copyBytes(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	return append([]byte{}, value...)
+}
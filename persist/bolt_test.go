@@ -0,0 +1,36 @@
+package persist
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStoreConcurrentUpdates(t *testing.T) {
+	const n = 50
+	path := filepath.Join(t.TempDir(), boltFileName)
+
+	st, err := NewBoltStore(path, testKeys)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	addConcurrently(t, st, n)
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewBoltStore(path, testKeys)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer reopened.Close()
+	checkAllAdded(t, reopened, n)
+}
+
+func TestBoltStoreKeepsEveryQuarantinedCopy(t *testing.T) {
+	st, err := NewBoltStore(filepath.Join(t.TempDir(), boltFileName), testKeys)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer st.Close()
+	quarantineTwice(t, st, func(keys [][]byte) { st.keys = keys }, [][]byte{[]byte("old-key-0123456789abcdef01234567")}, testKeys)
+}
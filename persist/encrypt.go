@@ -1,84 +1,161 @@
 package persist
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"io"
-	"os"
 )
 
-var key []byte
+// Encrypted data starts with a header of the magic bytes, a format version and a key check value,
+// followed by the GCM nonce and the sealed gob encoding. Data without the magic bytes is in the
+// original unauthenticated AES-CFB format.
+const (
+	encryptionMagic   = "WTT"
+	encryptionVersion = byte(1)
+	keyCheckSize      = 8
+	headerSize        = len(encryptionMagic) + 1 + keyCheckSize
+)
 
-// EncryptData encrypts the given data and writes it to the specified file
-func EncryptData(data interface{}, outputFile string) error {
-	outFile, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
+var (
+	// ErrWrongKey is returned when encrypted data was written with a key that is not configured
+	ErrWrongKey = errors.New("encrypted with a different key")
+	// ErrCorrupt is returned when encrypted data is truncated or has been modified
+	ErrCorrupt = errors.New("encrypted data is corrupt")
+)
+
+// keyCheck derives a short value identifying key, so a wrong key can be told apart from corrupt data
+func keyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("whototrust key check"))
+	return mac.Sum(nil)[:keyCheckSize]
+}
+
+// isLegacyEncryption reports whether data was written in the original AES-CFB format and should be rewritten
+func isLegacyEncryption(encrypted []byte) bool {
+	return !bytes.HasPrefix(encrypted, []byte(encryptionMagic))
+}
+
+// encryptData gob encodes and encrypts the given data with AES-GCM
+func encryptData(key []byte, data interface{}) ([]byte, error) {
+	var plain bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(data); err != nil {
+		return nil, err
 	}
-	defer outFile.Close()
 
-	block, err := aes.NewCipher(key)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return err
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
 	}
 
-	if _, err := outFile.Write(iv); err != nil {
-		return err
+	header := make([]byte, 0, headerSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, encryptionVersion)
+	header = append(header, keyCheck(key)...)
+
+	// The header is authenticated along with the data
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, plain.Bytes(), header), nil
+}
+
+// decryptData decrypts the given bytes with whichever of keys they were written with and populates the given
+// data struct. It returns the index of the key that was used, anything other than 0 should be re-encrypted.
+func decryptData(keys [][]byte, encrypted []byte, data interface{}) (int, error) {
+	if isLegacyEncryption(encrypted) {
+		var err error
+		for i, key := range keys {
+			if err = decryptLegacyData(key, encrypted, data); err == nil {
+				return i, nil
+			}
+		}
+		return 0, err
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	writer := &cipher.StreamWriter{S: stream, W: outFile}
+	if len(encrypted) < headerSize {
+		return 0, ErrCorrupt
+	}
 
-	encoder := gob.NewEncoder(writer)
-	if err := encoder.Encode(data); err != nil {
-		return err
+	header := encrypted[:headerSize]
+	if version := header[len(encryptionMagic)]; version != encryptionVersion {
+		return 0, fmt.Errorf("unsupported encryption version %d", version)
 	}
 
-	return nil
-}
+	for i, key := range keys {
+		if !hmac.Equal(header[len(encryptionMagic)+1:], keyCheck(key)) {
+			continue
+		}
 
-// DecryptData reads the encrypted data from the specified file, decrypts it, and populates the given data struct
-func DecryptData(inputFile string, data interface{}) error {
-	inFile, err := os.Open(inputFile)
-	if err != nil {
-		return err
+		gcm, err := newGCM(key)
+		if err != nil {
+			return 0, err
+		}
+
+		rest := encrypted[headerSize:]
+		if len(rest) < gcm.NonceSize() {
+			return 0, ErrCorrupt
+		}
+
+		plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+		if err != nil {
+			return 0, ErrCorrupt
+		}
+
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(data); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+
+		return i, nil
 	}
-	defer inFile.Close()
 
+	return 0, ErrWrongKey
+}
+
+// needsReencryption reports whether data decrypted with the key at keyIndex should be rewritten with the primary key
+func needsReencryption(encrypted []byte, keyIndex int) bool {
+	return keyIndex > 0 || isLegacyEncryption(encrypted)
+}
+
+// decryptLegacyData reads data written in the original AES-CFB format. The format has no integrity check,
+// so a wrong key and a corrupt file both show up as a decode failure.
+func decryptLegacyData(key []byte, encrypted []byte, data interface{}) error {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return err
 	}
 
+	in := bytes.NewReader(encrypted)
+
 	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(inFile, iv); err != nil {
-		return err
+	if _, err := io.ReadFull(in, iv); err != nil {
+		return ErrCorrupt
 	}
 
 	stream := cipher.NewCFBDecrypter(block, iv)
-	reader := &cipher.StreamReader{S: stream, R: inFile}
+	reader := &cipher.StreamReader{S: stream, R: in}
 
 	decoder := gob.NewDecoder(reader)
 	if err := decoder.Decode(data); err != nil {
-		return err
+		return fmt.Errorf("%w (legacy format, the key may also be wrong): %v", ErrCorrupt, err)
 	}
 
 	return nil
 }
 
-func Initialize(bytes []byte) error {
-	key = bytes
-
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return os.Mkdir(dir, 0755)
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return cipher.NewGCM(block)
 }
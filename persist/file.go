@@ -0,0 +1,911 @@
+package persist
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/gambtho/whototrust/model"
+	"github.com/gambtho/whototrust/xlog"
+)
+
+const (
+	defaultDir            = "data"
+	trustedCharactersFile = "trusted_characters.json"
+	listsFile             = "lists.json"
+	listsDir              = "lists"
+	subscriptionsFile     = "subscriptions.json"
+	managedContactsFile   = "managed_contacts.json"
+	auditFile             = "audit.log"
+	settingsFile          = "settings.json"
+	sessionsFile          = "sessions.json"
+	tokensFile            = "api_tokens.json"
+	quarantineDir         = "quarantine"
+	reasonSuffix          = ".reason"
+)
+
+// FileStore keeps the trust lists, audit log and settings in JSON files and each user's identities in an encrypted file
+type FileStore struct {
+	dir     string
+	keys    [][]byte
+	backups int
+
+	// Mutexes for safe concurrent access to each file type, trustedMu covers every trust list and the list definitions
+	trustedMu       sync.Mutex
+	subscriptionsMu sync.Mutex
+	managedMu       sync.Mutex
+	auditMu         sync.Mutex
+	settingsMu      sync.Mutex
+	sessionsMu      sync.Mutex
+	tokensMu        sync.Mutex
+	// identitiesMu covers the identity files and the quarantine directory
+	identitiesMu sync.Mutex
+}
+
+// NewFileStore creates a store that keeps its files in dir and the given number of previous trust list versions.
+// Identities are encrypted with the first of keys and can be read with any of them.
+// A corrupt trust list is replaced with the newest valid backup.
+func NewFileStore(dir string, keys [][]byte, backups int) (*FileStore, error) {
+	s := &FileStore{dir: dir, keys: keys, backups: backups}
+
+	var lists []model.TrustList
+	if err := recoverJSONFile(filepath.Join(dir, listsFile), backups, &lists); err != nil {
+		return nil, err
+	}
+
+	lists, err := s.loadLists()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, list := range lists {
+		var trustedData model.TrustedCharacters
+		if err := recoverJSONFile(s.trustListFileName(list.Name), backups, &trustedData); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// LoadTrustedCharacters loads the trusted characters and corporations on a list from its file
+func (s *FileStore) LoadTrustedCharacters(list string) (*model.TrustedCharacters, error) {
+	s.trustedMu.Lock()
+	defer s.trustedMu.Unlock()
+
+	return s.loadTrusted(list)
+}
+
+// SaveTrustedCharacters atomically replaces a trust list file, keeping the previous version as a backup
+func (s *FileStore) SaveTrustedCharacters(list string, trustedData *model.TrustedCharacters) error {
+	s.trustedMu.Lock()
+	defer s.trustedMu.Unlock()
+
+	return s.saveTrusted(list, trustedData)
+}
+
+// UpdateTrusted loads a trust list, applies updateFunc and saves the result while holding the trust list lock
+func (s *FileStore) UpdateTrusted(list string, updateFunc func(*model.TrustedCharacters) error) error {
+	s.trustedMu.Lock()
+	defer s.trustedMu.Unlock()
+
+	lists, err := s.loadLists()
+	if err != nil {
+		return err
+	}
+	if !hasList(lists, list) {
+		return ErrListNotFound
+	}
+
+	trustedData, err := s.loadTrusted(list)
+	if err != nil {
+		return err
+	}
+
+	trustedData.Revision++
+
+	if err := updateFunc(trustedData); err != nil {
+		if err == ErrUnchanged {
+			return nil
+		}
+		return err
+	}
+
+	return s.saveTrusted(list, trustedData)
+}
+
+// trustListFileName returns the file a list is kept in. The default list keeps the original trust list file.
+func (s *FileStore) trustListFileName(list string) string {
+	if list == model.DefaultList {
+		return filepath.Join(s.dir, trustedCharactersFile)
+	}
+	return filepath.Join(s.dir, listsDir, list+".json")
+}
+
+func (s *FileStore) loadTrusted(list string) (*model.TrustedCharacters, error) {
+	file, err := os.Open(s.trustListFileName(list))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return emptyTrustedCharacters(), nil
+		}
+		return nil, fmt.Errorf("failed to open trusted characters file: %v", err)
+	}
+	defer file.Close()
+
+	var trustedData model.TrustedCharacters
+	if err := json.NewDecoder(file).Decode(&trustedData); err != nil {
+		return nil, fmt.Errorf("failed to decode trusted characters: %v", err)
+	}
+
+	return &trustedData, nil
+}
+
+func (s *FileStore) saveTrusted(list string, trustedData *model.TrustedCharacters) error {
+	if !ValidListName(list) {
+		return fmt.Errorf("invalid list name: %q", list)
+	}
+
+	data, err := json.Marshal(trustedData)
+	if err != nil {
+		return fmt.Errorf("failed to encode trusted characters: %v", err)
+	}
+
+	path := s.trustListFileName(list)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create lists directory: %v", err)
+	}
+	if err := rotateBackups(path, s.backups); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0644)
+}
+
+// LoadTrustLists returns the list definitions from the lists file
+func (s *FileStore) LoadTrustLists() ([]model.TrustList, error) {
+	s.trustedMu.Lock()
+	defer s.trustedMu.Unlock()
+
+	return s.loadLists()
+}
+
+// UpdateTrustLists loads the list definitions, applies updateFunc and saves the result while holding the trust list lock
+func (s *FileStore) UpdateTrustLists(updateFunc func(*[]model.TrustList) error) error {
+	s.trustedMu.Lock()
+	defer s.trustedMu.Unlock()
+
+	lists, err := s.loadLists()
+	if err != nil {
+		return err
+	}
+
+	if err := updateFunc(&lists); err != nil {
+		if err == ErrUnchanged {
+			return nil
+		}
+		return err
+	}
+
+	return s.saveLists(lists)
+}
+
+// DeleteTrustList removes a list from the lists file and deletes its entries and backups
+func (s *FileStore) DeleteTrustList(name string) error {
+	if name == model.DefaultList {
+		return ErrDefaultList
+	}
+
+	s.trustedMu.Lock()
+	defer s.trustedMu.Unlock()
+
+	lists, err := s.loadLists()
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]model.TrustList, 0, len(lists))
+	for _, list := range lists {
+		if list.Name != name {
+			remaining = append(remaining, list)
+		}
+	}
+	if len(remaining) == len(lists) {
+		return ErrListNotFound
+	}
+
+	if err := s.saveLists(remaining); err != nil {
+		return err
+	}
+
+	path := s.trustListFileName(name)
+	for n := 1; n <= s.backups; n++ {
+		_ = os.Remove(backupName(path, n))
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete trust list file: %v", err)
+	}
+
+	return nil
+}
+
+// loadLists reads the list definitions, with the default list first
+func (s *FileStore) loadLists() ([]model.TrustList, error) {
+	var lists []model.TrustList
+
+	data, err := os.ReadFile(filepath.Join(s.dir, listsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read trust lists: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &lists); err != nil {
+			return nil, fmt.Errorf("failed to decode trust lists: %v", err)
+		}
+	}
+
+	return withDefaultList(lists), nil
+}
+
+func (s *FileStore) saveLists(lists []model.TrustList) error {
+	data, err := json.Marshal(lists)
+	if err != nil {
+		return fmt.Errorf("failed to encode trust lists: %v", err)
+	}
+
+	path := filepath.Join(s.dir, listsFile)
+	if err := rotateBackups(path, s.backups); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0644)
+}
+
+// LoadSubscriptions returns the list subscriptions of a main identity's characters from the subscriptions file
+func (s *FileStore) LoadSubscriptions(mainIdentity int64) (map[int64][]model.Subscription, error) {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	subscriptions, err := s.loadSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if subscriptions[mainIdentity] == nil {
+		return map[int64][]model.Subscription{}, nil
+	}
+	return subscriptions[mainIdentity], nil
+}
+
+// SaveSubscriptions writes the list subscriptions of a main identity's characters to the subscriptions file
+func (s *FileStore) SaveSubscriptions(mainIdentity int64, characterSubscriptions map[int64][]model.Subscription) error {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	subscriptions, err := s.loadSubscriptions()
+	if err != nil {
+		return err
+	}
+	subscriptions[mainIdentity] = characterSubscriptions
+
+	data, err := json.Marshal(subscriptions)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscriptions: %v", err)
+	}
+
+	return writeFileAtomic(filepath.Join(s.dir, subscriptionsFile), data, 0644)
+}
+
+func (s *FileStore) loadSubscriptions() (map[int64]map[int64][]model.Subscription, error) {
+	subscriptions := make(map[int64]map[int64][]model.Subscription)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, subscriptionsFile))
+	if os.IsNotExist(err) {
+		return subscriptions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return nil, fmt.Errorf("failed to decode subscriptions: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// LoadManagedContacts returns the IDs of the contacts the contact sync added to a character from the managed contacts file
+func (s *FileStore) LoadManagedContacts(characterID int64) ([]int64, error) {
+	s.managedMu.Lock()
+	defer s.managedMu.Unlock()
+
+	managed, err := s.loadManagedContacts()
+	if err != nil {
+		return nil, err
+	}
+	return managed[characterID], nil
+}
+
+// SaveManagedContacts writes the IDs of the contacts the contact sync added to a character to the managed contacts file
+func (s *FileStore) SaveManagedContacts(characterID int64, contactIDs []int64) error {
+	s.managedMu.Lock()
+	defer s.managedMu.Unlock()
+
+	managed, err := s.loadManagedContacts()
+	if err != nil {
+		return err
+	}
+	if len(contactIDs) == 0 {
+		delete(managed, characterID)
+	} else {
+		managed[characterID] = contactIDs
+	}
+
+	data, err := json.Marshal(managed)
+	if err != nil {
+		return fmt.Errorf("failed to encode managed contacts: %v", err)
+	}
+
+	return writeFileAtomic(filepath.Join(s.dir, managedContactsFile), data, 0644)
+}
+
+func (s *FileStore) loadManagedContacts() (map[int64][]int64, error) {
+	managed := make(map[int64][]int64)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, managedContactsFile))
+	if os.IsNotExist(err) {
+		return managed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed contacts: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &managed); err != nil {
+		return nil, fmt.Errorf("failed to decode managed contacts: %v", err)
+	}
+
+	return managed, nil
+}
+
+// LoadIdentities loads and decrypts the identity file for a main identity
+func (s *FileStore) LoadIdentities(mainIdentity int64) (*Identities, error) {
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	if mainIdentity == 0 {
+		return nil, fmt.Errorf("logged in user not provided")
+	}
+
+	identities := &Identities{Tokens: make(map[int64]oauth2.Token)}
+
+	fileName := s.identityFileName(mainIdentity)
+	encrypted, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) || (err == nil && len(encrypted) == 0) {
+		xlog.Log("no identity file or file is empty")
+		return identities, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndex, err := decryptData(s.keys, encrypted, identities)
+	if err != nil {
+		// Keep the file so it can be restored once the right key is configured, and let the user sign in again
+		xlog.Logf("error decrypting %s, quarantining it: %v", fileName, err)
+		if err := s.quarantineIdentity(mainIdentity, quarantineReason(err)); err != nil {
+			return nil, err
+		}
+		return &Identities{Tokens: make(map[int64]oauth2.Token)}, nil
+	}
+
+	// Rewrite files in the legacy format or encrypted with a previous key
+	if needsReencryption(encrypted, keyIndex) {
+		if err := s.saveIdentities(mainIdentity, identities); err != nil {
+			xlog.Logf("failed to migrate %s: %v", fileName, err)
+		}
+	}
+
+	return identities, nil
+}
+
+// SaveIdentities encrypts and writes the identity file for a main identity
+func (s *FileStore) SaveIdentities(mainIdentity int64, ids *Identities) error {
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+	return s.saveIdentities(mainIdentity, ids)
+}
+
+func (s *FileStore) saveIdentities(mainIdentity int64, ids *Identities) error {
+	if mainIdentity == 0 {
+		return fmt.Errorf("no main identity provided")
+	}
+
+	encrypted, err := encryptData(s.keys[0], ids)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.identityFileName(mainIdentity), encrypted, 0600)
+}
+
+// DeleteIdentity removes the identity file for a main identity
+func (s *FileStore) DeleteIdentity(mainIdentity int64) error {
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+	return os.Remove(s.identityFileName(mainIdentity))
+}
+
+func (s *FileStore) identityFileName(mainIdentity int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d_identity.json", mainIdentity))
+}
+
+// quarantineIdentity moves an identity file into the quarantine directory alongside a file recording why.
+// Each copy gets its own name so one quarantined earlier is never overwritten.
+func (s *FileStore) quarantineIdentity(mainIdentity int64, reason string) error {
+	if err := os.MkdirAll(filepath.Join(s.dir, quarantineDir), 0700); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %v", err)
+	}
+
+	quarantined := filepath.Join(s.dir, quarantineDir, fmt.Sprintf("%d_identity.%s.json", mainIdentity, quarantineStamp(time.Now())))
+	if err := os.Rename(s.identityFileName(mainIdentity), quarantined); err != nil {
+		return fmt.Errorf("failed to quarantine identity file: %v", err)
+	}
+
+	reason = fmt.Sprintf("%s\n", reason)
+	if err := writeFileAtomic(quarantined+reasonSuffix, []byte(reason), 0600); err != nil {
+		xlog.Logf("failed to record quarantine reason for %d: %v", mainIdentity, err)
+	}
+
+	return nil
+}
+
+// quarantinedFiles returns the quarantined copies of a main identity, or of every main identity when it is 0,
+// newest first. Copies quarantined before they were named uniquely come last.
+func (s *FileStore) quarantinedFiles(mainIdentity int64) ([]string, error) {
+	prefix := "*"
+	if mainIdentity != 0 {
+		prefix = strconv.FormatInt(mainIdentity, 10)
+	}
+	dir := filepath.Join(s.dir, quarantineDir)
+
+	stamped, err := filepath.Glob(filepath.Join(dir, prefix+"_identity.*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(stamped)
+	slices.Reverse(stamped)
+
+	legacy, err := filepath.Glob(filepath.Join(dir, prefix+"_identity.json"))
+	if err != nil {
+		return nil, err
+	}
+	return append(stamped, legacy...), nil
+}
+
+// ListQuarantined returns the identity files in the quarantine directory
+func (s *FileStore) ListQuarantined() ([]QuarantinedIdentity, error) {
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	fileNames, err := s.quarantinedFiles(0)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined := []QuarantinedIdentity{}
+	for _, fileName := range fileNames {
+		id, _, _ := strings.Cut(filepath.Base(fileName), "_identity")
+		mainIdentity, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		entry := QuarantinedIdentity{ID: filepath.Base(fileName), MainIdentity: mainIdentity}
+		if info, err := os.Stat(fileName + reasonSuffix); err == nil {
+			entry.QuarantinedAt = info.ModTime()
+		}
+		if reason, err := os.ReadFile(fileName + reasonSuffix); err == nil {
+			entry.Reason = strings.TrimSpace(string(reason))
+		}
+		quarantined = append(quarantined, entry)
+	}
+
+	return quarantined, nil
+}
+
+// RestoreQuarantined decrypts every quarantined copy of an identity that the configured keys can read and merges
+// them back. Copies that still cannot be decrypted stay in quarantine.
+func (s *FileStore) RestoreQuarantined(mainIdentity int64) error {
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	fileNames, err := s.quarantinedFiles(mainIdentity)
+	if err != nil {
+		return err
+	}
+	if len(fileNames) == 0 {
+		return ErrNotQuarantined
+	}
+
+	copies := make([][]byte, len(fileNames))
+	for i, fileName := range fileNames {
+		if copies[i], err = os.ReadFile(fileName); err != nil {
+			return err
+		}
+	}
+
+	// Read the current file directly, LoadIdentities would quarantine it while it is being restored into
+	current := &Identities{Tokens: make(map[int64]oauth2.Token)}
+	currentEncrypted, err := os.ReadFile(s.identityFileName(mainIdentity))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := decryptCurrent(s.keys, currentEncrypted, current); err != nil {
+		return err
+	}
+
+	restored, err := restoreCopies(s.keys, copies, current)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveIdentities(mainIdentity, current); err != nil {
+		return err
+	}
+
+	for i, fileName := range fileNames {
+		if !restored[i] {
+			continue
+		}
+		_ = os.Remove(fileName + reasonSuffix)
+		if err := os.Remove(fileName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RekeyIdentities re-encrypts every identity file with the primary key
+func (s *FileStore) RekeyIdentities() (int, error) {
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	fileNames, err := filepath.Glob(filepath.Join(s.dir, "*_identity.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	rekeyed := 0
+	for _, fileName := range fileNames {
+		mainIdentity, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(fileName), "_identity.json"), 10, 64)
+		if err != nil {
+			xlog.Logf("skipping %s: %v", fileName, err)
+			continue
+		}
+
+		encrypted, err := os.ReadFile(fileName)
+		if err != nil {
+			return rekeyed, err
+		}
+		if len(encrypted) == 0 {
+			continue
+		}
+
+		identities := &Identities{Tokens: make(map[int64]oauth2.Token)}
+		if _, err := decryptData(s.keys, encrypted, identities); err != nil {
+			return rekeyed, fmt.Errorf("failed to decrypt %s: %v", fileName, err)
+		}
+
+		if err := s.saveIdentities(mainIdentity, identities); err != nil {
+			return rekeyed, err
+		}
+		rekeyed++
+	}
+
+	return rekeyed, nil
+}
+
+// LoadSession returns a login session from the sessions file
+func (s *FileStore) LoadSession(id string) (*Session, error) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	sessions, err := s.loadSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// SaveSession writes a login session to the sessions file
+func (s *FileStore) SaveSession(session *Session) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	sessions, err := s.loadSessions()
+	if err != nil {
+		return err
+	}
+	sessions[session.ID] = *session
+
+	return s.saveSessions(sessions)
+}
+
+// DeleteSession removes a login session from the sessions file
+func (s *FileStore) DeleteSession(id string) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	sessions, err := s.loadSessions()
+	if err != nil {
+		return err
+	}
+	if _, ok := sessions[id]; !ok {
+		return nil
+	}
+	delete(sessions, id)
+
+	return s.saveSessions(sessions)
+}
+
+// ListSessions returns every login session in the sessions file
+func (s *FileStore) ListSessions() ([]Session, error) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	sessions, err := s.loadSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, session)
+	}
+	return list, nil
+}
+
+func (s *FileStore) loadSessions() (map[string]Session, error) {
+	sessions := make(map[string]Session)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, sessionsFile))
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to decode sessions: %v", err)
+	}
+
+	return sessions, nil
+}
+
+func (s *FileStore) saveSessions(sessions map[string]Session) error {
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %v", err)
+	}
+
+	return writeFileAtomic(filepath.Join(s.dir, sessionsFile), data, 0600)
+}
+
+// LoadAPIToken returns an API token from the tokens file
+func (s *FileStore) LoadAPIToken(id string) (*APIToken, error) {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+
+	tokens, err := s.loadTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	token, ok := tokens[id]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// SaveAPIToken writes an API token to the tokens file
+func (s *FileStore) SaveAPIToken(token *APIToken) error {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+
+	tokens, err := s.loadTokens()
+	if err != nil {
+		return err
+	}
+	tokens[token.ID] = *token
+
+	return s.saveTokens(tokens)
+}
+
+// DeleteAPIToken removes an API token from the tokens file
+func (s *FileStore) DeleteAPIToken(id string) error {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+
+	tokens, err := s.loadTokens()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[id]; !ok {
+		return nil
+	}
+	delete(tokens, id)
+
+	return s.saveTokens(tokens)
+}
+
+// ListAPITokens returns every API token in the tokens file
+func (s *FileStore) ListAPITokens() ([]APIToken, error) {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+
+	tokens, err := s.loadTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		list = append(list, token)
+	}
+	return list, nil
+}
+
+func (s *FileStore) loadTokens() (map[string]APIToken, error) {
+	tokens := make(map[string]APIToken)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, tokensFile))
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode API tokens: %v", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *FileStore) saveTokens(tokens map[string]APIToken) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("failed to encode API tokens: %v", err)
+	}
+
+	return writeFileAtomic(filepath.Join(s.dir, tokensFile), data, 0600)
+}
+
+// AppendAuditEvents appends events to the audit log, one JSON encoded event per line
+func (s *FileStore) AppendAuditEvents(events ...model.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(s.dir, auditFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to encode audit event: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// LoadAuditEvents returns the most recent events from the audit log, newest last. A limit of 0 returns every event.
+func (s *FileStore) LoadAuditEvents(limit int) ([]model.AuditEvent, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	file, err := os.Open(filepath.Join(s.dir, auditFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []model.AuditEvent{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	events := []model.AuditEvent{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event model.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode audit event: %v", err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	return events, nil
+}
+
+// LoadSetting returns a setting from the settings file
+func (s *FileStore) LoadSetting(name string) (string, error) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	settings, err := s.loadSettings()
+	if err != nil {
+		return "", err
+	}
+
+	return settings[name], nil
+}
+
+// SaveSetting writes a setting to the settings file
+func (s *FileStore) SaveSetting(name string, value string) error {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	settings, err := s.loadSettings()
+	if err != nil {
+		return err
+	}
+	settings[name] = value
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %v", err)
+	}
+
+	return writeFileAtomic(filepath.Join(s.dir, settingsFile), data, 0600)
+}
+
+func (s *FileStore) loadSettings() (map[string]string, error) {
+	settings := make(map[string]string)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, settingsFile))
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode settings: %v", err)
+	}
+
+	return settings, nil
+}
+
+// Close is a no-op, the file store holds no open resources
+func (s *FileStore) Close() error {
+	return nil
+}
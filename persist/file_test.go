@@ -0,0 +1,80 @@
+package persist
+
+import (
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestFileStoreConcurrentUpdates(t *testing.T) {
+	const n = 50
+	dir := t.TempDir()
+
+	st, err := NewFileStore(dir, testKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	addConcurrently(t, st, n)
+
+	reopened, err := NewFileStore(dir, testKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	checkAllAdded(t, reopened, n)
+}
+
+// TestFileStoreRestoreKeepsUnreadableIdentities checks a restore never quarantines over, or deletes, identities it cannot decrypt
+func TestFileStoreRestoreKeepsUnreadableIdentities(t *testing.T) {
+	const mainIdentity = 1
+	dir := t.TempDir()
+	oldKeys := [][]byte{[]byte("old-key-0123456789abcdef01234567")}
+	newKeys := [][]byte{[]byte("new-key-0123456789abcdef01234567")}
+
+	oldStore, err := NewFileStore(dir, oldKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	newStore, err := NewFileStore(dir, newKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	// Identities saved under the old key are quarantined once only the new key is configured
+	if err := oldStore.SaveIdentities(mainIdentity, &Identities{Tokens: map[int64]oauth2.Token{1: {AccessToken: "old"}}}); err != nil {
+		t.Fatalf("SaveIdentities: %v", err)
+	}
+	if _, err := newStore.LoadIdentities(mainIdentity); err != nil {
+		t.Fatalf("LoadIdentities: %v", err)
+	}
+	if err := newStore.SaveIdentities(mainIdentity, &Identities{Tokens: map[int64]oauth2.Token{2: {AccessToken: "new"}}}); err != nil {
+		t.Fatalf("SaveIdentities: %v", err)
+	}
+
+	// A store holding only the old key can read the quarantined file but not the current one
+	if err := oldStore.RestoreQuarantined(mainIdentity); err == nil {
+		t.Fatal("RestoreQuarantined succeeded without being able to decrypt the current identities")
+	}
+
+	bothStore, err := NewFileStore(dir, append(newKeys, oldKeys...), 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if err := bothStore.RestoreQuarantined(mainIdentity); err != nil {
+		t.Fatalf("RestoreQuarantined: %v", err)
+	}
+	identities, err := bothStore.LoadIdentities(mainIdentity)
+	if err != nil {
+		t.Fatalf("LoadIdentities: %v", err)
+	}
+	if len(identities.Tokens) != 2 {
+		t.Errorf("got %d tokens after restoring, want both the current and the quarantined token", len(identities.Tokens))
+	}
+}
+
+func TestFileStoreKeepsEveryQuarantinedCopy(t *testing.T) {
+	st, err := NewFileStore(t.TempDir(), testKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	quarantineTwice(t, st, func(keys [][]byte) { st.keys = keys }, [][]byte{[]byte("old-key-0123456789abcdef01234567")}, testKeys)
+}
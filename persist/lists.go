@@ -0,0 +1,121 @@
+package persist
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+var (
+	// ErrListNotFound is returned for a trust list that has not been created
+	ErrListNotFound = errors.New("trust list not found")
+	// ErrListExists is returned when creating a trust list with a name that is already taken
+	ErrListExists = errors.New("trust list already exists")
+	// ErrDefaultList is returned when deleting the default list
+	ErrDefaultList = errors.New("the default list cannot be deleted")
+)
+
+// listNamePattern limits list names to characters that are safe in file names and URLs
+var listNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// ValidListName reports whether name can be used for a trust list
+func ValidListName(name string) bool {
+	return listNamePattern.MatchString(name)
+}
+
+// defaultTrustList is the definition of the default list until it has been changed
+func defaultTrustList() model.TrustList {
+	return model.TrustList{
+		Name:        model.DefaultList,
+		Description: "Shared by everyone on this instance",
+		Access:      model.AccessPublic,
+	}
+}
+
+// withDefaultList returns lists with the default list first, adding it if it has no stored definition
+func withDefaultList(lists []model.TrustList) []model.TrustList {
+	result := []model.TrustList{defaultTrustList()}
+	for _, list := range lists {
+		if list.Name == model.DefaultList {
+			result[0] = list
+			continue
+		}
+		result = append(result, list)
+	}
+	return result
+}
+
+// hasList reports whether lists contains a list called name. The default list always exists.
+func hasList(lists []model.TrustList, name string) bool {
+	if name == model.DefaultList {
+		return true
+	}
+	for _, list := range lists {
+		if list.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateTrustList adds a new list definition, failing if the name is invalid or taken
+func CreateTrustList(st Store, list model.TrustList) error {
+	if !ValidListName(list.Name) {
+		return errors.New("list names must be 1-32 lowercase letters, digits or dashes")
+	}
+	return st.UpdateTrustLists(func(lists *[]model.TrustList) error {
+		if hasList(*lists, list.Name) {
+			return ErrListExists
+		}
+		*lists = append(*lists, list)
+		return nil
+	})
+}
+
+// SaveTrustList replaces the definition of an existing list
+func SaveTrustList(st Store, list model.TrustList) error {
+	return st.UpdateTrustLists(func(lists *[]model.TrustList) error {
+		for i := range *lists {
+			if (*lists)[i].Name == list.Name {
+				(*lists)[i] = list
+				return nil
+			}
+		}
+		return ErrListNotFound
+	})
+}
+
+// FindTrustList returns the definition of the named list
+func FindTrustList(st Store, name string) (model.TrustList, error) {
+	lists, err := st.LoadTrustLists()
+	if err != nil {
+		return model.TrustList{}, err
+	}
+	for _, list := range lists {
+		if list.Name == name {
+			return list, nil
+		}
+	}
+	return model.TrustList{}, ErrListNotFound
+}
+
+// SubscribedLists returns the lists a character syncs its contacts from. Characters that have
+// never changed their subscriptions sync all of the default list.
+func SubscribedLists(subscriptions map[int64][]model.Subscription, characterID int64) []model.Subscription {
+	lists, ok := subscriptions[characterID]
+	if !ok {
+		return []model.Subscription{{List: model.DefaultList}}
+	}
+	return lists
+}
+
+// FindSubscription returns a character's subscription to a list, if it has one
+func FindSubscription(subscriptions map[int64][]model.Subscription, characterID int64, list string) (model.Subscription, bool) {
+	for _, subscription := range SubscribedLists(subscriptions, characterID) {
+		if subscription.List == list {
+			return subscription, true
+		}
+	}
+	return model.Subscription{}, false
+}
@@ -0,0 +1,82 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNotQuarantined is returned when restoring identities that are not in quarantine
+var ErrNotQuarantined = errors.New("identity is not quarantined")
+
+// QuarantinedIdentity describes stored identities that could not be decrypted and were set aside
+type QuarantinedIdentity struct {
+	// ID tells apart copies quarantined for the same main identity
+	ID            string    `json:"id"`
+	MainIdentity  int64     `json:"main_identity"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// quarantineReason describes why identities were quarantined
+func quarantineReason(err error) string {
+	return fmt.Sprintf("failed to decrypt: %v", err)
+}
+
+// decryptCurrent decrypts the identities a quarantined copy is being restored into. Nothing is restored when the
+// current identities cannot be decrypted either, so neither copy is lost.
+func decryptCurrent(keys [][]byte, encrypted []byte, current *Identities) error {
+	if len(encrypted) == 0 {
+		return nil
+	}
+	if _, err := decryptData(keys, encrypted, current); err != nil {
+		return fmt.Errorf("current identities cannot be decrypted: %w", err)
+	}
+	return nil
+}
+
+// restoreCopies merges every quarantined copy that can now be decrypted into current. Copies are given newest first
+// so their tokens win over older ones. It returns which copies were restored, and the last error when none were.
+func restoreCopies(keys [][]byte, copies [][]byte, current *Identities) ([]bool, error) {
+	restored := make([]bool, len(copies))
+	var lastErr error
+	for i, encrypted := range copies {
+		if err := restoreIdentities(keys, encrypted, current); err != nil {
+			lastErr = err
+			continue
+		}
+		restored[i] = true
+	}
+	if !slices.Contains(restored, true) {
+		return nil, lastErr
+	}
+	return restored, nil
+}
+
+// quarantineStamp makes the name of each quarantined copy unique so an earlier copy is never overwritten
+func quarantineStamp(at time.Time) string {
+	return at.UTC().Format("20060102T150405.000000000Z")
+}
+
+// restoreIdentities decrypts quarantined identities and merges them into the current ones.
+// Tokens stored since the quarantine take precedence over the restored ones.
+func restoreIdentities(keys [][]byte, encrypted []byte, current *Identities) error {
+	restored := &Identities{Tokens: make(map[int64]oauth2.Token)}
+	if _, err := decryptData(keys, encrypted, restored); err != nil {
+		return fmt.Errorf("still unable to decrypt: %w", err)
+	}
+
+	if current.MainIdentity == "" {
+		current.MainIdentity = restored.MainIdentity
+	}
+	for id, token := range restored.Tokens {
+		if _, exists := current.Tokens[id]; !exists {
+			current.Tokens[id] = token
+		}
+	}
+
+	return nil
+}
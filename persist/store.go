@@ -0,0 +1,146 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+// Storage backends
+const (
+	BackendFile = "file"
+	BackendBolt = "bolt"
+)
+
+// ErrUnchanged can be returned from an UpdateTrusted or UpdateTrustLists function to leave the data as it is
+var ErrUnchanged = errors.New("trust list unchanged")
+
+// Store persists trust lists, subscriptions, managed contacts, identities, sessions, API tokens, audit events and settings
+type Store interface {
+	// LoadTrustedCharacters loads the trusted and untrusted characters and corporations on a list
+	LoadTrustedCharacters(list string) (*model.TrustedCharacters, error)
+	// SaveTrustedCharacters replaces the entries on a list
+	SaveTrustedCharacters(list string, trustedData *model.TrustedCharacters) error
+	// UpdateTrusted applies updateFunc to the entries on a list and saves them, with no other update in between.
+	// The list revision is incremented before updateFunc runs so changed entries can be stamped with it.
+	// Nothing is saved if updateFunc returns an error, and ErrUnchanged skips the save without failing.
+	// Updating a list that has not been created returns ErrListNotFound.
+	UpdateTrusted(list string, updateFunc func(*model.TrustedCharacters) error) error
+
+	// LoadTrustLists returns the definition of every list, the default list first
+	LoadTrustLists() ([]model.TrustList, error)
+	// UpdateTrustLists applies updateFunc to the list definitions and saves them, with no other update in between
+	UpdateTrustLists(updateFunc func(*[]model.TrustList) error) error
+	// DeleteTrustList removes a list definition and its entries. The default list cannot be deleted.
+	DeleteTrustList(name string) error
+
+	// LoadSubscriptions returns the lists each character of a main identity syncs, by character ID
+	LoadSubscriptions(mainIdentity int64) (map[int64][]model.Subscription, error)
+	// SaveSubscriptions replaces the list subscriptions of a main identity's characters
+	SaveSubscriptions(mainIdentity int64, subscriptions map[int64][]model.Subscription) error
+
+	// LoadManagedContacts returns the IDs of the contacts the contact sync added to a character, the only ones it removes
+	LoadManagedContacts(characterID int64) ([]int64, error)
+	// SaveManagedContacts replaces the IDs of the contacts the contact sync added to a character
+	SaveManagedContacts(characterID int64, contactIDs []int64) error
+
+	// LoadIdentities loads the tokens for every character authenticated by a main identity.
+	// Identities that cannot be decrypted are quarantined and an empty set is returned.
+	LoadIdentities(mainIdentity int64) (*Identities, error)
+	// SaveIdentities replaces the stored tokens for a main identity
+	SaveIdentities(mainIdentity int64, ids *Identities) error
+	// DeleteIdentity removes the stored tokens for a main identity
+	DeleteIdentity(mainIdentity int64) error
+	// ListQuarantined returns identities that could not be decrypted and were set aside
+	ListQuarantined() ([]QuarantinedIdentity, error)
+	// RestoreQuarantined decrypts quarantined identities with the configured keys and merges them back
+	RestoreQuarantined(mainIdentity int64) error
+	// RekeyIdentities re-encrypts all stored identities with the primary key and returns how many were rewritten
+	RekeyIdentities() (int, error)
+
+	// LoadSession returns a login session, or nil if it does not exist
+	LoadSession(id string) (*Session, error)
+	// SaveSession creates or replaces a login session
+	SaveSession(session *Session) error
+	// DeleteSession removes a login session
+	DeleteSession(id string) error
+	// ListSessions returns every stored login session
+	ListSessions() ([]Session, error)
+
+	// LoadAPIToken returns an API token by the hash of its secret, or nil if it does not exist
+	LoadAPIToken(id string) (*APIToken, error)
+	// SaveAPIToken creates or replaces an API token
+	SaveAPIToken(token *APIToken) error
+	// DeleteAPIToken removes an API token
+	DeleteAPIToken(id string) error
+	// ListAPITokens returns every stored API token
+	ListAPITokens() ([]APIToken, error)
+
+	// AppendAuditEvents appends events to the audit log
+	AppendAuditEvents(events ...model.AuditEvent) error
+	// LoadAuditEvents returns the most recent events, newest last. A limit of 0 returns every event.
+	LoadAuditEvents(limit int) ([]model.AuditEvent, error)
+
+	// LoadSetting returns a stored setting, or an empty string if it has not been set
+	LoadSetting(name string) (string, error)
+	// SaveSetting stores a setting
+	SaveSetting(name string, value string) error
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// Config selects and configures a storage backend
+type Config struct {
+	// Backend is BackendFile or BackendBolt, defaults to BackendFile
+	Backend string
+	// Dir is the directory the data is stored in
+	Dir string
+	// Key encrypts identity tokens at rest
+	Key []byte
+	// PreviousKeys are older keys that identities may still be encrypted with. Identities are
+	// re-encrypted with Key when they are next read.
+	PreviousKeys [][]byte
+	// Backups is the number of previous trust list versions the file backend keeps, defaults to 5.
+	// A negative value disables backups.
+	Backups int
+}
+
+// Open creates the data directory and opens the configured backend
+func Open(config Config) (Store, error) {
+	if config.Dir == "" {
+		config.Dir = defaultDir
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	keys := append([][]byte{config.Key}, config.PreviousKeys...)
+
+	switch config.Backend {
+	case "", BackendFile:
+		backups := config.Backups
+		if backups == 0 {
+			backups = defaultBackups
+		}
+		return NewFileStore(config.Dir, keys, backups)
+	case BackendBolt:
+		return NewBoltStore(filepath.Join(config.Dir, boltFileName), keys)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", config.Backend)
+	}
+}
+
+// emptyTrustedCharacters returns a trust list with no entries
+func emptyTrustedCharacters() *model.TrustedCharacters {
+	return &model.TrustedCharacters{
+		TrustedCharacters:     []model.TrustedCharacter{},
+		TrustedCorporations:   []model.TrustedCorporation{},
+		UntrustedCharacters:   []model.TrustedCharacter{},
+		UntrustedCorporations: []model.TrustedCorporation{},
+	}
+}
@@ -0,0 +1,103 @@
+package persist
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+// testKeys are the data keys test stores encrypt identities with
+var testKeys = [][]byte{[]byte("0123456789abcdef0123456789abcdef")}
+
+// addConcurrently adds n trusted characters to the default list from n goroutines at once
+func addConcurrently(t *testing.T, st Store, n int) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			_, err := AddTrustedCharacter(st, model.DefaultList, model.TrustedCharacter{CharacterID: id, CharacterName: "Pilot"}, AnyRevision)
+			errs <- err
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AddTrustedCharacter: %v", err)
+		}
+	}
+}
+
+// checkAllAdded fails unless the default list holds characters 1 to n and was saved n times
+func checkAllAdded(t *testing.T, st Store, n int) {
+	t.Helper()
+
+	trustedData, err := st.LoadTrustedCharacters(model.DefaultList)
+	if err != nil {
+		t.Fatalf("LoadTrustedCharacters: %v", err)
+	}
+	if len(trustedData.TrustedCharacters) != n {
+		t.Errorf("got %d trusted characters, want %d", len(trustedData.TrustedCharacters), n)
+	}
+	if trustedData.Revision != int64(n) {
+		t.Errorf("got revision %d, want %d", trustedData.Revision, n)
+	}
+
+	seen := make(map[int64]bool)
+	for _, char := range trustedData.TrustedCharacters {
+		seen[char.CharacterID] = true
+	}
+	for id := int64(1); id <= int64(n); id++ {
+		if !seen[id] {
+			t.Errorf("character %d was lost", id)
+		}
+	}
+}
+
+// quarantineTwice quarantines two copies of the same identity, each saved under oldKeys and read under newKeys.
+// setKeys switches the keys the store uses.
+func quarantineTwice(t *testing.T, st Store, setKeys func([][]byte), oldKeys, newKeys [][]byte) {
+	t.Helper()
+
+	for id := int64(1); id <= 2; id++ {
+		setKeys(oldKeys)
+		if err := st.SaveIdentities(1, &Identities{Tokens: map[int64]oauth2.Token{id: {AccessToken: "token"}}}); err != nil {
+			t.Fatalf("SaveIdentities: %v", err)
+		}
+		setKeys(newKeys)
+		if _, err := st.LoadIdentities(1); err != nil {
+			t.Fatalf("LoadIdentities: %v", err)
+		}
+	}
+
+	quarantined, err := st.ListQuarantined()
+	if err != nil {
+		t.Fatalf("ListQuarantined: %v", err)
+	}
+	if len(quarantined) != 2 {
+		t.Fatalf("got %d quarantined copies, want 2", len(quarantined))
+	}
+
+	setKeys(append(newKeys, oldKeys...))
+	if err := st.RestoreQuarantined(1); err != nil {
+		t.Fatalf("RestoreQuarantined: %v", err)
+	}
+	identities, err := st.LoadIdentities(1)
+	if err != nil {
+		t.Fatalf("LoadIdentities: %v", err)
+	}
+	if len(identities.Tokens) != 2 {
+		t.Errorf("got %d tokens after restoring, want the tokens from both copies", len(identities.Tokens))
+	}
+	if quarantined, _ := st.ListQuarantined(); len(quarantined) != 0 {
+		t.Errorf("got %d quarantined copies after restoring, want 0", len(quarantined))
+	}
+}
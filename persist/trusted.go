@@ -1,214 +1,473 @@
 package persist
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
+	"slices"
+	"time"
+
 	"github.com/gambtho/whototrust/model"
 	"github.com/gambtho/whototrust/xlog"
-	"os"
-	"sync"
 )
 
-const trustedCharactersFile = "data/trusted_characters.json"
+// AnyRevision skips the revision check when adding or removing an entry
+const AnyRevision int64 = -1
 
-// Mutex for safe concurrent access
-var mu sync.Mutex
+// ErrRevisionConflict is returned when an entry is not at the revision the caller expected
+var ErrRevisionConflict = errors.New("entry was changed by someone else")
 
-// LoadTrustedCharacters loads trusted characters and corporations from a file
-func LoadTrustedCharacters() (*model.TrustedCharacters, error) {
-	mu.Lock()
-	defer mu.Unlock()
+// ErrEntryNotFound is returned when changing an entry that is not on the list
+var ErrEntryNotFound = errors.New("entry not found")
 
-	file, err := os.Open(trustedCharactersFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return &model.TrustedCharacters{
-				TrustedCharacters:     []model.TrustedCharacter{},
-				TrustedCorporations:   []model.TrustedCorporation{},
-				UntrustedCharacters:   []model.TrustedCharacter{},
-				UntrustedCorporations: []model.TrustedCorporation{},
-			}, nil
-		}
-		return nil, fmt.Errorf("failed to open trusted characters file: %v", err)
-	}
-	defer file.Close()
+// EntryChange holds the fields to change on an entry. Nil fields are left as they are, a zero ExpiresAt clears
+// the expiry and Tags replaces all of the entry's tags.
+type EntryChange struct {
+	Comment   *string
+	Standing  *float64
+	ExpiresAt *time.Time
+	Tags      *[]string
+}
 
-	var trustedData model.TrustedCharacters
-	if err := json.NewDecoder(file).Decode(&trustedData); err != nil {
-		return nil, fmt.Errorf("failed to decode trusted characters: %v", err)
+// CheckRevision returns ErrRevisionConflict if an entry at current is not at the expected revision
+func CheckRevision(current int64, expected int64) error {
+	if expected != AnyRevision && current != expected {
+		return ErrRevisionConflict
 	}
+	return nil
+}
+
+// AddTrustedCharacter adds a new character to the trusted list and reports whether it was added
+// Unless revision is AnyRevision, a character that is already on the list is a conflict.
+func AddTrustedCharacter(st Store, list string, newCharacter model.TrustedCharacter, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		// Check for duplicate
+		for _, char := range trustedData.TrustedCharacters {
+			if char.CharacterID == newCharacter.CharacterID {
+				return existingEntry(revision)
+			}
+		}
 
-	return &trustedData, nil
+		newCharacter.Revision = trustedData.Revision
+		trustedData.TrustedCharacters = append(trustedData.TrustedCharacters, newCharacter)
+		return nil
+	})
 }
 
-// SaveTrustedCharacters saves trusted characters and corporations to a file
-func SaveTrustedCharacters(trustedData *model.TrustedCharacters) error {
-	mu.Lock()
-	defer mu.Unlock()
+// RemoveTrustedCharacter removes a character from the trusted list by CharacterID and reports whether it was removed
+func RemoveTrustedCharacter(st Store, list string, characterID int64, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		var err error
+		trustedData.TrustedCharacters, err = removeCharacter(trustedData.TrustedCharacters, characterID, revision)
+		return err
+	})
+}
 
-	file, err := os.Create(trustedCharactersFile)
-	if err != nil {
-		return fmt.Errorf("failed to create trusted characters file: %v", err)
-	}
-	defer file.Close()
+// AddTrustedCorporation adds a new corporation to the trusted list and reports whether it was added
+func AddTrustedCorporation(st Store, list string, newCorporation model.TrustedCorporation, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		// Check for duplicate
+		for _, corp := range trustedData.TrustedCorporations {
+			if corp.CorporationID == newCorporation.CorporationID {
+				xlog.Logf("corporation already exists")
+				return existingEntry(revision)
+			}
+		}
 
-	if err := json.NewEncoder(file).Encode(trustedData); err != nil {
-		return fmt.Errorf("failed to encode trusted characters: %v", err)
-	}
+		newCorporation.Revision = trustedData.Revision
+		trustedData.TrustedCorporations = append(trustedData.TrustedCorporations, newCorporation)
+		return nil
+	})
+}
 
-	return nil
+// RemoveTrustedCorporation removes a corporation from the trusted list by CorporationID and reports whether it was removed
+func RemoveTrustedCorporation(st Store, list string, id int64, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		var err error
+		trustedData.TrustedCorporations, err = removeCorporation(trustedData.TrustedCorporations, id, revision)
+		return err
+	})
 }
 
-// AddTrustedCharacter adds a new character to the trusted list
-func AddTrustedCharacter(newCharacter model.TrustedCharacter) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
-	}
+// RemoveUntrustedCorporation removes a corporation from the untrusted list by CorporationID and reports whether it was removed
+func RemoveUntrustedCorporation(st Store, list string, id int64, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		var err error
+		trustedData.UntrustedCorporations, err = removeCorporation(trustedData.UntrustedCorporations, id, revision)
+		return err
+	})
+}
 
-	// Check for duplicate
-	for _, char := range trustedData.TrustedCharacters {
-		if char.CharacterID == newCharacter.CharacterID {
-			return nil
+// AddUntrustedCorporation adds a new corporation to the untrusted list and reports whether it was added
+func AddUntrustedCorporation(st Store, list string, newCorporation model.TrustedCorporation, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		// Check for duplicate
+		for _, corp := range trustedData.UntrustedCorporations {
+			if corp.CorporationID == newCorporation.CorporationID {
+				xlog.Logf("corporation already exists in untrusted list")
+				return existingEntry(revision)
+			}
 		}
-	}
 
-	trustedData.TrustedCharacters = append(trustedData.TrustedCharacters, newCharacter)
+		newCorporation.Revision = trustedData.Revision
+		trustedData.UntrustedCorporations = append(trustedData.UntrustedCorporations, newCorporation)
+		return nil
+	})
+}
 
-	return SaveTrustedCharacters(trustedData)
+// AddUntrustedCharacter adds a new character to the untrusted list and reports whether it was added
+func AddUntrustedCharacter(st Store, list string, newCharacter model.TrustedCharacter, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		// Check for duplicate
+		for _, char := range trustedData.UntrustedCharacters {
+			if char.CharacterID == newCharacter.CharacterID {
+				xlog.Logf("character already exists in untrusted list")
+				return existingEntry(revision)
+			}
+		}
+
+		newCharacter.Revision = trustedData.Revision
+		trustedData.UntrustedCharacters = append(trustedData.UntrustedCharacters, newCharacter)
+		return nil
+	})
 }
 
-// RemoveTrustedCharacter removes a character from the trusted list by CorporationID
-func RemoveTrustedCharacter(characterID int64) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
-	}
+// RemoveUntrustedCharacter removes a character from the untrusted list by CharacterID and reports whether it was removed
+func RemoveUntrustedCharacter(st Store, list string, characterID int64, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		var err error
+		trustedData.UntrustedCharacters, err = removeCharacter(trustedData.UntrustedCharacters, characterID, revision)
+		return err
+	})
+}
 
-	updatedCharacters := make([]model.TrustedCharacter, 0, len(trustedData.TrustedCharacters))
-	for _, char := range trustedData.TrustedCharacters {
-		if char.CharacterID != characterID {
-			updatedCharacters = append(updatedCharacters, char)
+// AddTrustedAlliance adds a new alliance to the trusted list and reports whether it was added
+func AddTrustedAlliance(st Store, list string, newAlliance model.TrustedAlliance, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		if hasAlliance(trustedData.TrustedAlliances, newAlliance.AllianceID) {
+			xlog.Logf("alliance already exists")
+			return existingEntry(revision)
 		}
-	}
-
-	trustedData.TrustedCharacters = updatedCharacters
 
-	return SaveTrustedCharacters(trustedData)
+		newAlliance.Revision = trustedData.Revision
+		trustedData.TrustedAlliances = append(trustedData.TrustedAlliances, newAlliance)
+		return nil
+	})
 }
 
-// AddTrustedCorporation adds a new corporation to the trusted list
-func AddTrustedCorporation(newCorporation model.TrustedCorporation) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
-	}
+// RemoveTrustedAlliance removes an alliance from the trusted list by AllianceID and reports whether it was removed
+func RemoveTrustedAlliance(st Store, list string, id int64, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		var err error
+		trustedData.TrustedAlliances, err = removeAlliance(trustedData.TrustedAlliances, id, revision)
+		return err
+	})
+}
 
-	// Check for duplicate
-	for _, corp := range trustedData.TrustedCorporations {
-		if corp.CorporationID == newCorporation.CorporationID {
-			xlog.Logf("corporation already exists - returning success")
-			return nil
+// AddUntrustedAlliance adds a new alliance to the untrusted list and reports whether it was added
+func AddUntrustedAlliance(st Store, list string, newAlliance model.TrustedAlliance, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		if hasAlliance(trustedData.UntrustedAlliances, newAlliance.AllianceID) {
+			xlog.Logf("alliance already exists in untrusted list")
+			return existingEntry(revision)
 		}
-	}
 
-	trustedData.TrustedCorporations = append(trustedData.TrustedCorporations, newCorporation)
+		newAlliance.Revision = trustedData.Revision
+		trustedData.UntrustedAlliances = append(trustedData.UntrustedAlliances, newAlliance)
+		return nil
+	})
+}
 
-	return SaveTrustedCharacters(trustedData)
+// RemoveUntrustedAlliance removes an alliance from the untrusted list by AllianceID and reports whether it was removed
+func RemoveUntrustedAlliance(st Store, list string, id int64, revision int64) (bool, error) {
+	return changeTrusted(st, list, func(trustedData *model.TrustedCharacters) error {
+		var err error
+		trustedData.UntrustedAlliances, err = removeAlliance(trustedData.UntrustedAlliances, id, revision)
+		return err
+	})
 }
 
-// RemoveTrustedCorporation removes a corporation from the trusted list by CorporationID
-func RemoveTrustedCorporation(id int64) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
-	}
+// changeTrusted runs updateFunc in UpdateTrusted and reports whether it changed the list. ErrUnchanged from
+// updateFunc leaves the list as it is and reports no change.
+func changeTrusted(st Store, list string, updateFunc func(*model.TrustedCharacters) error) (bool, error) {
+	changed := false
+	err := st.UpdateTrusted(list, func(trustedData *model.TrustedCharacters) error {
+		changed = false
+		if err := updateFunc(trustedData); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
 
-	updatedCorporations := make([]model.TrustedCorporation, 0, len(trustedData.TrustedCorporations))
-	for _, corp := range trustedData.TrustedCorporations {
-		if corp.CorporationID != id {
-			updatedCorporations = append(updatedCorporations, corp)
+// AddEntries adds entries of any status and type in a single update and returns the ones that were added.
+// Entries already on the list are skipped.
+func AddEntries(st Store, list string, entries []model.TrustEntry) ([]model.TrustEntry, error) {
+	var added []model.TrustEntry
+	err := st.UpdateTrusted(list, func(trustedData *model.TrustedCharacters) error {
+		added = nil
+		for _, entry := range entries {
+			entry.Revision = trustedData.Revision
+			characters, corporations, alliances := &trustedData.TrustedCharacters, &trustedData.TrustedCorporations, &trustedData.TrustedAlliances
+			if entry.Status == "untrusted" {
+				characters, corporations, alliances = &trustedData.UntrustedCharacters, &trustedData.UntrustedCorporations, &trustedData.UntrustedAlliances
+			}
+
+			switch entry.Type {
+			case "character":
+				if hasCharacter(*characters, entry.ID) {
+					continue
+				}
+				*characters = append(*characters, model.TrustedCharacter{
+					CharacterID:     entry.ID,
+					CharacterName:   entry.Name,
+					CorporationID:   entry.CorporationID,
+					CorporationName: entry.CorporationName,
+					AllianceID:      entry.AllianceID,
+					AllianceName:    entry.AllianceName,
+					AddedBy:         entry.AddedBy,
+					DateAdded:       entry.DateAdded,
+					Comment:         entry.Comment,
+					Standing:        entry.Standing,
+					ExpiresAt:       entry.ExpiresAt,
+					Tags:            entry.Tags,
+					Revision:        entry.Revision,
+				})
+			case "alliance":
+				if hasAlliance(*alliances, entry.ID) {
+					continue
+				}
+				*alliances = append(*alliances, model.TrustedAlliance{
+					AllianceID:   entry.ID,
+					AllianceName: entry.Name,
+					AddedBy:      entry.AddedBy,
+					DateAdded:    entry.DateAdded,
+					Comment:      entry.Comment,
+					Standing:     entry.Standing,
+					ExpiresAt:    entry.ExpiresAt,
+					Tags:         entry.Tags,
+					Revision:     entry.Revision,
+				})
+			default:
+				if hasCorporation(*corporations, entry.ID) {
+					continue
+				}
+				*corporations = append(*corporations, model.TrustedCorporation{
+					CorporationID:   entry.ID,
+					CorporationName: entry.Name,
+					AllianceID:      entry.AllianceID,
+					AllianceName:    entry.AllianceName,
+					AddedBy:         entry.AddedBy,
+					DateAdded:       entry.DateAdded,
+					Comment:         entry.Comment,
+					Standing:        entry.Standing,
+					ExpiresAt:       entry.ExpiresAt,
+					Tags:            entry.Tags,
+					Revision:        entry.Revision,
+				})
+			}
+			added = append(added, entry)
 		}
-	}
 
-	trustedData.TrustedCorporations = updatedCorporations
+		if len(added) == 0 {
+			return ErrUnchanged
+		}
+		return nil
+	})
+	return added, err
+}
 
-	return SaveTrustedCharacters(trustedData)
+func hasCharacter(characters []model.TrustedCharacter, id int64) bool {
+	for _, char := range characters {
+		if char.CharacterID == id {
+			return true
+		}
+	}
+	return false
 }
 
-func RemoveUntrustedCorporation(id int64) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
+func hasAlliance(alliances []model.TrustedAlliance, id int64) bool {
+	for _, alliance := range alliances {
+		if alliance.AllianceID == id {
+			return true
+		}
 	}
+	return false
+}
 
-	updatedCorporations := make([]model.TrustedCorporation, 0, len(trustedData.UntrustedCorporations))
-	for _, corp := range trustedData.UntrustedCorporations {
-		if corp.CorporationID != id {
-			updatedCorporations = append(updatedCorporations, corp)
+func hasCorporation(corporations []model.TrustedCorporation, id int64) bool {
+	for _, corp := range corporations {
+		if corp.CorporationID == id {
+			return true
 		}
 	}
+	return false
+}
+
+// UpdateEntry changes the comment, standing, expiry or tags of an entry and returns its name and new revision.
+// Unless revision is AnyRevision, an entry that has been removed is a conflict rather than missing.
+func UpdateEntry(st Store, list string, trustStatus string, entityType string, id int64, revision int64, change EntryChange) (string, int64, error) {
+	var name string
+	var newRevision int64
+	err := st.UpdateTrusted(list, func(data *model.TrustedCharacters) error {
+		characters, corporations, alliances := data.TrustedCharacters, data.TrustedCorporations, data.TrustedAlliances
+		if trustStatus == "untrusted" {
+			characters, corporations, alliances = data.UntrustedCharacters, data.UntrustedCorporations, data.UntrustedAlliances
+		}
 
-	trustedData.UntrustedCorporations = updatedCorporations
+		switch entityType {
+		case "character":
+			for i := range characters {
+				if characters[i].CharacterID == id {
+					if err := CheckRevision(characters[i].Revision, revision); err != nil {
+						return err
+					}
+					applyChange(&characters[i].Comment, &characters[i].Standing, &characters[i].ExpiresAt, &characters[i].Tags, change)
+					characters[i].Revision = data.Revision
+					name, newRevision = characters[i].CharacterName, data.Revision
+					return nil
+				}
+			}
+		case "alliance":
+			for i := range alliances {
+				if alliances[i].AllianceID == id {
+					if err := CheckRevision(alliances[i].Revision, revision); err != nil {
+						return err
+					}
+					applyChange(&alliances[i].Comment, &alliances[i].Standing, &alliances[i].ExpiresAt, &alliances[i].Tags, change)
+					alliances[i].Revision = data.Revision
+					name, newRevision = alliances[i].AllianceName, data.Revision
+					return nil
+				}
+			}
+		default:
+			for i := range corporations {
+				if corporations[i].CorporationID == id {
+					if err := CheckRevision(corporations[i].Revision, revision); err != nil {
+						return err
+					}
+					applyChange(&corporations[i].Comment, &corporations[i].Standing, &corporations[i].ExpiresAt, &corporations[i].Tags, change)
+					corporations[i].Revision = data.Revision
+					name, newRevision = corporations[i].CorporationName, data.Revision
+					return nil
+				}
+			}
+		}
 
-	return SaveTrustedCharacters(trustedData)
+		if revision != AnyRevision {
+			return ErrRevisionConflict
+		}
+		return ErrEntryNotFound
+	})
+	return name, newRevision, err
 }
 
-// AddUntrustedCorporation adds a new corporation to the untrusted list
-func AddUntrustedCorporation(newCorporation model.TrustedCorporation) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
+// applyChange copies the fields set on change to an entry
+func applyChange(comment *string, standing **float64, expiresAt **time.Time, tags *[]string, change EntryChange) {
+	if change.Comment != nil {
+		*comment = *change.Comment
 	}
-
-	// Check for duplicate
-	for _, corp := range trustedData.UntrustedCorporations {
-		if corp.CorporationID == newCorporation.CorporationID {
-			xlog.Logf("corporation already exists in untrusted list - returning success")
-			return nil
+	if change.Standing != nil {
+		value := *change.Standing
+		*standing = &value
+	}
+	if change.ExpiresAt != nil {
+		if change.ExpiresAt.IsZero() {
+			*expiresAt = nil
+		} else {
+			value := *change.ExpiresAt
+			*expiresAt = &value
 		}
 	}
-
-	trustedData.UntrustedCorporations = append(trustedData.UntrustedCorporations, newCorporation)
-
-	return SaveTrustedCharacters(trustedData)
+	if change.Tags != nil {
+		*tags = slices.Clone(*change.Tags)
+	}
 }
 
-// AddUntrustedCharacter adds a new character to the untrusted list
-func AddUntrustedCharacter(newCharacter model.TrustedCharacter) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
+// existingEntry handles adding an entry that is already on the list, which succeeds unless the caller
+// expected the entry not to exist yet
+func existingEntry(revision int64) error {
+	if revision == AnyRevision {
+		xlog.Logf("entry already exists - returning success")
+		return ErrUnchanged
 	}
+	return ErrRevisionConflict
+}
 
-	// Check for duplicate
-	for _, char := range trustedData.UntrustedCharacters {
-		if char.CharacterID == newCharacter.CharacterID {
-			xlog.Logf("character already exists in untrusted list - returning success")
-			return nil
+// removeCharacter removes a character. A missing character is a conflict unless revision is AnyRevision, when it
+// leaves the list unchanged.
+func removeCharacter(characters []model.TrustedCharacter, characterID int64, revision int64) ([]model.TrustedCharacter, error) {
+	found := false
+	updatedCharacters := make([]model.TrustedCharacter, 0, len(characters))
+	for _, char := range characters {
+		if char.CharacterID != characterID {
+			updatedCharacters = append(updatedCharacters, char)
+			continue
+		}
+		if err := CheckRevision(char.Revision, revision); err != nil {
+			return characters, err
 		}
+		found = true
 	}
 
-	trustedData.UntrustedCharacters = append(trustedData.UntrustedCharacters, newCharacter)
-
-	return SaveTrustedCharacters(trustedData)
+	if !found {
+		if revision != AnyRevision {
+			return characters, ErrRevisionConflict
+		}
+		// Nothing to remove, leave the list and its revision as they are
+		return characters, ErrUnchanged
+	}
+	return updatedCharacters, nil
 }
 
-// RemoveUntrustedCharacter removes a character from the untrusted list by CorporationID
-func RemoveUntrustedCharacter(characterID int64) error {
-	trustedData, err := LoadTrustedCharacters()
-	if err != nil {
-		return fmt.Errorf("failed to load trusted data: %v", err)
+// removeCorporation removes a corporation. A missing corporation is a conflict unless revision is AnyRevision, when it
+// leaves the list unchanged.
+func removeCorporation(corporations []model.TrustedCorporation, id int64, revision int64) ([]model.TrustedCorporation, error) {
+	found := false
+	updatedCorporations := make([]model.TrustedCorporation, 0, len(corporations))
+	for _, corp := range corporations {
+		if corp.CorporationID != id {
+			updatedCorporations = append(updatedCorporations, corp)
+			continue
+		}
+		if err := CheckRevision(corp.Revision, revision); err != nil {
+			return corporations, err
+		}
+		found = true
 	}
 
-	updatedCharacters := make([]model.TrustedCharacter, 0, len(trustedData.UntrustedCharacters))
-	for _, char := range trustedData.UntrustedCharacters {
-		if char.CharacterID != characterID {
-			updatedCharacters = append(updatedCharacters, char)
+	if !found {
+		if revision != AnyRevision {
+			return corporations, ErrRevisionConflict
 		}
+		// Nothing to remove, leave the list and its revision as they are
+		return corporations, ErrUnchanged
 	}
+	return updatedCorporations, nil
+}
 
-	trustedData.UntrustedCharacters = updatedCharacters
+// removeAlliance removes an alliance. A missing alliance is a conflict unless revision is AnyRevision, when it
+// leaves the list unchanged.
+func removeAlliance(alliances []model.TrustedAlliance, id int64, revision int64) ([]model.TrustedAlliance, error) {
+	found := false
+	updatedAlliances := make([]model.TrustedAlliance, 0, len(alliances))
+	for _, alliance := range alliances {
+		if alliance.AllianceID != id {
+			updatedAlliances = append(updatedAlliances, alliance)
+			continue
+		}
+		if err := CheckRevision(alliance.Revision, revision); err != nil {
+			return alliances, err
+		}
+		found = true
+	}
 
-	return SaveTrustedCharacters(trustedData)
+	if !found {
+		if revision != AnyRevision {
+			return alliances, ErrRevisionConflict
+		}
+		// Nothing to remove, leave the list and its revision as they are
+		return alliances, ErrUnchanged
+	}
+	return updatedAlliances, nil
 }
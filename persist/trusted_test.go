@@ -0,0 +1,67 @@
+package persist
+
+import (
+	"testing"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+func TestRemoveMissingEntryKeepsRevision(t *testing.T) {
+	st, err := NewFileStore(t.TempDir(), testKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if _, err := AddTrustedCharacter(st, model.DefaultList, model.TrustedCharacter{CharacterID: 1}, AnyRevision); err != nil {
+		t.Fatalf("AddTrustedCharacter: %v", err)
+	}
+
+	removals := map[string]func() (bool, error){
+		"character":   func() (bool, error) { return RemoveTrustedCharacter(st, model.DefaultList, 2, AnyRevision) },
+		"corporation": func() (bool, error) { return RemoveUntrustedCorporation(st, model.DefaultList, 2, AnyRevision) },
+		"alliance":    func() (bool, error) { return RemoveTrustedAlliance(st, model.DefaultList, 2, AnyRevision) },
+	}
+	for name, remove := range removals {
+		t.Run(name, func(t *testing.T) {
+			removed, err := remove()
+			if err != nil {
+				t.Fatalf("removing a missing %s: %v", name, err)
+			}
+			if removed {
+				t.Errorf("removing a missing %s reported a removal", name)
+			}
+			trustedData, err := st.LoadTrustedCharacters(model.DefaultList)
+			if err != nil {
+				t.Fatalf("LoadTrustedCharacters: %v", err)
+			}
+			if trustedData.Revision != 1 {
+				t.Errorf("got revision %d after removing a missing %s, want 1", trustedData.Revision, name)
+			}
+		})
+	}
+
+	if _, err := RemoveTrustedCharacter(st, model.DefaultList, 2, 1); err != ErrRevisionConflict {
+		t.Errorf("removing a missing character at a revision returned %v, want ErrRevisionConflict", err)
+	}
+}
+
+func TestAddReportsDuplicates(t *testing.T) {
+	st, err := NewFileStore(t.TempDir(), testKeys, 5)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	for i, want := range []bool{true, false} {
+		added, err := AddUntrustedAlliance(st, model.DefaultList, model.TrustedAlliance{AllianceID: 1}, AnyRevision)
+		if err != nil {
+			t.Fatalf("AddUntrustedAlliance: %v", err)
+		}
+		if added != want {
+			t.Errorf("add %d reported added=%v, want %v", i+1, added, want)
+		}
+	}
+
+	removed, err := RemoveUntrustedAlliance(st, model.DefaultList, 1, AnyRevision)
+	if err != nil || !removed {
+		t.Errorf("RemoveUntrustedAlliance reported removed=%v, err=%v, want a removal", removed, err)
+	}
+}
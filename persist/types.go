@@ -1,6 +1,8 @@
 package persist
 
 import (
+	"time"
+
 	"golang.org/x/oauth2"
 )
 
@@ -8,3 +10,40 @@ type Identities struct {
 	MainIdentity string                 `json:"main_identity"`
 	Tokens       map[int64]oauth2.Token `json:"identities"`
 }
+
+// Session is a server side login session. ID is a hash of the random token held in the session cookie,
+// so stored sessions cannot be used to log in.
+type Session struct {
+	ID           string    `json:"id"`
+	MainIdentity int64     `json:"main_identity"`
+	Values       []byte    `json:"values"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	UserAgent    string    `json:"user_agent"`
+}
+
+// API token scopes
+const (
+	TokenScopeRead      = "read"
+	TokenScopeReadWrite = "read-write"
+)
+
+// APIToken is a personal token for the JSON API. ID is a hash of the secret token, so stored tokens cannot be
+// used to call the API. The owner's corporation and alliance are recorded when it is created and used for list access.
+type APIToken struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Owner         int64      `json:"owner"`
+	OwnerName     string     `json:"owner_name"`
+	CorporationID int64      `json:"corporation_id,omitempty"`
+	AllianceID    int64      `json:"alliance_id,omitempty"`
+	Scope         string     `json:"scope"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastUsed      *time.Time `json:"last_used,omitempty"`
+}
+
+// Expired reports whether the token has passed its expiry time
+func (t APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
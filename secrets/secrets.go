@@ -0,0 +1,210 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Purposes keys are derived for, used as the HKDF info
+const (
+	purposeCookieAuth       = "whototrust cookie authentication"
+	purposeCookieEncryption = "whototrust cookie encryption"
+	purposeData             = "whototrust data encryption"
+)
+
+// Key lengths in bytes
+const (
+	masterKeyLength        = 32
+	cookieAuthKeyLength    = 64
+	cookieEncryptKeyLength = 32
+	dataKeyLength          = 32
+)
+
+// Keys holds the keys for each purpose, current key first followed by previous keys that are still accepted
+type Keys struct {
+	CookieAuth       [][]byte
+	CookieEncryption [][]byte
+	Data             [][]byte
+
+	// GeneratedSecret is set to a throwaway master secret when no keys were configured
+	GeneratedSecret string
+
+	// legacyCookieSecret is the SECRET_KEY string session cookies used to be signed with
+	legacyCookieSecret []byte
+}
+
+// FromEnv loads the cookie authentication, cookie encryption and data encryption keys.
+//
+// COOKIE_AUTH_KEY, COOKIE_ENCRYPTION_KEY and DATA_KEY set each key directly, with older keys in the matching
+// _PREVIOUS variable. Any key that is not set is derived from SECRET_KEY, and keys derived from SECRET_KEY_PREVIOUS
+// are accepted as previous keys. All values are base64 encoded, previous keys are comma separated.
+func FromEnv() (*Keys, error) {
+	keys := &Keys{}
+
+	masterValue := os.Getenv("SECRET_KEY")
+	var master []byte
+	if masterValue == "" {
+		if os.Getenv("COOKIE_AUTH_KEY") == "" || os.Getenv("COOKIE_ENCRYPTION_KEY") == "" || os.Getenv("DATA_KEY") == "" {
+			master = make([]byte, masterKeyLength)
+			if _, err := rand.Read(master); err != nil {
+				return nil, fmt.Errorf("failed to generate key: %v", err)
+			}
+			keys.GeneratedSecret = base64.StdEncoding.EncodeToString(master)
+		}
+	} else {
+		var err error
+		master, err = decode("SECRET_KEY", masterValue)
+		if err != nil {
+			return nil, err
+		}
+		keys.legacyCookieSecret = []byte(masterValue)
+	}
+
+	previousValues := splitList(os.Getenv("SECRET_KEY_PREVIOUS"))
+	previousMasters := make([][]byte, 0, len(previousValues))
+	for _, value := range previousValues {
+		previous, err := decode("SECRET_KEY_PREVIOUS", value)
+		if err != nil {
+			return nil, err
+		}
+		previousMasters = append(previousMasters, previous)
+	}
+
+	var err error
+	if keys.CookieAuth, err = load("COOKIE_AUTH_KEY", purposeCookieAuth, cookieAuthKeyLength, master, previousMasters); err != nil {
+		return nil, err
+	}
+	if keys.CookieEncryption, err = load("COOKIE_ENCRYPTION_KEY", purposeCookieEncryption, cookieEncryptKeyLength, master, previousMasters); err != nil {
+		return nil, err
+	}
+	if keys.Data, err = load("DATA_KEY", purposeData, dataKeyLength, master, previousMasters); err != nil {
+		return nil, err
+	}
+
+	// Identities used to be encrypted with SECRET_KEY itself, keep reading them until they are re-encrypted
+	if master != nil {
+		keys.Data = append(keys.Data, master)
+	}
+	keys.Data = append(keys.Data, previousMasters...)
+
+	for _, list := range [][][]byte{keys.CookieEncryption, keys.Data} {
+		for _, key := range list {
+			if !validAESKey(key) {
+				return nil, fmt.Errorf("encryption keys must be 16, 24 or 32 bytes, got %d", len(key))
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// CookieKeyPairs returns the authentication and encryption key pairs for a gorilla sessions store, current pair first.
+// Every authentication key is paired with every encryption key so either can be rotated on its own.
+func (k *Keys) CookieKeyPairs() [][]byte {
+	var pairs [][]byte
+	seen := make(map[string]bool)
+	for _, authKey := range k.CookieAuth {
+		for _, encryptionKey := range k.CookieEncryption {
+			pair := string(authKey) + "\x00" + string(encryptionKey)
+			if seen[pair] {
+				continue
+			}
+			seen[pair] = true
+			pairs = append(pairs, authKey, encryptionKey)
+		}
+	}
+
+	// Cookies used to be signed with the SECRET_KEY string and not encrypted, accept them so users stay logged in
+	if k.legacyCookieSecret != nil {
+		pairs = append(pairs, k.legacyCookieSecret, nil)
+	}
+
+	return pairs
+}
+
+// load returns the key configured in name, or one derived from master, followed by any previous keys
+func load(name string, purpose string, length int, master []byte, previousMasters [][]byte) ([][]byte, error) {
+	var keys [][]byte
+
+	if value := os.Getenv(name); value != "" {
+		key, err := decode(name, value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	} else if master != nil {
+		keys = append(keys, Derive(master, purpose, length))
+	} else {
+		return nil, fmt.Errorf("%s or SECRET_KEY must be set", name)
+	}
+
+	for _, value := range splitList(os.Getenv(name + "_PREVIOUS")) {
+		key, err := decode(name+"_PREVIOUS", value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	// A key derived from the current SECRET_KEY stays valid after an explicit key is configured
+	if os.Getenv(name) != "" && master != nil {
+		keys = append(keys, Derive(master, purpose, length))
+	}
+	for _, previous := range previousMasters {
+		keys = append(keys, Derive(previous, purpose, length))
+	}
+
+	return keys, nil
+}
+
+// Derive derives a key of the given length for a purpose from a master secret using HKDF-SHA256 (RFC 5869)
+func Derive(master []byte, purpose string, length int) []byte {
+	// Extract, with no salt
+	extractor := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extractor.Write(master)
+	prk := extractor.Sum(nil)
+
+	// Expand
+	var okm, block []byte
+	for counter := byte(1); len(okm) < length; counter++ {
+		expander := hmac.New(sha256.New, prk)
+		expander.Write(block)
+		expander.Write([]byte(purpose))
+		expander.Write([]byte{counter})
+		block = expander.Sum(nil)
+		okm = append(okm, block...)
+	}
+
+	return okm[:length]
+}
+
+func decode(name string, value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", name, err)
+	}
+	return key, nil
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func validAESKey(key []byte) bool {
+	switch len(key) {
+	case 16, 24, 32:
+		return true
+	}
+	return false
+}
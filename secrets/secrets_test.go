@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+func testKey(fill byte, length int) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, length))
+}
+
+func cookieCodecs(t *testing.T, auth, authPrevious, encryption, encryptionPrevious string) []securecookie.Codec {
+	t.Helper()
+
+	t.Setenv("SECRET_KEY", "")
+	t.Setenv("SECRET_KEY_PREVIOUS", "")
+	t.Setenv("DATA_KEY", testKey('d', dataKeyLength))
+	t.Setenv("COOKIE_AUTH_KEY", auth)
+	t.Setenv("COOKIE_AUTH_KEY_PREVIOUS", authPrevious)
+	t.Setenv("COOKIE_ENCRYPTION_KEY", encryption)
+	t.Setenv("COOKIE_ENCRYPTION_KEY_PREVIOUS", encryptionPrevious)
+
+	keys, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	return securecookie.CodecsFromPairs(keys.CookieKeyPairs()...)
+}
+
+// TestCookieKeyRotation checks cookies issued under the old keys still decode after rotating only one of them
+func TestCookieKeyRotation(t *testing.T) {
+	oldAuth, newAuth := testKey('a', cookieAuthKeyLength), testKey('A', cookieAuthKeyLength)
+	oldEncryption, newEncryption := testKey('e', cookieEncryptKeyLength), testKey('E', cookieEncryptKeyLength)
+
+	encoded, err := securecookie.EncodeMulti("session", "value", cookieCodecs(t, oldAuth, "", oldEncryption, "")...)
+	if err != nil {
+		t.Fatalf("EncodeMulti: %v", err)
+	}
+
+	tests := []struct {
+		name                                               string
+		auth, authPrevious, encryption, encryptionPrevious string
+	}{
+		{"auth key rotated", newAuth, oldAuth, oldEncryption, ""},
+		{"encryption key rotated", oldAuth, "", newEncryption, oldEncryption},
+		{"both keys rotated", newAuth, oldAuth, newEncryption, oldEncryption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codecs := cookieCodecs(t, tt.auth, tt.authPrevious, tt.encryption, tt.encryptionPrevious)
+
+			var decoded string
+			if err := securecookie.DecodeMulti("session", encoded, &decoded, codecs...); err != nil {
+				t.Fatalf("DecodeMulti: %v", err)
+			}
+			if decoded != "value" {
+				t.Errorf("decoded %q, want %q", decoded, "value")
+			}
+		})
+	}
+}
+
+// TestCookieKeyPairsCurrentFirst checks new cookies are issued under the current authentication and encryption keys
+func TestCookieKeyPairsCurrentFirst(t *testing.T) {
+	t.Setenv("SECRET_KEY", "")
+	t.Setenv("SECRET_KEY_PREVIOUS", "")
+	t.Setenv("DATA_KEY", testKey('d', dataKeyLength))
+	t.Setenv("COOKIE_AUTH_KEY", testKey('A', cookieAuthKeyLength))
+	t.Setenv("COOKIE_AUTH_KEY_PREVIOUS", testKey('a', cookieAuthKeyLength))
+	t.Setenv("COOKIE_ENCRYPTION_KEY", testKey('E', cookieEncryptKeyLength))
+	t.Setenv("COOKIE_ENCRYPTION_KEY_PREVIOUS", testKey('e', cookieEncryptKeyLength))
+
+	keys, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+
+	pairs := keys.CookieKeyPairs()
+	if len(pairs) != 8 {
+		t.Fatalf("got %d keys, want 4 pairs", len(pairs))
+	}
+	if !bytes.Equal(pairs[0], keys.CookieAuth[0]) || !bytes.Equal(pairs[1], keys.CookieEncryption[0]) {
+		t.Errorf("first pair is not the current authentication and encryption keys")
+	}
+}
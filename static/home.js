@@ -3,6 +3,7 @@
 // Initialize state variables
 let activeRequests = 0; // Counter for active requests
 let isShowingUntrusted = false; // Default view
+const changeHighlightDays = 7; // How long rows stay highlighted after an affiliation change
 
 // Assume these lists are declared and initialized globally elsewhere
 // If not, uncomment the following lines to initialize them
@@ -10,6 +11,8 @@ let isShowingUntrusted = false; // Default view
 // let TrustedCorporations = TrustedCorporations || [];
 // let UntrustedCharacters = UntrustedCharacters || [];
 // let UntrustedCorporations = UntrustedCorporations || [];
+// let TrustedAlliances = TrustedAlliances || [];
+// let UntrustedAlliances = UntrustedAlliances || [];
 
 // Initialize table variables grouped under a single object
 const tables = {};
@@ -109,7 +112,7 @@ function toggleMultipleSections(sections, show) {
 /**
  * Helper function to determine the correct server endpoint
  * @param {string} trustStatus - 'trusted' or 'untrusted'
- * @param {string} entityType - 'character' or 'corporation'
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'
  * @param {string} action - 'add' or 'remove'
  * @returns {string|null} - The server endpoint URL or null if invalid inputs
  */
@@ -123,6 +126,10 @@ function getServerEndpoint(trustStatus, entityType, action) {
             corporation: {
                 add: '/validate-and-add-trusted-corporation',
                 remove: '/remove-trusted-corporation',
+            },
+            alliance: {
+                add: '/validate-and-add-trusted-alliance',
+                remove: '/remove-trusted-alliance',
             }
         },
         untrusted: {
@@ -133,6 +140,10 @@ function getServerEndpoint(trustStatus, entityType, action) {
             corporation: {
                 add: '/validate-and-add-untrusted-corporation',
                 remove: '/remove-untrusted-corporation',
+            },
+            alliance: {
+                add: '/validate-and-add-untrusted-alliance',
+                remove: '/remove-untrusted-alliance',
             }
         }
     };
@@ -152,6 +163,10 @@ async function fetchWithHandling(url, options) {
         const response = await fetch(url, options);
         const contentType = response.headers.get("Content-Type");
 
+        if (response.status === 409) {
+            showRevisionConflict();
+        }
+
         if (!response.ok) {
             let errorData;
             if (contentType && contentType.includes("application/json")) {
@@ -240,6 +255,9 @@ async function writeContacts(characterID) {
         const deleteData = await response.json();
         console.log("Contacts deleted successfully:", deleteData);
         toastr.success("Contacts updated successfully.");
+        if (deleteData.skipped > 0) {
+            toastr.info(`${deleteData.skipped} untrusted contacts were left alone because you added them yourself.`);
+        }
     } catch (error) {
         toastr.error("Error writing contacts: " + error.message);
         console.error("Error writing contacts:", error);
@@ -251,11 +269,47 @@ async function writeContacts(characterID) {
 
 
 
+/**
+ * Returns the ID and name fields of the rows of an entity type
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
+ * @returns {{idField: string, nameField: string}} - The field names, e.g. CharacterID and CharacterName.
+ */
+function entityFields(entityType) {
+    const prefix = capitalize(entityType);
+    return { idField: `${prefix}ID`, nameField: `${prefix}Name` };
+}
+
+/**
+ * Returns the local list holding the entries of a status and type
+ * @param {string} trustStatus - 'trusted' or 'untrusted'.
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
+ * @returns {Array|undefined} - The list, or undefined for an unknown status or type.
+ */
+function entityList(trustStatus, entityType) {
+    return {
+        'trusted-character': TrustedCharacters,
+        'trusted-corporation': TrustedCorporations,
+        'trusted-alliance': TrustedAlliances,
+        'untrusted-character': UntrustedCharacters,
+        'untrusted-corporation': UntrustedCorporations,
+        'untrusted-alliance': UntrustedAlliances,
+    }[`${trustStatus}-${entityType}`];
+}
+
+/**
+ * Returns the entity type of the entries a table shows
+ * @param {string} tableId - The ID of the table, e.g. trusted-alliances-table.
+ * @returns {string} - 'character', 'corporation' or 'alliance'.
+ */
+function tableEntityType(tableId) {
+    return tableId.split('-')[1].slice(0, -1);
+}
+
 /**
  * Checks if an entity is in a given list by Identifier (ID or Name).
  * @param {Array} list - The list to check.
  * @param {string|number} identifier - The ID or Name of the entity.
- * @param {string} entityType - 'character' or 'corporation'.
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
  * @returns {boolean} - True if the entity is in the list.
  */
 function isEntityInListByIdentifier(list, identifier, entityType) {
@@ -265,8 +319,7 @@ function isEntityInListByIdentifier(list, identifier, entityType) {
     }
 
     const isId = typeof identifier === 'number' || /^\d+$/.test(identifier);
-    const idField = entityType === 'character' ? 'CharacterID' : 'CorporationID';
-    const nameField = entityType === 'character' ? 'CharacterName' : 'CorporationName';
+    const { idField, nameField } = entityFields(entityType);
 
     if (isId) {
         const numericId = typeof identifier === 'number' ? identifier : parseInt(identifier, 10);
@@ -297,22 +350,48 @@ const isCorporationInTrustedList = (identifier) => isEntityInListByIdentifier(Tr
 const isCharacterInUntrustedList = (identifier) => isEntityInListByIdentifier(UntrustedCharacters, identifier, 'character');
 const isCorporationInUntrustedList = (identifier) => isEntityInListByIdentifier(UntrustedCorporations, identifier, 'corporation');
 
+/**
+ * Checks whether an entity is on the opposite list to the one it is being added to
+ * @param {string} trustStatus - The list it is being added to, 'trusted' or 'untrusted'.
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
+ * @param {string|number} identifier - The ID or Name of the entity.
+ * @returns {boolean} - True if the entity is on the opposite list.
+ */
+function isInOppositeList(trustStatus, entityType, identifier) {
+    const oppositeTrustStatus = trustStatus === 'trusted' ? 'untrusted' : 'trusted';
+    return isEntityInListByIdentifier(entityList(oppositeTrustStatus, entityType) || [], identifier, entityType);
+}
+
 /** Helper functions for ID-based checks */
 const isCharacterInTrustedListByID = (id) => isEntityInListByIdentifier(TrustedCharacters, id, 'character');
 const isCorporationInTrustedListByID = (id) => isEntityInListByIdentifier(TrustedCorporations, id, 'corporation');
+const isAllianceInTrustedListByID = (id) => isEntityInListByIdentifier(TrustedAlliances, id, 'alliance');
+const isAllianceInUntrustedListByID = (id) => isEntityInListByIdentifier(UntrustedAlliances, id, 'alliance');
 
 /**
- * Checks if a character is trusted based on character and corporation trust lists.
- * @param {object} character - The character object.
- * @returns {boolean} - True if trusted, otherwise false.
- */
-/**
- * Checks if a character is trusted based on character and corporation trust lists.
+ * Checks if a character is trusted based on character, corporation and alliance trust lists. A character
+ * entry beats a corporation entry, which beats an alliance entry, and an untrusted entry beats a trusted
+ * one, as on the server.
  * @param {object} character - The character object.
  * @returns {boolean} - True if trusted, otherwise false.
  */
 function isCharacterTrusted(character) {
-    return isCharacterInTrustedListByID(character.CharacterID) || isCorporationInTrustedListByID(character.CorporationID);
+    if (isCharacterInUntrustedList(character.CharacterID)) {
+        return false;
+    }
+    if (isCharacterInTrustedListByID(character.CharacterID)) {
+        return true;
+    }
+    if (isCorporationInUntrustedList(character.CorporationID)) {
+        return false;
+    }
+    if (isCorporationInTrustedListByID(character.CorporationID)) {
+        return true;
+    }
+    if (!character.AllianceID || isAllianceInUntrustedListByID(character.AllianceID)) {
+        return false;
+    }
+    return isAllianceInTrustedListByID(character.AllianceID);
 }
 
 
@@ -399,12 +478,143 @@ function initializeCharacterTile(character) {
         writeContacts(character.CharacterID);
     });
 
+    const subscribeButton = document.createElement("button");
+    subscribeButton.className = "subscribe-btn";
+    subscribeButton.setAttribute("aria-label", "Sync This List");
+    updateSubscribeButton(subscribeButton, character.Subscribed, character.SubscribedTags);
+
+    subscribeButton.addEventListener("click", (e) => {
+        e.stopPropagation();
+        toggleSubscription(character, subscribeButton);
+    });
+
     tile.appendChild(img);
     tile.appendChild(name);
     tile.appendChild(button);
+    tile.appendChild(subscribeButton);
     return tile;
 }
 
+/**
+ * Shows whether a character syncs its contacts from the current list
+ * @param {HTMLElement} button - The subscribe button on the character's tile
+ * @param {boolean} subscribed - Whether the character is subscribed
+ * @param {Array} tags - The tags the subscription is limited to, if any
+ */
+function updateSubscribeButton(button, subscribed, tags) {
+    let label = subscribed ? `Syncing ${CurrentList}` : `Not syncing ${CurrentList}`;
+    if (subscribed && tags && tags.length > 0) {
+        label += ` tagged ${tags.join(', ')}`;
+    }
+    button.title = label;
+    button.setAttribute("data-tooltip", label);
+    button.classList.toggle("subscribed", subscribed);
+    button.innerHTML = subscribed
+        ? '<i class="fas fa-link" aria-hidden="true"></i>'
+        : '<i class="fas fa-unlink" aria-hidden="true"></i>';
+}
+
+/**
+ * Subscribes or unsubscribes a character from the current list for contact syncing
+ * @param {object} character - The character data object
+ * @param {HTMLElement} button - The subscribe button on the character's tile
+ */
+async function toggleSubscription(character, button) {
+    const subscribed = !character.Subscribed;
+
+    let tags = [];
+    if (subscribed) {
+        const result = await Swal.fire({
+            title: `Sync ${CurrentList}`,
+            input: 'text',
+            inputLabel: 'Only sync trusted entries with any of these tags',
+            inputPlaceholder: 'comma separated, leave empty to sync everything',
+            showCancelButton: true,
+            confirmButtonText: 'Sync'
+        });
+        if (!result.isConfirmed) {
+            return;
+        }
+        tags = splitTags(result.value);
+    }
+
+    showLoading();
+    try {
+        await fetchWithHandling('/subscriptions', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ characterID: character.CharacterID, list: CurrentList, subscribed, tags })
+        });
+        character.Subscribed = subscribed;
+        character.SubscribedTags = tags;
+        updateSubscribeButton(button, subscribed, tags);
+        toastr.success(subscribed
+            ? `${character.CharacterName} now syncs ${CurrentList}.`
+            : `${character.CharacterName} no longer syncs ${CurrentList}.`);
+    } catch (error) {
+        toastr.error("Failed to change subscription. " + error.message);
+    } finally {
+        hideLoading();
+    }
+}
+
+/**
+ * Switches the page to another trust list
+ * @param {string} name - Name of the list to show
+ */
+function switchList(name) {
+    window.location.href = name === 'default' ? '/' : `/?list=${encodeURIComponent(name)}`;
+}
+
+/**
+ * Asks for the details of a new list, creates it and switches to it
+ */
+async function createList() {
+    const result = await Swal.fire({
+        title: 'New list',
+        html: `
+            <input id="new-list-name" class="swal2-input" placeholder="name, e.g. fleet-blues" maxlength="32">
+            <input id="new-list-description" class="swal2-input" placeholder="Description">
+            <select id="new-list-access" class="swal2-select">
+                <option value="public">Public - everyone can see and sync it</option>
+                <option value="restricted">Restricted - only people you add</option>
+            </select>`,
+        showCancelButton: true,
+        confirmButtonText: 'Create',
+        focusConfirm: false,
+        preConfirm: () => {
+            const name = document.getElementById('new-list-name').value.trim().toLowerCase();
+            if (!/^[a-z0-9][a-z0-9-]{0,31}$/.test(name)) {
+                Swal.showValidationMessage('Use up to 32 lowercase letters, digits or dashes');
+                return false;
+            }
+            return {
+                name,
+                description: document.getElementById('new-list-description').value,
+                access: document.getElementById('new-list-access').value
+            };
+        }
+    });
+
+    if (!result.isConfirmed) {
+        return;
+    }
+
+    showLoading();
+    try {
+        const list = await fetchWithHandling('/lists', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(result.value)
+        });
+        switchList(list.name);
+    } catch (error) {
+        toastr.error("Failed to create list. " + error.message);
+    } finally {
+        hideLoading();
+    }
+}
+
 /**
  * Initializes all character tiles
  */
@@ -427,7 +637,7 @@ function initializeCharacterTiles() {
         // Click event to add untrusted characters to the trusted list
         tile.addEventListener("click", () => {
             console.log(`Tile clicked for CharacterID: ${character.CharacterID}, Class: ${tile.className}`);
-            if (tile.classList.contains('untrusted') && activeRequests === 0) {
+            if (CanEdit && tile.classList.contains('untrusted') && activeRequests === 0) {
                 console.log("Adding to trusted list...");
                 addEntity('trusted', 'character', character.CharacterID.toString()); // Convert to string
             } else {
@@ -440,8 +650,8 @@ function initializeCharacterTiles() {
 /**
  * Adds an entity based on trustStatus and entityType using a single identifier.
  * @param {string} trustStatus - 'trusted' or 'untrusted'.
- * @param {string} entityType - 'character' or 'corporation'.
- * @param {string|number} identifier - Character/Corporation ID or Name.
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
+ * @param {string|number} identifier - Character/Corporation/Alliance ID or Name.
  */
 function addEntity(trustStatus, entityType, identifier) {
     console.log("Adding entity:", trustStatus, entityType, identifier);
@@ -459,19 +669,17 @@ function addEntity(trustStatus, entityType, identifier) {
     const oppositeTrustStatus = trustStatus === 'trusted' ? 'untrusted' : 'trusted';
 
     // Check if the entity is already in the opposite list
-    const isInOppositeList = trustStatus === 'trusted'
-        ? (entityType === 'character' ? isCharacterInUntrustedList(identifier) : isCorporationInUntrustedList(identifier))
-        : (entityType === 'character' ? isCharacterInTrustedList(identifier) : isCorporationInTrustedList(identifier));
-
-    if (isInOppositeList) {
+    if (isInOppositeList(trustStatus, entityType, identifier)) {
         toastr.warning(`${capitalize(entityType)} already exists in the ${oppositeTrustStatus} list.`);
         console.warn(`${capitalize(entityType)} with identifier ${identifierStr} is already in ${oppositeTrustStatus} list.`);
         return; // Prevent adding to the current list
     }
 
-    // Prepare payload with a single identifier field
+    // Prepare payload with a single identifier field, revision 0 expects the entity not to be listed yet
     const payload = {
-        identifier: identifierStr
+        identifier: identifierStr,
+        revision: 0,
+        list: CurrentList
     };
 
     showLoading();
@@ -499,6 +707,11 @@ function addEntity(trustStatus, entityType, identifier) {
                 TabulatorIdentities
                     .filter(char => char.CorporationID === data.CorporationID)
                     .forEach(char => recomputeAndUpdateTileTrustStatus(char.CharacterID));
+            } else if (entityType === 'alliance') {
+                // Update all characters belonging to this alliance
+                TabulatorIdentities
+                    .filter(char => char.AllianceID === data.AllianceID)
+                    .forEach(char => recomputeAndUpdateTileTrustStatus(char.CharacterID));
             }
 
         })
@@ -516,26 +729,18 @@ function addEntity(trustStatus, entityType, identifier) {
 /**
  * Updates local data by adding the new entity to the appropriate list
  * @param {string} trustStatus - 'trusted' or 'untrusted'
- * @param {string} entityType - 'character' or 'corporation'
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'
  * @param {object} data - The data object of the entity to add
  */
 function additionUpdateLocalData(trustStatus, entityType, data) {
-    let targetList;
-    if (trustStatus === 'trusted' && entityType === 'character') {
-        targetList = TrustedCharacters;
-    } else if (trustStatus === 'trusted' && entityType === 'corporation') {
-        targetList = TrustedCorporations;
-    } else if (trustStatus === 'untrusted' && entityType === 'character') {
-        targetList = UntrustedCharacters;
-    } else if (trustStatus === 'untrusted' && entityType === 'corporation') {
-        targetList = UntrustedCorporations;
-    } else {
+    const targetList = entityList(trustStatus, entityType);
+    if (!targetList) {
         console.warn(`Unknown trustStatus (${trustStatus}) or entityType (${entityType})`);
         return;
     }
 
     // Check for duplicates
-    const idField = entityType === 'character' ? 'CharacterID' : 'CorporationID';
+    const { idField } = entityFields(entityType);
     const exists = targetList.some(entity => entity[idField] === data[idField]);
 
     if (!exists) {
@@ -555,13 +760,13 @@ function additionUpdateLocalData(trustStatus, entityType, data) {
 function addRowToTable(tableId, data) {
     const targetTable = tables[tableId];
     if (targetTable) {
-        const rowID = data.CharacterID || data.CorporationID;
+        const rowID = data[entityFields(tableEntityType(tableId)).idField];
         const existingRow = targetTable.getRow(rowID);
 
         if (!existingRow) {
             targetTable.addRow(data)
                 .then(() => {
-                    const entityType = tableId.includes('character') ? 'Character' : 'Corporation';
+                    const entityType = capitalize(tableEntityType(tableId));
                     const trustStatus = tableId.split('-')[0];
                     toastr.success(`Added ${data[`${entityType}Name`]} to ${capitalize(trustStatus)} list.`);
 
@@ -584,12 +789,12 @@ function addRowToTable(tableId, data) {
                 })
                 .catch(error => {
                     console.error(`Error adding row to ${tableId}:`, error);
-                    const entityType = tableId.includes('character') ? 'Character' : 'Corporation';
+                    const entityType = capitalize(tableEntityType(tableId));
                     const trustStatus = tableId.split('-')[0];
                     toastr.error(`Error updating ${capitalize(trustStatus)} ${entityType} table.`);
                 });
         } else {
-            const entityType = tableId.includes('character') ? 'Character' : 'Corporation';
+            const entityType = capitalize(tableEntityType(tableId));
             const trustStatus = tableId.split('-')[0];
             toastr.warning(`${entityType} already exists in the ${capitalize(trustStatus)} list.`);
         }
@@ -600,7 +805,7 @@ function addRowToTable(tableId, data) {
  * Handle form submission by extracting the identifier and performing add/remove operations.
  * @param {Event} e - The submit event.
  * @param {string} trustStatus - 'trusted' or 'untrusted'.
- * @param {string} entityType - 'character' or 'corporation'.
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
  */
 function handleFormSubmission(e, trustStatus, entityType) {
     e.preventDefault();
@@ -608,8 +813,10 @@ function handleFormSubmission(e, trustStatus, entityType) {
     const inputIdMap = {
         'trusted-character': 'trusted-character-identifier',
         'trusted-corporation': 'trusted-corporation-identifier',
+        'trusted-alliance': 'trusted-alliance-identifier',
         'untrusted-character': 'untrusted-character-identifier',
         'untrusted-corporation': 'untrusted-corporation-identifier',
+        'untrusted-alliance': 'untrusted-alliance-identifier',
     };
 
     const inputId = inputIdMap[`${trustStatus}-${entityType}`];
@@ -636,11 +843,7 @@ function handleFormSubmission(e, trustStatus, entityType) {
     }
 
     // Prevent adding an entity that already exists in the opposite list
-    const isInOppositeList = trustStatus === 'trusted'
-        ? (entityType === 'character' ? isCharacterInUntrustedList(identifier) : isCorporationInUntrustedList(identifier))
-        : (entityType === 'character' ? isCharacterInTrustedList(identifier) : isCorporationInTrustedList(identifier));
-
-    if (isInOppositeList) {
+    if (isInOppositeList(trustStatus, entityType, identifier)) {
         const statusMessage = trustStatus === 'trusted' ? 'untrusted' : 'trusted';
         toastr.warning(`${identifier} is already in the ${statusMessage} list.`);
         return;
@@ -660,6 +863,8 @@ function setupFormEventListeners() {
         { id: "add-untrusted-character-form", trustStatus: 'untrusted', entityType: 'character' },
         { id: "add-trusted-corporation-form", trustStatus: 'trusted', entityType: 'corporation' },
         { id: "add-untrusted-corporation-form", trustStatus: 'untrusted', entityType: 'corporation' },
+        { id: "add-trusted-alliance-form", trustStatus: 'trusted', entityType: 'alliance' },
+        { id: "add-untrusted-alliance-form", trustStatus: 'untrusted', entityType: 'alliance' },
     ];
 
     forms.forEach(({ id, trustStatus, entityType }) => {
@@ -673,6 +878,70 @@ function setupFormEventListeners() {
 }
 
 
+/**
+ * Formats the contact standing of an entry, which is +5 unless set through the API
+ * @param {Object} cell - The Tabulator cell
+ * @returns {string} The standing with its sign
+ */
+function formatStanding(cell) {
+    const standing = cell.getValue() ?? 5;
+    return standing > 0 ? `+${standing}` : `${standing}`;
+}
+
+/**
+ * Formats when an entry expires, or nothing for entries that do not expire
+ * @param {Object} cell - The Tabulator cell
+ * @returns {string} The expiry date and time
+ */
+function formatExpiry(cell) {
+    const expiresAt = cell.getValue();
+    return expiresAt ? new Date(expiresAt).toLocaleString() : '';
+}
+
+/**
+ * Formats the tags of an entry as labels
+ * @param {Object} cell - The Tabulator cell
+ * @returns {string} The tags as HTML
+ */
+function formatTags(cell) {
+    return (cell.getValue() || []).map(tag => `<span class="entry-tag">${escapeHTML(tag)}</span>`).join(' ');
+}
+
+/**
+ * Header filter matching entries with a tag that contains the filter text
+ * @param {string} headerValue - The text typed into the header filter
+ * @param {Array} rowValue - The tags of the entry
+ * @returns {boolean} Whether the entry is shown
+ */
+function filterTags(headerValue, rowValue) {
+    const wanted = headerValue.trim().toLowerCase();
+    return !wanted || (rowValue || []).some(tag => tag.includes(wanted));
+}
+
+/**
+ * Splits comma separated tags
+ * @param {string} text - Tags as typed by the user
+ * @returns {Array} The tags, lowercase and without blanks
+ */
+function splitTags(text) {
+    return String(text || '').split(',').map(tag => tag.trim().toLowerCase()).filter(tag => tag !== '');
+}
+
+/**
+ * Column showing the tags of an entry, filterable from its header and editable as comma separated text
+ */
+const tagsColumn = {
+    title: "Tags",
+    field: "Tags",
+    headerSort: false,
+    formatter: formatTags,
+    headerFilter: "input",
+    headerFilterPlaceholder: "Filter tags",
+    headerFilterFunc: filterTags,
+    editor: "input",
+    editable: () => CanEdit
+};
+
 /**
  * Initializes all Tabulator tables
  */
@@ -687,6 +956,9 @@ function initializeAllTabulatorTables() {
                 { title: "Character Name", field: "CharacterName", headerSort: true },
                 { title: "Added By", field: "AddedBy", headerSort: true },
                 { title: "Corporation", field: "CorporationName", headerSort: true },
+                { title: "Standing", field: "Standing", headerSort: true, formatter: formatStanding },
+                { title: "Expires", field: "ExpiresAt", headerSort: true, formatter: formatExpiry },
+                tagsColumn,
                 {
                     title: "Comment",
                     field: "Comment",
@@ -717,7 +989,7 @@ function initializeAllTabulatorTables() {
                             cancelButtonText: 'No'
                         }).then((result) => {
                             if (result.isConfirmed) {
-                                removeEntity('trusted', 'character', characterID.toString()); // Convert to string
+                                removeEntity('trusted', 'character', characterID.toString(), rowData.Revision); // Convert to string
                             }
                         });
                     }
@@ -732,6 +1004,9 @@ function initializeAllTabulatorTables() {
                 { title: "Corporation Name", field: "CorporationName", headerSort: true },
                 { title: "Added By", field: "AddedBy", headerSort: true },
                 { title: "Alliance Name", field: "AllianceName", headerSort: true },
+                { title: "Standing", field: "Standing", headerSort: true, formatter: formatStanding },
+                { title: "Expires", field: "ExpiresAt", headerSort: true, formatter: formatExpiry },
+                tagsColumn,
                 {
                     title: "Comment",
                     field: "Comment",
@@ -762,7 +1037,54 @@ function initializeAllTabulatorTables() {
                             cancelButtonText: 'No'
                         }).then((result) => {
                             if (result.isConfirmed) {
-                                removeEntity('trusted', 'corporation', corporationID.toString()); // Convert to string
+                                removeEntity('trusted', 'corporation', corporationID.toString(), rowData.Revision); // Convert to string
+                            }
+                        });
+                    },
+                }
+            ]
+        },
+        {
+            tableId: "trusted-alliances-table",
+            indexField: "AllianceID",
+            data: TrustedAlliances,
+            columns: [
+                { title: "Alliance Name", field: "AllianceName", headerSort: true },
+                { title: "Added By", field: "AddedBy", headerSort: true },
+                { title: "Standing", field: "Standing", headerSort: true, formatter: formatStanding },
+                { title: "Expires", field: "ExpiresAt", headerSort: true, formatter: formatExpiry },
+                tagsColumn,
+                {
+                    title: "Comment",
+                    field: "Comment",
+                    editor: "input", // Makes the cell editable
+                    editable: true // Ensures it's editable by the user
+                },
+                {
+                    title: "Remove",
+                    formatter: "buttonCross",
+                    width: 10,
+                    hozAlign: "center",
+                    headerSort: false,
+                    cellClick: function (e, cell) {
+                        const rowData = cell.getRow().getData();
+                        const allianceID = rowData.AllianceID;
+                        const allianceName = rowData.AllianceName;
+                        console.log(`Removing trusted alliance with ID: ${allianceID}, Name: ${allianceName}`);
+
+                        // Use SweetAlert2 for Confirmation
+                        Swal.fire({
+                            title: `Remove Alliance?`,
+                            text: `Do you want to stop trusting "${allianceName}"?`,
+                            icon: 'warning',
+                            showCancelButton: true,
+                            confirmButtonColor: '#d33',
+                            cancelButtonColor: '#3085d6',
+                            confirmButtonText: 'Yes',
+                            cancelButtonText: 'No'
+                        }).then((result) => {
+                            if (result.isConfirmed) {
+                                removeEntity('trusted', 'alliance', allianceID.toString(), rowData.Revision); // Convert to string
                             }
                         });
                     },
@@ -777,6 +1099,8 @@ function initializeAllTabulatorTables() {
                 { title: "Character Name", field: "CharacterName", headerSort: true },
                 { title: "Added By", field: "AddedBy", headerSort: true },
                 { title: "Corporation", field: "CorporationName", headerSort: true },
+                { title: "Expires", field: "ExpiresAt", headerSort: true, formatter: formatExpiry },
+                tagsColumn,
                 {
                     title: "Comment",
                     field: "Comment",
@@ -807,7 +1131,7 @@ function initializeAllTabulatorTables() {
                             cancelButtonText: 'No'
                         }).then((result) => {
                             if (result.isConfirmed) {
-                                removeEntity('untrusted', 'character', characterID.toString()); // Convert to string
+                                removeEntity('untrusted', 'character', characterID.toString(), rowData.Revision); // Convert to string
                             }
                         });
                     }
@@ -822,6 +1146,8 @@ function initializeAllTabulatorTables() {
                 { title: "Corporation Name", field: "CorporationName", headerSort: true },
                 { title: "Added By", field: "AddedBy", headerSort: true },
                 { title: "Alliance Name", field: "AllianceName", headerSort: true },
+                { title: "Expires", field: "ExpiresAt", headerSort: true, formatter: formatExpiry },
+                tagsColumn,
                 {
                     title: "Comment",
                     field: "Comment",
@@ -852,7 +1178,53 @@ function initializeAllTabulatorTables() {
                             cancelButtonText: 'No'
                         }).then((result) => {
                             if (result.isConfirmed) {
-                                removeEntity('untrusted', 'corporation', corporationID.toString()); // Convert to string
+                                removeEntity('untrusted', 'corporation', corporationID.toString(), rowData.Revision); // Convert to string
+                            }
+                        });
+                    },
+                }
+            ]
+        },
+        {
+            tableId: "untrusted-alliances-table",
+            indexField: "AllianceID",
+            data: UntrustedAlliances,
+            columns: [
+                { title: "Alliance Name", field: "AllianceName", headerSort: true },
+                { title: "Added By", field: "AddedBy", headerSort: true },
+                { title: "Expires", field: "ExpiresAt", headerSort: true, formatter: formatExpiry },
+                tagsColumn,
+                {
+                    title: "Comment",
+                    field: "Comment",
+                    editor: "input", // Makes the cell editable
+                    editable: true // Ensures it's editable by the user
+                },
+                {
+                    title: "Remove",
+                    formatter: "buttonCross",
+                    width: 10,
+                    hozAlign: "center",
+                    headerSort: false,
+                    cellClick: function (e, cell) {
+                        const rowData = cell.getRow().getData();
+                        const allianceID = rowData.AllianceID;
+                        const allianceName = rowData.AllianceName;
+                        console.log(`Removing untrusted alliance with ID: ${allianceID}, Name: ${allianceName}`);
+
+                        // Use SweetAlert2 for Confirmation
+                        Swal.fire({
+                            title: `Remove Alliance?`,
+                            text: `Has everyone updated their standings for "${allianceName}"?`,
+                            icon: 'warning',
+                            showCancelButton: true,
+                            confirmButtonColor: '#d33',
+                            cancelButtonColor: '#3085d6',
+                            confirmButtonText: 'Yes',
+                            cancelButtonText: 'No'
+                        }).then((result) => {
+                            if (result.isConfirmed) {
+                                removeEntity('untrusted', 'alliance', allianceID.toString(), rowData.Revision); // Convert to string
                             }
                         });
                     },
@@ -875,8 +1247,10 @@ function showUntrustedTables() {
     const untrustedSections = [
         "untrusted-characters-table",
         "untrusted-corporations-table",
+        "untrusted-alliances-table",
         "add-untrusted-character-section",
-        "add-untrusted-corporation-section"
+        "add-untrusted-corporation-section",
+        "add-untrusted-alliance-section"
     ];
 
     // Apply display:block to make sure they're treated as visible
@@ -893,14 +1267,17 @@ function showUntrustedTables() {
     // Force redraw to ensure tables resize
     tables["untrusted-characters-table"].redraw(true);
     tables["untrusted-corporations-table"].redraw(true);
+    tables["untrusted-alliances-table"].redraw(true);
 }
 
 function hideUntrustedTables() {
     const untrustedSections = [
         "untrusted-characters-table",
         "untrusted-corporations-table",
+        "untrusted-alliances-table",
         "add-untrusted-character-section",
-        "add-untrusted-corporation-section"
+        "add-untrusted-corporation-section",
+        "add-untrusted-alliance-section"
     ];
 
     // Set display:none to fully hide the sections
@@ -920,8 +1297,10 @@ function hideUntrustedTables() {
  * @param {number} id - ID to be commented on
  * @param {string} comment - The updated comment text
  * @param {string} tableId - ID of the table the comment is in
+ * @param {number} revision - Revision of the entry the comment was edited against
+ * @returns {Promise<number|null>} - The new revision of the entry, or null if it was not saved
  */
-async function updateComment(id, comment, tableId) {
+async function updateComment(id, comment, tableId, revision) {
     const url = `/update-comment`; // Replace with your actual endpoint URL
 
     try {
@@ -929,31 +1308,87 @@ async function updateComment(id, comment, tableId) {
         const response = await fetch(url, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ id, comment, tableId })
+            body: JSON.stringify({ id, comment, tableId, revision, list: CurrentList })
         });
 
+        if (response.status === 409) {
+            showRevisionConflict();
+            return null;
+        }
+
         if (!response.ok) {
             const errorData = await response.json();
             throw new Error(errorData.error || "Failed to save comment.");
         }
 
+        const data = await response.json();
         toastr.success("Comment saved successfully.");
+        return data.revision;
     } catch (error) {
         console.error(`Error saving comment: ${error}`);
         toastr.error("Failed to save comment. " + error.message);
+        return null;
+    } finally {
+        hideLoading();
+    }
+}
+
+/**
+ * Saves the tags typed into a table cell and shows them as stored, or restores the old tags if they were not saved.
+ * @param {Object} cell - The edited Tabulator cell
+ * @param {string} tableId - ID of the table the entry is in, e.g. trusted-characters-table
+ */
+async function updateTags(cell, tableId) {
+    const row = cell.getRow();
+    const rowData = row.getData();
+    const trustStatus = tableId.split('-')[0];
+    const entityType = tableEntityType(tableId);
+    const id = rowData[entityFields(entityType).idField];
+
+    showLoading();
+    try {
+        const entry = await fetchWithHandling(`/api/v1/lists/${encodeURIComponent(CurrentList)}/entries/${trustStatus}/${entityType}/${id}`, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ tags: splitTags(cell.getValue()), revision: rowData.Revision || 0 })
+        });
+        row.update({ Tags: entry.tags || [], Revision: entry.revision });
+        toastr.success("Tags saved successfully.");
+    } catch (error) {
+        cell.restoreOldValue();
+        toastr.error("Failed to save tags. " + error.message);
     } finally {
         hideLoading();
     }
 }
 
+/**
+ * Tells the user an entry was changed by someone else since the page loaded and offers to reload.
+ */
+function showRevisionConflict() {
+    Swal.fire({
+        title: 'Changed by someone else',
+        text: 'This entry was changed by someone else since you loaded the page. Reload to see the latest version.',
+        icon: 'warning',
+        showCancelButton: true,
+        confirmButtonText: 'Reload',
+        cancelButtonText: 'Later'
+    }).then((result) => {
+        if (result.isConfirmed) {
+            window.location.reload();
+        }
+    });
+}
+
 
 /**
  * Removes an entity based on trustStatus and entityType using a single identifier.
  * @param {string} trustStatus - 'trusted' or 'untrusted'.
- * @param {string} entityType - 'character' or 'corporation'.
- * @param {string|number} identifier - Character/Corporation ID or Name.
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'.
+ * @param {string|number} identifier - Character/Corporation/Alliance ID or Name.
+ * @param {number} [revision] - Revision of the entry the user saw, the removal fails if it has changed since.
  */
-async function removeEntity(trustStatus, entityType, identifier) {
+async function removeEntity(trustStatus, entityType, identifier, revision) {
     console.log("Removing entity:", trustStatus, entityType, identifier);
 
     const serverEndpoint = getServerEndpoint(trustStatus, entityType, 'remove');
@@ -967,7 +1402,10 @@ async function removeEntity(trustStatus, entityType, identifier) {
     const identifierStr = String(identifier);
 
     // Prepare payload
-    const payload = { identifier: identifierStr };
+    const payload = { identifier: identifierStr, list: CurrentList };
+    if (revision !== undefined) {
+        payload.revision = revision;
+    }
 
     try {
         showLoading();
@@ -977,6 +1415,11 @@ async function removeEntity(trustStatus, entityType, identifier) {
             body: JSON.stringify(payload)
         });
 
+        if (response.status === 409) {
+            showRevisionConflict();
+            return;
+        }
+
         if (!response.ok) {
             const errorData = await response.json();
             throw new Error(errorData.error || "Failed to remove entity.");
@@ -1006,7 +1449,7 @@ async function removeEntity(trustStatus, entityType, identifier) {
 /**
  * Removes entity data from the appropriate local list.
  * @param {string} trustStatus - 'trusted' or 'untrusted'
- * @param {string} entityType - 'character' or 'corporation'
+ * @param {string} entityType - 'character', 'corporation' or 'alliance'
  * @param {string|number} identifier - The identifier of the entity to remove
  */
 function removeUpdateLocalData(trustStatus, entityType, identifier) {
@@ -1021,6 +1464,10 @@ function removeUpdateLocalData(trustStatus, entityType, identifier) {
         UntrustedCharacters = UntrustedCharacters.filter(entity => entity.CharacterID !== identifierNum);
     } else if (trustStatus === 'untrusted' && entityType === 'corporation') {
         UntrustedCorporations = UntrustedCorporations.filter(entity => entity.CorporationID !== identifierNum);
+    } else if (trustStatus === 'trusted' && entityType === 'alliance') {
+        TrustedAlliances = TrustedAlliances.filter(entity => entity.AllianceID !== identifierNum);
+    } else if (trustStatus === 'untrusted' && entityType === 'alliance') {
+        UntrustedAlliances = UntrustedAlliances.filter(entity => entity.AllianceID !== identifierNum);
     } else {
         console.warn(`Unknown trustStatus (${trustStatus}) or entityType (${entityType})`);
         return;
@@ -1039,9 +1486,9 @@ function removeRowFromTable(tableId, identifier) {
     const targetTable = tables[tableId];
     if (targetTable) {
         const numericIdentifier = Number(identifier);
-        const entityType = tableId.includes('character') ? 'Character' : 'Corporation';
+        const entityType = capitalize(tableEntityType(tableId));
         const trustStatus = tableId.split('-')[0];
-        const data = targetTable.getData().find(row => row.CharacterID === numericIdentifier || row.CorporationID === numericIdentifier);
+        const data = targetTable.getData().find(row => row[`${entityType}ID`] === numericIdentifier);
         targetTable.deleteRow(numericIdentifier)
             .then(() => {
                 resizeTabulatorTable(tableId);
@@ -1052,7 +1499,7 @@ function removeRowFromTable(tableId, identifier) {
             })
             .catch(error => {
                 console.error(`Error deleting row from ${tableId}:`, error);
-                const entityType = tableId.includes('character') ? 'Character' : 'Corporation';
+                const entityType = capitalize(tableEntityType(tableId));
                 const trustStatus = tableId.split('-')[0];
                 toastr.error(`Error updating ${data[`${entityType}Name`]} in ${capitalize(trustStatus)} ${entityType} table.`);
             });
@@ -1118,6 +1565,30 @@ function setupMutationObservers(tableIds) {
     });
 }
 
+/**
+ * Highlights rows whose corporation or alliance changed recently
+ * @param {object} row - The Tabulator row component
+ */
+function highlightChangedRow(row) {
+    const data = row.getData();
+    const element = row.getElement();
+    const changedAt = data.ChangedAt ? new Date(data.ChangedAt) : null;
+    const isRecent = changedAt && (Date.now() - changedAt.getTime()) < changeHighlightDays * 24 * 60 * 60 * 1000;
+
+    if (!isRecent) {
+        element.classList.remove("changed-row");
+        element.removeAttribute("title");
+        return;
+    }
+
+    const isCharacter = data.CharacterID !== undefined;
+    const previous = isCharacter ? data.PreviousCorporationName : data.PreviousAllianceName;
+    const current = isCharacter ? data.CorporationName : data.AllianceName;
+
+    element.classList.add("changed-row");
+    element.title = `Moved from ${previous || "none"} to ${current || "none"} on ${changedAt.toLocaleDateString()}`;
+}
+
 /**
  * Initializes a Tabulator table with given parameters
  * @param {string} tableId - The ID of the table container
@@ -1133,6 +1604,7 @@ function initializeTabulatorTable(tableId, indexField, data, columns) {
         responsiveLayout: "hide",
         placeholder: `No ${capitalize(tableId.split('-')[1].slice(0, -1))}s`,
         columns: columns,
+        rowFormatter: highlightChangedRow,
         rowAdded: function () {
             resizeTabulatorTable(tableId);
         },
@@ -1144,25 +1616,469 @@ function initializeTabulatorTable(tableId, indexField, data, columns) {
             resizeTabulatorTable(tableId);
         },
         cellEdited: function (cell) {
+            if (cell.getColumn().getField() === "Tags") {
+                updateTags(cell, tableId);
+            }
             // Ensure this is for the "Comment" field
             if (cell.getColumn().getField() === "Comment") {
                 const rowData = cell.getRow().getData();
                 const updatedComment = cell.getValue();
 
-                // Determine if this is a character or corporation table
-                const isCharacterTable = tableId.includes("character");
-                const entityId = isCharacterTable ? rowData.CharacterID : rowData.CorporationID;
+                // Determine if this is a character, corporation or alliance table
+                const entityType = tableEntityType(tableId);
+                const entityId = rowData[entityFields(entityType).idField];
 
                 // Log for debugging (optional)
-                console.log(`Updating comment for ${isCharacterTable ? "Character" : "Corporation"} ID: ${entityId}, Comment: ${updatedComment}`);
+                console.log(`Updating comment for ${capitalize(entityType)} ID: ${entityId}, Comment: ${updatedComment}`);
                 console.log(`Table ID: ${tableId}`);
-                // Call backend function to update the comment
-                updateComment(entityId, updatedComment, tableId);
+                // Call backend function to update the comment, then track the entry's new revision
+                updateComment(entityId, updatedComment, tableId, rowData.Revision || 0)
+                    .then(revision => {
+                        if (revision !== null) {
+                            cell.getRow().update({ Revision: revision });
+                        }
+                    });
+            }
+        }
+    });
+}
+
+
+/**
+ * Shows the logged in user's active sessions and lets them end any other session.
+ */
+async function showSessions() {
+    try {
+        const sessions = await fetchWithHandling('/sessions', { method: 'GET' });
+
+        const rows = sessions.map(session => `
+            <tr>
+                <td>${escapeHTML(session.user_agent || 'Unknown browser')}${session.current ? ' <strong>(this session)</strong>' : ''}</td>
+                <td>${new Date(session.last_seen).toLocaleString()}</td>
+                <td>${session.current ? '' : `<button class="button end-session-btn" data-session-id="${session.id}">End</button>`}</td>
+            </tr>`).join('');
+
+        Swal.fire({
+            title: 'Active Sessions',
+            html: `<table class="sessions-table"><thead><tr><th>Browser</th><th>Last Seen</th><th></th></tr></thead><tbody>${rows}</tbody></table>`,
+            width: 800,
+            didOpen: (popup) => {
+                popup.querySelectorAll('.end-session-btn').forEach(button => {
+                    button.addEventListener('click', () => endSession(button.dataset.sessionId, button));
+                });
+            }
+        });
+    } catch (error) {
+        toastr.error(`Failed to load sessions. ${error.message}`);
+    }
+}
+
+/**
+ * Ends one of the logged in user's sessions.
+ * @param {string} id - Session ID.
+ * @param {HTMLElement} button - The button that was clicked, its row is removed on success.
+ */
+async function endSession(id, button) {
+    try {
+        await fetchWithHandling('/sessions/revoke', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ id })
+        });
+        button.closest('tr').remove();
+        toastr.success('Session ended.');
+    } catch (error) {
+        toastr.error(`Failed to end session. ${error.message}`);
+    }
+}
+
+/**
+ * Shows the logged in user's API tokens and lets them create and revoke tokens.
+ */
+async function showTokens() {
+    try {
+        const tokens = await fetchWithHandling('/tokens', { method: 'GET' });
+
+        const rows = tokens.map(token => `
+            <tr>
+                <td>${escapeHTML(token.name)}</td>
+                <td>${token.scope}</td>
+                <td>${token.expires_at ? new Date(token.expires_at).toLocaleDateString() : 'Never'}</td>
+                <td>${token.last_used ? new Date(token.last_used).toLocaleString() : 'Never'}</td>
+                <td><button class="button revoke-token-btn" data-token-id="${token.id}">Revoke</button></td>
+            </tr>`).join('');
+
+        const result = await Swal.fire({
+            title: 'API Tokens',
+            html: `<table class="sessions-table"><thead><tr><th>Name</th><th>Scope</th><th>Expires</th><th>Last Used</th><th></th></tr></thead><tbody>${rows}</tbody></table>`,
+            width: 800,
+            showCancelButton: true,
+            confirmButtonText: 'New token',
+            cancelButtonText: 'Close',
+            didOpen: (popup) => {
+                popup.querySelectorAll('.revoke-token-btn').forEach(button => {
+                    button.addEventListener('click', () => revokeToken(button.dataset.tokenId, button));
+                });
+            }
+        });
+
+        if (result.isConfirmed) {
+            await createToken();
+        }
+    } catch (error) {
+        toastr.error(`Failed to load API tokens. ${error.message}`);
+    }
+}
+
+/**
+ * Asks for the details of a new API token, creates it and shows its secret once.
+ */
+async function createToken() {
+    const result = await Swal.fire({
+        title: 'New API token',
+        html: `
+            <input id="new-token-name" class="swal2-input" placeholder="Name, e.g. fleet bot" maxlength="64">
+            <select id="new-token-scope" class="swal2-select">
+                <option value="read">Read only</option>
+                <option value="read-write">Read and write</option>
+            </select>
+            <input id="new-token-expiry" class="swal2-input" type="number" min="0" value="90" placeholder="Days until it expires, 0 for never">`,
+        showCancelButton: true,
+        confirmButtonText: 'Create',
+        focusConfirm: false,
+        preConfirm: () => {
+            const name = document.getElementById('new-token-name').value.trim();
+            const expiresInDays = parseInt(document.getElementById('new-token-expiry').value || '0', 10);
+            if (!name) {
+                Swal.showValidationMessage('Give the token a name');
+                return false;
+            }
+            if (isNaN(expiresInDays) || expiresInDays < 0) {
+                Swal.showValidationMessage('Expiry must be a number of days');
+                return false;
+            }
+            return { name, scope: document.getElementById('new-token-scope').value, expiresInDays };
+        }
+    });
+
+    if (!result.isConfirmed) {
+        return;
+    }
+
+    try {
+        const token = await fetchWithHandling('/tokens', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(result.value)
+        });
+        await Swal.fire({
+            title: 'Token created',
+            html: `<p>Copy this token now, it will not be shown again.</p><input class="swal2-input" readonly value="${escapeHTML(token.token)}" onclick="this.select()">`,
+            icon: 'success'
+        });
+    } catch (error) {
+        toastr.error(`Failed to create API token. ${error.message}`);
+    }
+}
+
+/**
+ * Revokes one of the logged in user's API tokens.
+ * @param {string} id - Token ID.
+ * @param {HTMLElement} button - The button that was clicked, its row is removed on success.
+ */
+async function revokeToken(id, button) {
+    try {
+        await fetchWithHandling('/tokens/revoke', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ id })
+        });
+        button.closest('tr').remove();
+        toastr.success('API token revoked.');
+    } catch (error) {
+        toastr.error(`Failed to revoke API token. ${error.message}`);
+    }
+}
+
+/**
+ * Asks for a member list copied from local or fleet chat and shows every pilot as trusted,
+ * untrusted or unknown against the current list, untrusted pilots first.
+ */
+async function showAnalyzer() {
+    const result = await Swal.fire({
+        title: `Check local against ${escapeHTML(CurrentList)}`,
+        html: `<textarea id="analyze-names" class="swal2-textarea" rows="12" placeholder="Paste the member list from local or fleet chat, one name per line"></textarea>`,
+        width: 700,
+        showCancelButton: true,
+        confirmButtonText: 'Check',
+        focusConfirm: false,
+        preConfirm: () => {
+            const names = document.getElementById('analyze-names').value;
+            if (!names.trim()) {
+                Swal.showValidationMessage('Paste some names to check');
+                return false;
             }
+            return names;
         }
     });
+
+    if (!result.isConfirmed) {
+        return;
+    }
+
+    try {
+        const analysis = await fetchWithHandling(`/api/v1/lists/${encodeURIComponent(CurrentList)}/analyze`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ names: result.value })
+        });
+
+        const order = { untrusted: 0, unknown: 1, trusted: 2 };
+        const pilots = [...analysis.pilots].sort((a, b) => order[a.verdict] - order[b.verdict] || a.name.localeCompare(b.name));
+        const rows = pilots.map(pilot => `
+            <tr class="analysis-${pilot.verdict}">
+                <td>${escapeHTML(pilot.name)}</td>
+                <td>${escapeHTML(pilot.corporation_name || '')}</td>
+                <td>${escapeHTML(pilot.alliance_name || '')}</td>
+                <td>${pilot.verdict}</td>
+                <td>${describeVerdict(pilot)}</td>
+            </tr>`).join('');
+        const unresolved = analysis.unresolved.length
+            ? `<p>Not found: ${analysis.unresolved.map(escapeHTML).join(', ')}</p>`
+            : '';
+
+        await Swal.fire({
+            title: 'Local',
+            html: `<p>${analysis.summary.untrusted} untrusted, ${analysis.summary.unknown} unknown, ${analysis.summary.trusted} trusted</p>
+                ${unresolved}
+                <div class="import-preview"><table class="sessions-table"><thead><tr><th>Pilot</th><th>Corporation</th><th>Alliance</th><th>Verdict</th><th>Because</th></tr></thead><tbody>${rows}</tbody></table></div>`,
+            width: 1000
+        });
+    } catch (error) {
+        toastr.error(`Failed to check local. ${error.message}`);
+    }
 }
 
+/**
+ * Describes the list entry that decided a pilot's verdict.
+ * @param {Object} pilot - Analyzed pilot.
+ * @returns {string} Escaped description.
+ */
+function describeVerdict(pilot) {
+    if (pilot.rule === 'none') {
+        return 'Not on the list';
+    }
+    if (pilot.rule === 'character') {
+        return `Listed as ${pilot.verdict}`;
+    }
+    const rule = pilot.rule.charAt(0).toUpperCase() + pilot.rule.slice(1);
+    return `${rule} ${escapeHTML(pilot.matched_name || String(pilot.matched_id))} is ${pilot.verdict}`;
+}
+
+/**
+ * Asks which entries of the current list to export and in which format, then downloads them.
+ */
+async function showExport() {
+    const result = await Swal.fire({
+        title: `Export ${escapeHTML(CurrentList)}`,
+        html: `
+            <select id="export-format" class="swal2-select">
+                <option value="csv">CSV</option>
+                <option value="json">JSON</option>
+                <option value="txt">Names only, one per line</option>
+            </select>
+            <select id="export-status" class="swal2-select">
+                <option value="">Trusted and untrusted</option>
+                <option value="trusted">Trusted only</option>
+                <option value="untrusted">Untrusted only</option>
+            </select>
+            <select id="export-type" class="swal2-select">
+                <option value="">Characters, corporations and alliances</option>
+                <option value="character">Characters only</option>
+                <option value="corporation">Corporations only</option>
+                <option value="alliance">Alliances only</option>
+            </select>`,
+        showCancelButton: true,
+        confirmButtonText: 'Download',
+        preConfirm: () => ({
+            format: document.getElementById('export-format').value,
+            status: document.getElementById('export-status').value,
+            type: document.getElementById('export-type').value
+        })
+    });
+
+    if (!result.isConfirmed) {
+        return;
+    }
+
+    const params = new URLSearchParams({ list: CurrentList, format: result.value.format });
+    if (result.value.status) {
+        params.set('status', result.value.status);
+    }
+    if (result.value.type) {
+        params.set('type', result.value.type);
+    }
+    window.location.href = `/export?${params}`;
+}
+
+/**
+ * Asks for names, IDs, CSV or JSON to import into the current list, previews what would happen to
+ * each row and imports the rows that can be added once confirmed.
+ */
+async function showImport() {
+    const result = await Swal.fire({
+        title: `Import into ${escapeHTML(CurrentList)}`,
+        html: `
+            <textarea id="import-data" class="swal2-textarea" rows="10" placeholder="One name or ID per line, CSV with a name, status, type, comment and standing header, or an exported JSON list"></textarea>
+            <select id="import-format" class="swal2-select">
+                <option value="">Detect format</option>
+                <option value="text">Names or IDs</option>
+                <option value="csv">CSV</option>
+                <option value="json">JSON</option>
+            </select>
+            <select id="import-status" class="swal2-select">
+                <option value="trusted">Trusted</option>
+                <option value="untrusted">Untrusted</option>
+            </select>
+            <select id="import-type" class="swal2-select">
+                <option value="">Characters, corporations or alliances</option>
+                <option value="character">Characters only</option>
+                <option value="corporation">Corporations only</option>
+                <option value="alliance">Alliances only</option>
+            </select>
+            <input id="import-comment" class="swal2-input" placeholder="Comment for rows without one">
+            <input id="import-standing" class="swal2-input" type="number" min="-10" max="10" step="0.1" placeholder="Standing for rows without one">`,
+        width: 800,
+        showCancelButton: true,
+        confirmButtonText: 'Preview',
+        focusConfirm: false,
+        preConfirm: () => {
+            const data = document.getElementById('import-data').value;
+            const standingValue = document.getElementById('import-standing').value;
+            if (!data.trim()) {
+                Swal.showValidationMessage('Paste something to import');
+                return false;
+            }
+            const standing = standingValue === '' ? null : parseFloat(standingValue);
+            if (standing !== null && (isNaN(standing) || standing < -10 || standing > 10)) {
+                Swal.showValidationMessage('Standing must be between -10 and 10');
+                return false;
+            }
+            return {
+                data,
+                format: document.getElementById('import-format').value,
+                status: document.getElementById('import-status').value,
+                type: document.getElementById('import-type').value,
+                comment: document.getElementById('import-comment').value.trim(),
+                standing
+            };
+        }
+    });
+
+    if (!result.isConfirmed) {
+        return;
+    }
+
+    const request = result.value;
+    try {
+        const preview = await sendImport(request);
+        const summary = preview.summary || {};
+        const toAdd = summary.add || 0;
+
+        const confirm = await Swal.fire({
+            title: 'Import preview',
+            html: `<p>${describeImportSummary(summary)}</p>${importPreviewTable(preview.rows)}`,
+            width: 900,
+            showCancelButton: true,
+            showConfirmButton: toAdd > 0,
+            confirmButtonText: `Add ${toAdd} ${toAdd === 1 ? 'entry' : 'entries'}`,
+            cancelButtonText: toAdd > 0 ? 'Cancel' : 'Close'
+        });
+        if (!confirm.isConfirmed) {
+            return;
+        }
+
+        const imported = await sendImport({ ...request, commit: true });
+        const skipped = toAdd - imported.added;
+        toastr.success(`Imported ${imported.added} ${imported.added === 1 ? 'entry' : 'entries'}` +
+            (skipped > 0 ? `, ${skipped} added by someone else meanwhile.` : '.'));
+        setTimeout(() => window.location.reload(), 1000);
+    } catch (error) {
+        toastr.error(`Failed to import. ${error.message}`);
+    }
+}
+
+/**
+ * Sends an import request for the current list.
+ * @param {Object} request - Import request body.
+ * @returns {Promise<Object>} The import response.
+ */
+function sendImport(request) {
+    return fetchWithHandling(`/api/v1/lists/${encodeURIComponent(CurrentList)}/import`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(request)
+    });
+}
+
+/**
+ * Describes how many import rows have each result.
+ * @param {Object} summary - Row counts by result.
+ * @returns {string} Summary text.
+ */
+function describeImportSummary(summary) {
+    const labels = {
+        add: 'to add',
+        exists: 'already on the list',
+        duplicate: 'repeated',
+        ambiguous: 'ambiguous',
+        unresolved: 'not found',
+        invalid: 'invalid'
+    };
+    return Object.keys(labels)
+        .filter(result => summary[result])
+        .map(result => `${summary[result]} ${labels[result]}`)
+        .join(', ');
+}
+
+/**
+ * Builds a table of import rows colored by their result.
+ * @param {Array} rows - Import rows.
+ * @returns {string} Table HTML.
+ */
+function importPreviewTable(rows) {
+    const body = rows.map(row => {
+        const candidates = (row.candidates || [])
+            .map(candidate => `${escapeHTML(candidate.name)} (${candidate.category} ${candidate.id})`)
+            .join(', ');
+        const message = [row.message, candidates].filter(Boolean).map(escapeHTML).join(': ');
+        return `
+            <tr class="import-row-${row.result}">
+                <td>${row.line}</td>
+                <td>${escapeHTML(row.input)}</td>
+                <td>${escapeHTML(row.name || '')}</td>
+                <td>${row.type || ''}</td>
+                <td>${row.status}</td>
+                <td>${escapeHTML(row.corporation_name || '')}</td>
+                <td>${escapeHTML(row.alliance_name || '')}</td>
+                <td>${row.result}</td>
+                <td>${message}</td>
+            </tr>`;
+    }).join('');
+
+    return `<div class="import-preview"><table class="sessions-table"><thead><tr><th>Line</th><th>Input</th><th>Name</th><th>Type</th><th>Status</th><th>Corporation</th><th>Alliance</th><th>Result</th><th></th></tr></thead><tbody>${body}</tbody></table></div>`;
+}
+
+/**
+ * Escapes text for inclusion in HTML.
+ * @param {string} text - The text to escape.
+ * @returns {string} - The escaped text.
+ */
+function escapeHTML(text) {
+    const element = document.createElement('div');
+    element.textContent = text;
+    return element.innerHTML;
+}
 
 /**
  * Toggle Button Event Listener
@@ -1177,15 +2093,19 @@ function setupToggleButton() {
             const trustedSections = [
                 "trusted-characters-table",
                 "trusted-corporations-table",
+                "trusted-alliances-table",
                 "add-trusted-character-section",
-                "add-trusted-corporation-section"
+                "add-trusted-corporation-section",
+                "add-trusted-alliance-section"
             ];
 
             const untrustedSections = [
                 "untrusted-characters-table",
                 "untrusted-corporations-table",
+                "untrusted-alliances-table",
                 "add-untrusted-character-section",
-                "add-untrusted-corporation-section"
+                "add-untrusted-corporation-section",
+                "add-untrusted-alliance-section"
             ];
 
             // Determine the current state using the `isShowingUntrusted` flag
@@ -1224,8 +2144,10 @@ function setupToggleButton() {
                 const tableIds = [
                     "trusted-characters-table",
                     "trusted-corporations-table",
+                    "trusted-alliances-table",
                     "untrusted-characters-table",
-                    "untrusted-corporations-table"
+                    "untrusted-corporations-table",
+                    "untrusted-alliances-table"
                 ];
 
                 tableIds.forEach(tableId => {
@@ -1253,8 +2175,10 @@ document.addEventListener('DOMContentLoaded', function () {
     const untrustedSections = [
         "untrusted-characters-table",
         "untrusted-corporations-table",
+        "untrusted-alliances-table",
         "add-untrusted-character-section",
-        "add-untrusted-corporation-section"
+        "add-untrusted-corporation-section",
+        "add-untrusted-alliance-section"
     ];
     toggleMultipleSections(untrustedSections, false);
 
@@ -1264,7 +2188,8 @@ document.addEventListener('DOMContentLoaded', function () {
     // Show trusted sections if they have data
     const trustedSections = [
         "trusted-characters-table",
-        "trusted-corporations-table"
+        "trusted-corporations-table",
+        "trusted-alliances-table"
     ];
     trustedSections.forEach(tableId => {
         const hasData = tables[tableId].getData().length > 0;
@@ -1277,11 +2202,52 @@ document.addEventListener('DOMContentLoaded', function () {
     // Setup Toggle Button Event Listener
     setupToggleButton();
 
+    // Trust list selection
+    const listSelect = document.getElementById("list-select");
+    if (listSelect) {
+        listSelect.addEventListener("change", () => switchList(listSelect.value));
+    }
+    const newListBtn = document.getElementById("new-list-btn");
+    if (newListBtn) {
+        newListBtn.addEventListener("click", createList);
+    }
+
+    // Active sessions list
+    const sessionsBtn = document.getElementById("sessions-btn");
+    if (sessionsBtn) {
+        sessionsBtn.addEventListener("click", showSessions);
+    }
+
+    // API tokens
+    const tokensBtn = document.getElementById("tokens-btn");
+    if (tokensBtn) {
+        tokensBtn.addEventListener("click", showTokens);
+    }
+
+    // Local chat analysis
+    const analyzeBtn = document.getElementById("analyze-btn");
+    if (analyzeBtn) {
+        analyzeBtn.addEventListener("click", showAnalyzer);
+    }
+
+    // Export
+    const exportBtn = document.getElementById("export-btn");
+    if (exportBtn) {
+        exportBtn.addEventListener("click", showExport);
+    }
+
+    // Bulk import
+    const importBtn = document.getElementById("import-btn");
+    if (importBtn) {
+        importBtn.addEventListener("click", showImport);
+    }
+
     // Initial resizing of tables on page load
     setTimeout(() => {
         const initialTables = [
             "trusted-characters-table",
-            "trusted-corporations-table"
+            "trusted-corporations-table",
+            "trusted-alliances-table"
             // Untrusted tables are hidden on page load
         ];
 
@@ -0,0 +1,89 @@
+package trust
+
+import (
+	"github.com/gambtho/whototrust/model"
+)
+
+// Verdicts
+const (
+	VerdictTrusted   = "trusted"
+	VerdictUntrusted = "untrusted"
+	VerdictUnknown   = "unknown"
+)
+
+// Rules that decide a verdict, from the most to the least specific
+const (
+	RuleCharacter   = "character"
+	RuleCorporation = "corporation"
+	RuleAlliance    = "alliance"
+	RuleNone        = "none"
+)
+
+// Verdict is how far a character is trusted and the entry that decided it
+type Verdict struct {
+	Verdict string `json:"verdict"`
+	Rule    string `json:"rule"`
+	// MatchedID and MatchedName identify the list entry the rule matched
+	MatchedID   int64  `json:"matched_id,omitempty"`
+	MatchedName string `json:"matched_name,omitempty"`
+}
+
+// Index looks up trust list entries by ID, for classifying many characters against one list
+type Index struct {
+	characters   map[int64]map[string]string
+	corporations map[int64]map[string]string
+	alliances    map[int64]map[string]string
+}
+
+// NewIndex indexes the entries of a trust list
+func NewIndex(trustedData *model.TrustedCharacters) *Index {
+	index := &Index{
+		characters:   make(map[int64]map[string]string),
+		corporations: make(map[int64]map[string]string),
+		alliances:    make(map[int64]map[string]string),
+	}
+	for _, entry := range Entries(trustedData) {
+		byID := index.characters
+		switch entry.Type {
+		case TypeCorporation:
+			byID = index.corporations
+		case TypeAlliance:
+			byID = index.alliances
+		}
+		if byID[entry.ID] == nil {
+			byID[entry.ID] = make(map[string]string)
+		}
+		byID[entry.ID][entry.Status] = entry.Name
+	}
+	return index
+}
+
+// Classify decides whether a character is trusted from its affiliation. A character entry beats a
+// corporation entry, which beats an alliance entry, and at the same level an untrusted entry beats a trusted one.
+func (index *Index) Classify(affiliation model.CharacterAffiliation) Verdict {
+	if verdict, ok := decide(index.characters[affiliation.CharacterID], RuleCharacter, affiliation.CharacterID); ok {
+		return verdict
+	}
+	if verdict, ok := decide(index.corporations[affiliation.CorporationID], RuleCorporation, affiliation.CorporationID); ok {
+		return verdict
+	}
+	if verdict, ok := decide(index.alliances[affiliation.AllianceID], RuleAlliance, affiliation.AllianceID); ok {
+		return verdict
+	}
+	return Verdict{Verdict: VerdictUnknown, Rule: RuleNone}
+}
+
+// decide returns the verdict of the entries found for one ID, if there are any. ID 0 is no corporation or
+// alliance and never matches.
+func decide(statuses map[string]string, rule string, id int64) (Verdict, bool) {
+	if id == 0 {
+		return Verdict{}, false
+	}
+	if name, ok := statuses[StatusUntrusted]; ok {
+		return Verdict{Verdict: VerdictUntrusted, Rule: rule, MatchedID: id, MatchedName: name}, true
+	}
+	if name, ok := statuses[StatusTrusted]; ok {
+		return Verdict{Verdict: VerdictTrusted, Rule: rule, MatchedID: id, MatchedName: name}, true
+	}
+	return Verdict{}, false
+}
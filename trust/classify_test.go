@@ -0,0 +1,108 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+func TestClassify(t *testing.T) {
+	const (
+		pilot       = 1
+		corporation = 10
+		alliance    = 100
+	)
+
+	tests := []struct {
+		name        string
+		list        model.TrustedCharacters
+		affiliation model.CharacterAffiliation
+		want        Verdict
+	}{
+		{
+			name:        "no entries",
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation, AllianceID: alliance},
+			want:        Verdict{Verdict: VerdictUnknown, Rule: RuleNone},
+		},
+		{
+			name:        "trusted character",
+			list:        model.TrustedCharacters{TrustedCharacters: []model.TrustedCharacter{{CharacterID: pilot, CharacterName: "Pilot"}}},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation},
+			want:        Verdict{Verdict: VerdictTrusted, Rule: RuleCharacter, MatchedID: pilot, MatchedName: "Pilot"},
+		},
+		{
+			name:        "untrusted corporation",
+			list:        model.TrustedCharacters{UntrustedCorporations: []model.TrustedCorporation{{CorporationID: corporation, CorporationName: "Corp"}}},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation},
+			want:        Verdict{Verdict: VerdictUntrusted, Rule: RuleCorporation, MatchedID: corporation, MatchedName: "Corp"},
+		},
+		{
+			name:        "trusted alliance",
+			list:        model.TrustedCharacters{TrustedAlliances: []model.TrustedAlliance{{AllianceID: alliance, AllianceName: "Alliance"}}},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation, AllianceID: alliance},
+			want:        Verdict{Verdict: VerdictTrusted, Rule: RuleAlliance, MatchedID: alliance, MatchedName: "Alliance"},
+		},
+		{
+			name: "character beats corporation",
+			list: model.TrustedCharacters{
+				TrustedCharacters:     []model.TrustedCharacter{{CharacterID: pilot, CharacterName: "Pilot"}},
+				UntrustedCorporations: []model.TrustedCorporation{{CorporationID: corporation, CorporationName: "Corp"}},
+			},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation},
+			want:        Verdict{Verdict: VerdictTrusted, Rule: RuleCharacter, MatchedID: pilot, MatchedName: "Pilot"},
+		},
+		{
+			name: "corporation beats alliance",
+			list: model.TrustedCharacters{
+				TrustedCorporations: []model.TrustedCorporation{{CorporationID: corporation, CorporationName: "Corp"}},
+				UntrustedAlliances:  []model.TrustedAlliance{{AllianceID: alliance, AllianceName: "Alliance"}},
+			},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation, AllianceID: alliance},
+			want:        Verdict{Verdict: VerdictTrusted, Rule: RuleCorporation, MatchedID: corporation, MatchedName: "Corp"},
+		},
+		{
+			name: "untrusted beats trusted character",
+			list: model.TrustedCharacters{
+				TrustedCharacters:   []model.TrustedCharacter{{CharacterID: pilot, CharacterName: "Pilot"}},
+				UntrustedCharacters: []model.TrustedCharacter{{CharacterID: pilot, CharacterName: "Pilot"}},
+			},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation},
+			want:        Verdict{Verdict: VerdictUntrusted, Rule: RuleCharacter, MatchedID: pilot, MatchedName: "Pilot"},
+		},
+		{
+			name: "untrusted beats trusted alliance",
+			list: model.TrustedCharacters{
+				TrustedAlliances:   []model.TrustedAlliance{{AllianceID: alliance, AllianceName: "Alliance"}},
+				UntrustedAlliances: []model.TrustedAlliance{{AllianceID: alliance, AllianceName: "Alliance"}},
+			},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation, AllianceID: alliance},
+			want:        Verdict{Verdict: VerdictUntrusted, Rule: RuleAlliance, MatchedID: alliance, MatchedName: "Alliance"},
+		},
+		{
+			name:        "alliance entry does not match a character without an alliance",
+			list:        model.TrustedCharacters{TrustedAlliances: []model.TrustedAlliance{{AllianceID: alliance, AllianceName: "Alliance"}}},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation},
+			want:        Verdict{Verdict: VerdictUnknown, Rule: RuleNone},
+		},
+		{
+			name:        "an entry with ID 0 does not match a character without an alliance",
+			list:        model.TrustedCharacters{UntrustedAlliances: []model.TrustedAlliance{{AllianceID: 0}}},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation},
+			want:        Verdict{Verdict: VerdictUnknown, Rule: RuleNone},
+		},
+		{
+			name:        "falls through to the alliance when the corporation is not listed",
+			list:        model.TrustedCharacters{UntrustedAlliances: []model.TrustedAlliance{{AllianceID: alliance, AllianceName: "Alliance"}}, TrustedCorporations: []model.TrustedCorporation{{CorporationID: 11}}},
+			affiliation: model.CharacterAffiliation{CharacterID: pilot, CorporationID: corporation, AllianceID: alliance},
+			want:        Verdict{Verdict: VerdictUntrusted, Rule: RuleAlliance, MatchedID: alliance, MatchedName: "Alliance"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewIndex(&tt.list).Classify(tt.affiliation); got != tt.want {
+				t.Errorf("Classify() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
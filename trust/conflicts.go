@@ -0,0 +1,180 @@
+package trust
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gambtho/whototrust/model"
+)
+
+// Conflict types
+const (
+	ConflictCorporation = "corporation"
+	ConflictAlliance    = "alliance"
+	ConflictBothLists   = "both_lists"
+)
+
+// FindConflicts returns every entry whose current affiliation is on the opposing list,
+// such as a trusted character in an untrusted corporation or an untrusted corporation in a trusted alliance.
+// Affiliation data is only as current as the last refresh of the trust list.
+func FindConflicts(trustedData *model.TrustedCharacters) []model.TrustConflict {
+	conflicts := []model.TrustConflict{}
+	if trustedData == nil {
+		return conflicts
+	}
+
+	trustedCorps := corporationsByID(trustedData.TrustedCorporations)
+	untrustedCorps := corporationsByID(trustedData.UntrustedCorporations)
+	trustedAlliances := alliancesByID(trustedData.TrustedAlliances)
+	untrustedAlliances := alliancesByID(trustedData.UntrustedAlliances)
+	untrustedChars := make(map[int64]bool, len(trustedData.UntrustedCharacters))
+	for _, char := range trustedData.UntrustedCharacters {
+		untrustedChars[char.CharacterID] = true
+	}
+
+	for _, char := range trustedData.TrustedCharacters {
+		if untrustedChars[char.CharacterID] {
+			conflicts = append(conflicts, bothListsConflict("character", char.CharacterID, char.CharacterName))
+		}
+		if corp, ok := untrustedCorps[char.CorporationID]; ok {
+			conflicts = append(conflicts, model.TrustConflict{
+				EntityType:   "character",
+				EntityID:     char.CharacterID,
+				EntityName:   char.CharacterName,
+				Status:       "trusted",
+				ConflictType: ConflictCorporation,
+				ConflictID:   corp.CorporationID,
+				ConflictName: corp.CorporationName,
+				Description:  fmt.Sprintf("Trusted character %s is in untrusted corporation %s", char.CharacterName, corp.CorporationName),
+			})
+		}
+		if alliance, ok := untrustedAlliances[char.AllianceID]; ok {
+			conflicts = append(conflicts, allianceConflict("character", char.CharacterID, char.CharacterName, "trusted", alliance))
+		}
+	}
+
+	for _, char := range trustedData.UntrustedCharacters {
+		if corp, ok := trustedCorps[char.CorporationID]; ok {
+			conflicts = append(conflicts, model.TrustConflict{
+				EntityType:   "character",
+				EntityID:     char.CharacterID,
+				EntityName:   char.CharacterName,
+				Status:       "untrusted",
+				ConflictType: ConflictCorporation,
+				ConflictID:   corp.CorporationID,
+				ConflictName: corp.CorporationName,
+				Description:  fmt.Sprintf("Untrusted character %s is in trusted corporation %s", char.CharacterName, corp.CorporationName),
+			})
+		}
+		if alliance, ok := trustedAlliances[char.AllianceID]; ok {
+			conflicts = append(conflicts, allianceConflict("character", char.CharacterID, char.CharacterName, "untrusted", alliance))
+		}
+	}
+
+	for _, corp := range trustedData.TrustedCorporations {
+		if _, ok := untrustedCorps[corp.CorporationID]; ok {
+			conflicts = append(conflicts, bothListsConflict("corporation", corp.CorporationID, corp.CorporationName))
+		}
+		if alliance, ok := untrustedAlliances[corp.AllianceID]; ok {
+			conflicts = append(conflicts, allianceConflict("corporation", corp.CorporationID, corp.CorporationName, "trusted", alliance))
+		}
+	}
+
+	for _, corp := range trustedData.UntrustedCorporations {
+		if alliance, ok := trustedAlliances[corp.AllianceID]; ok {
+			conflicts = append(conflicts, allianceConflict("corporation", corp.CorporationID, corp.CorporationName, "untrusted", alliance))
+		}
+	}
+
+	for _, alliance := range trustedData.TrustedAlliances {
+		if _, ok := untrustedAlliances[alliance.AllianceID]; ok {
+			conflicts = append(conflicts, bothListsConflict("alliance", alliance.AllianceID, alliance.AllianceName))
+		}
+	}
+
+	return conflicts
+}
+
+func bothListsConflict(entityType string, id int64, name string) model.TrustConflict {
+	return model.TrustConflict{
+		EntityType:   entityType,
+		EntityID:     id,
+		EntityName:   name,
+		Status:       "trusted",
+		ConflictType: ConflictBothLists,
+		ConflictID:   id,
+		ConflictName: name,
+		Description:  fmt.Sprintf("%s is on both the trusted and untrusted lists", name),
+	}
+}
+
+// allianceConflict reports a character or corporation in an alliance on the opposing list
+func allianceConflict(entityType string, id int64, name string, status string, alliance model.TrustedAlliance) model.TrustConflict {
+	description := fmt.Sprintf("Trusted %s %s is in untrusted alliance %s", entityType, name, alliance.AllianceName)
+	if status == "untrusted" {
+		description = fmt.Sprintf("Untrusted %s %s is in trusted alliance %s", entityType, name, alliance.AllianceName)
+	}
+	return model.TrustConflict{
+		EntityType:   entityType,
+		EntityID:     id,
+		EntityName:   name,
+		Status:       status,
+		ConflictType: ConflictAlliance,
+		ConflictID:   alliance.AllianceID,
+		ConflictName: alliance.AllianceName,
+		Description:  description,
+	}
+}
+
+func alliancesByID(alliances []model.TrustedAlliance) map[int64]model.TrustedAlliance {
+	byID := make(map[int64]model.TrustedAlliance, len(alliances))
+	for _, alliance := range alliances {
+		byID[alliance.AllianceID] = alliance
+	}
+	return byID
+}
+
+func corporationsByID(corporations []model.TrustedCorporation) map[int64]model.TrustedCorporation {
+	byID := make(map[int64]model.TrustedCorporation, len(corporations))
+	for _, corp := range corporations {
+		byID[corp.CorporationID] = corp
+	}
+	return byID
+}
+
+// NewConflicts returns the conflicts in current that are not in previous
+func NewConflicts(previous, current []model.TrustConflict) []model.TrustConflict {
+	known := make(map[string]bool, len(previous))
+	for _, conflict := range previous {
+		known[conflictKey(conflict)] = true
+	}
+
+	var added []model.TrustConflict
+	for _, conflict := range current {
+		if !known[conflictKey(conflict)] {
+			added = append(added, conflict)
+		}
+	}
+	return added
+}
+
+// ConflictEvents returns an audit event for every conflict in current that was not already in previous
+func ConflictEvents(previous, current []model.TrustConflict, actor string) []model.AuditEvent {
+	var events []model.AuditEvent
+	for _, conflict := range NewConflicts(previous, current) {
+		events = append(events, model.AuditEvent{
+			Time:       time.Now(),
+			Type:       model.EventConflictDetected,
+			Actor:      actor,
+			EntityType: conflict.EntityType,
+			EntityID:   conflict.EntityID,
+			EntityName: conflict.EntityName,
+			Detail:     conflict.Description,
+		})
+	}
+	return events
+}
+
+func conflictKey(conflict model.TrustConflict) string {
+	return fmt.Sprintf("%s:%d:%s:%d", conflict.EntityType, conflict.EntityID, conflict.ConflictType, conflict.ConflictID)
+}
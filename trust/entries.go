@@ -0,0 +1,103 @@
+package trust
+
+import (
+	"github.com/gambtho/whototrust/model"
+)
+
+// Entry statuses and types
+const (
+	StatusTrusted   = "trusted"
+	StatusUntrusted = "untrusted"
+	TypeCharacter   = "character"
+	TypeCorporation = "corporation"
+	TypeAlliance    = "alliance"
+)
+
+// Entries flattens every entry on a trust list, trusted characters first
+func Entries(trustedData *model.TrustedCharacters) []model.TrustEntry {
+	entries := []model.TrustEntry{}
+	if trustedData == nil {
+		return entries
+	}
+
+	entries = appendCharacters(entries, StatusTrusted, trustedData.TrustedCharacters)
+	entries = appendCorporations(entries, StatusTrusted, trustedData.TrustedCorporations)
+	entries = appendAlliances(entries, StatusTrusted, trustedData.TrustedAlliances)
+	entries = appendCharacters(entries, StatusUntrusted, trustedData.UntrustedCharacters)
+	entries = appendCorporations(entries, StatusUntrusted, trustedData.UntrustedCorporations)
+	entries = appendAlliances(entries, StatusUntrusted, trustedData.UntrustedAlliances)
+	return entries
+}
+
+// FindEntry returns an entry on a trust list, if it is there
+func FindEntry(trustedData *model.TrustedCharacters, status string, entityType string, id int64) (model.TrustEntry, bool) {
+	for _, entry := range Entries(trustedData) {
+		if entry.Status == status && entry.Type == entityType && entry.ID == id {
+			return entry, true
+		}
+	}
+	return model.TrustEntry{}, false
+}
+
+func appendCharacters(entries []model.TrustEntry, status string, characters []model.TrustedCharacter) []model.TrustEntry {
+	for _, char := range characters {
+		entries = append(entries, model.TrustEntry{
+			Status:          status,
+			Type:            TypeCharacter,
+			ID:              char.CharacterID,
+			Name:            char.CharacterName,
+			CorporationID:   char.CorporationID,
+			CorporationName: char.CorporationName,
+			AllianceID:      char.AllianceID,
+			AllianceName:    char.AllianceName,
+			AddedBy:         char.AddedBy,
+			DateAdded:       char.DateAdded,
+			Comment:         char.Comment,
+			Standing:        char.Standing,
+			ExpiresAt:       char.ExpiresAt,
+			Tags:            char.Tags,
+			Revision:        char.Revision,
+		})
+	}
+	return entries
+}
+
+func appendCorporations(entries []model.TrustEntry, status string, corporations []model.TrustedCorporation) []model.TrustEntry {
+	for _, corp := range corporations {
+		entries = append(entries, model.TrustEntry{
+			Status:       status,
+			Type:         TypeCorporation,
+			ID:           corp.CorporationID,
+			Name:         corp.CorporationName,
+			AllianceID:   corp.AllianceID,
+			AllianceName: corp.AllianceName,
+			AddedBy:      corp.AddedBy,
+			DateAdded:    corp.DateAdded,
+			Comment:      corp.Comment,
+			Standing:     corp.Standing,
+			ExpiresAt:    corp.ExpiresAt,
+			Tags:         corp.Tags,
+			Revision:     corp.Revision,
+		})
+	}
+	return entries
+}
+
+func appendAlliances(entries []model.TrustEntry, status string, alliances []model.TrustedAlliance) []model.TrustEntry {
+	for _, alliance := range alliances {
+		entries = append(entries, model.TrustEntry{
+			Status:    status,
+			Type:      TypeAlliance,
+			ID:        alliance.AllianceID,
+			Name:      alliance.AllianceName,
+			AddedBy:   alliance.AddedBy,
+			DateAdded: alliance.DateAdded,
+			Comment:   alliance.Comment,
+			Standing:  alliance.Standing,
+			ExpiresAt: alliance.ExpiresAt,
+			Tags:      alliance.Tags,
+			Revision:  alliance.Revision,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,54 @@
+package trust
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gambtho/whototrust/eveapi"
+	"github.com/gambtho/whototrust/model"
+)
+
+// ErrUnknownCharacter is returned when evaluating an ID that is not a character
+var ErrUnknownCharacter = errors.New("no character found with this ID")
+
+// Evaluation is the effective trust of a character on a list, with the affiliation it was decided from
+type Evaluation struct {
+	CharacterID     int64  `json:"character_id"`
+	CharacterName   string `json:"character_name,omitempty"`
+	CorporationID   int64  `json:"corporation_id"`
+	CorporationName string `json:"corporation_name,omitempty"`
+	AllianceID      int64  `json:"alliance_id,omitempty"`
+	AllianceName    string `json:"alliance_name,omitempty"`
+	Verdict
+}
+
+// Evaluate looks up the current affiliation of a character and decides whether the list trusts it,
+// applying the same precedence as Index.Classify. Affiliations and names come from the ESI cache.
+func Evaluate(trustedData *model.TrustedCharacters, characterID int64) (Evaluation, error) {
+	affiliations, err := eveapi.CachedAffiliations([]int64{characterID})
+	if errors.Is(err, eveapi.ErrNotFound) || errors.Is(err, eveapi.ErrBadRequest) {
+		return Evaluation{}, ErrUnknownCharacter
+	}
+	if err != nil {
+		return Evaluation{}, fmt.Errorf("failed to get affiliation of character %d: %v", characterID, err)
+	}
+	affiliation, ok := affiliations[characterID]
+	if !ok {
+		return Evaluation{}, ErrUnknownCharacter
+	}
+
+	names, err := eveapi.CachedNames([]int64{characterID, affiliation.CorporationID, affiliation.AllianceID})
+	if err != nil {
+		return Evaluation{}, fmt.Errorf("failed to resolve affiliation names: %v", err)
+	}
+
+	return Evaluation{
+		CharacterID:     characterID,
+		CharacterName:   names[characterID],
+		CorporationID:   affiliation.CorporationID,
+		CorporationName: names[affiliation.CorporationID],
+		AllianceID:      affiliation.AllianceID,
+		AllianceName:    names[affiliation.AllianceID],
+		Verdict:         NewIndex(trustedData).Classify(affiliation),
+	}, nil
+}
@@ -0,0 +1,57 @@
+package trust
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Tag limits
+const (
+	MaxTags      = 10
+	MaxTagLength = 32
+)
+
+// NormalizeTags lowercases and trims tags, drops empty and repeated ones and sorts them. Tags are
+// written as comma separated text in the UI and CSV files, so they cannot contain commas.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		switch {
+		case tag == "" || slices.Contains(normalized, tag):
+			continue
+		case strings.Contains(tag, ","):
+			return nil, fmt.Errorf("tags cannot contain commas: %s", tag)
+		case len(tag) > MaxTagLength:
+			return nil, fmt.Errorf("tags are limited to %d characters: %s", MaxTagLength, tag)
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("entries are limited to %d tags", MaxTags)
+	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// SplitTags reads comma separated tags
+func SplitTags(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return strings.Split(text, ",")
+}
+
+// HasAnyTag reports whether an entry has at least one of the wanted tags. Every entry matches when no tags are wanted.
+func HasAnyTag(tags []string, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, tag := range wanted {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}